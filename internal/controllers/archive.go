@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"amazon/internal/utils"
+	"amazon/models"
+	"errors"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ArchiveHandler struct{}
+
+func NewArchiveHandler() ArchiveHandler {
+	return ArchiveHandler{}
+}
+
+// GetArchive lists archived upstream responses, newest first.
+// Optional query parameters: url (substring filter) and limit (default 100).
+func (h ArchiveHandler) GetArchive(c *fiber.Ctx) error {
+	entries, err := utils.ListArchive(c.Query("url"), c.QueryInt("limit", 100))
+	if errors.Is(err, utils.ErrArchiveDisabled) {
+		return archiveDisabled(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to list archive: " + err.Error(),
+			Code:  "error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: entries,
+	})
+}
+
+// GetArchivedResponse serves the raw body of one archived response, or its
+// metadata with ?meta=true.
+func (h ArchiveHandler) GetArchivedResponse(c *fiber.Ctx) error {
+	entry, content, err := utils.ReadArchive(c.Params("id"))
+
+	if errors.Is(err, utils.ErrArchiveDisabled) {
+		return archiveDisabled(c)
+	}
+	if os.IsNotExist(err) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: "Archived response not found",
+			Code:  "not_found",
+		})
+	}
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to read archived response: " + err.Error(),
+			Code:  "error",
+		})
+	}
+
+	if c.QueryBool("meta") {
+		return c.Status(fiber.StatusOK).JSON(models.Response{
+			Code: "success",
+			Data: entry,
+		})
+	}
+
+	// served as text so the archived page never runs in the admin's browser
+	return c.Status(fiber.StatusOK).Type("txt", "utf-8").SendString(content)
+}
+
+func archiveDisabled(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.Response{
+		Error: "Response archive is disabled, set RAW_ARCHIVE_DIR to enable it",
+		Code:  "archive_disabled",
+	})
+}
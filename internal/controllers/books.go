@@ -3,9 +3,9 @@ package controllers
 import (
 	"amazon/internal/scrapers"
 	"amazon/internal/scrapers/books"
+	"amazon/internal/services"
 	"amazon/internal/utils"
 	"amazon/models"
-	"amazon/notification"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -27,7 +27,19 @@ func (h *BookHandler) GetBooks(c *fiber.Ctx) error {
 		})
 	}
 
-	books, pageCount, err := scrapers.FetchBooks(page)
+	language, ok := languageFilter(c)
+	if !ok {
+		return invalidLanguageFilter(c)
+	}
+
+	// the language is filtered before paging, so its pages stay full
+	var thumbnails *[]models.BookThumbnail
+	var pageCount int
+	if language == "" {
+		thumbnails, pageCount, err = scrapers.FetchBooks(page)
+	} else {
+		thumbnails, pageCount, err = scrapers.FetchBooksInLanguage(language, page)
+	}
 
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(models.Response{
@@ -37,21 +49,30 @@ func (h *BookHandler) GetBooks(c *fiber.Ctx) error {
 		})
 	}
 
-	// shuffled := utils.Shuffle(*books)
-	// books = &shuffled
+	// shuffled := utils.Shuffle(*thumbnails)
+	// thumbnails = &shuffled
+
+	books.FillThumbnailLanguages(*thumbnails)
+	proxyThumbnailCovers(*thumbnails)
 
 	return c.Status(fiber.StatusOK).JSON(models.Response{
 		Error: "",
 		Code:  "success",
 		Data: models.BooksResponse{
 			PageCount: pageCount,
-			Books:     *books,
+			Books:     *thumbnails,
 		},
 	})
 }
 
 func (h *BookHandler) GetBookByID(c *fiber.Ctx) error {
 	id := c.Params("id")
+
+	descFormat, ok := descriptionFormat(c)
+	if !ok {
+		return invalidDescriptionFormat(c)
+	}
+
 	book, errCode, err := scrapers.FetchBook(id)
 
 	status := fiber.StatusInternalServerError
@@ -68,11 +89,13 @@ func (h *BookHandler) GetBookByID(c *fiber.Ctx) error {
 	}
 
 	// UPDATE: CLIENT ASKED TO NOT DISPLAY THE PRICE AND SEND IT AS AN EMAIL WITH HIS OWN FORMULA.
-	// apply new formatted price
-	// book.Price = utils.FormatPrice(book.Price)
+	// orders are priced with services.PricingService and the customer receives
+	// the prices as an emailed quote, see services.QuoteService
+
+	// serve the cover through our proxy, upscaled for better quality
+	book.Cover = services.CoverProxyURL(book.Cover, 1000)
 
-	// upscale image for better quality
-	book.Cover = utils.ResizeBookImage(book.Cover, 1000)
+	formatDescription(book, descFormat)
 
 	return c.Status(fiber.StatusOK).JSON(models.Response{
 		Error: "",
@@ -83,6 +106,12 @@ func (h *BookHandler) GetBookByID(c *fiber.Ctx) error {
 
 func (h *BookHandler) GetGBookByID(c *fiber.Ctx) error {
 	id := c.Params("id")
+
+	descFormat, ok := descriptionFormat(c)
+	if !ok {
+		return invalidDescriptionFormat(c)
+	}
+
 	book, errCode, err := scrapers.FetchGBook(id)
 
 	status := fiber.StatusInternalServerError
@@ -99,11 +128,13 @@ func (h *BookHandler) GetGBookByID(c *fiber.Ctx) error {
 	}
 
 	// UPDATE: CLIENT ASKED TO NOT DISPLAY THE PRICE AND SEND IT AS AN EMAIL WITH HIS OWN FORMULA.
-	// apply new formatted price
-	// book.Price = utils.FormatPrice(book.Price)
+	// orders are priced with services.PricingService and the customer receives
+	// the prices as an emailed quote, see services.QuoteService
+
+	// serve the cover through our proxy, upscaled for better quality
+	book.Cover = services.CoverProxyURL(book.Cover, 1000)
 
-	// upscale image for better quality
-	book.Cover = utils.ResizeBookImage(book.Cover, 1000)
+	formatDescription(book, descFormat)
 
 	return c.Status(fiber.StatusOK).JSON(models.Response{
 		Error: "",
@@ -112,72 +143,50 @@ func (h *BookHandler) GetGBookByID(c *fiber.Ctx) error {
 	})
 }
 
-func (h *BookHandler) SearchLirekaBooks(c *fiber.Ctx) error {
-
-	query := c.Query("query")
-	_, err := strconv.Atoi(c.Query("page"))
-
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
-			Error: "Missing or invalid 'page' query parameter",
-			Code:  "invalid_params",
-			Data:  nil,
-		})
-	}
-
-	books, err := books.LirekaSearchBooks(query)
-	if err != nil {
-		utils.Report("Failed to search books: " + err.Error())
-
-		return c.Status(fiber.StatusNotFound).JSON(models.Response{
-			Error: "No search results for " + query,
-			Code:  "empty_page",
-			Data:  nil,
-		})
+// proxyThumbnailCovers points listing covers to our cover proxy.
+func proxyThumbnailCovers(thumbnails []models.BookThumbnail) {
+	for i := range thumbnails {
+		thumbnails[i].Cover = services.CoverProxyURL(thumbnails[i].Cover, utils.COVER_IMG_SIZE)
 	}
+}
 
-	notification.SendTo(notification.ApiKeys[0], "Someone is searching...", "Search: "+query)
+// descriptionFormat reads ?descFormat=markdown|html|text, markdown by default.
+func descriptionFormat(c *fiber.Ctx) (string, bool) {
+	format := c.Query("descFormat", utils.DESCRIPTION_FORMAT_MARKDOWN)
+	return format, utils.IsDescriptionFormat(format)
+}
 
-	return c.Status(fiber.StatusOK).JSON(models.Response{
-		Error: "",
-		Code:  "success",
-		Data: models.LirekaBooksResponse{
-			PageCount: 1,
-			Books:     books,
-		},
+func invalidDescriptionFormat(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+		Error: "Invalid 'descFormat' query parameter, expected markdown, html or text",
+		Code:  "invalid_params",
+		Data:  nil,
 	})
 }
 
-func (h *BookHandler) SearchBooks(c *fiber.Ctx) error {
-
-	query := c.Query("query")
-	page, err := strconv.Atoi(c.Query("page"))
+// formatDescription renders the stored description in the requested format and
+// adds the short plain text variant used by listing cards.
+func formatDescription(book *models.Book, format string) {
+	book.ShortDescription = utils.ShortDescription(book.Description, utils.SHORT_DESCRIPTION_LENGTH)
+	book.Description = utils.RenderDescription(book.Description, format)
+}
 
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
-			Error: "Missing or invalid 'page' query parameter",
-			Code:  "invalid_params",
-			Data:  nil,
-		})
+// languageFilter reads ?lang=, accepting ISO 639-1 codes as well as names
+// such as "Français".
+func languageFilter(c *fiber.Ctx) (string, bool) {
+	raw := c.Query("lang")
+	if raw == "" {
+		return "", true
 	}
 
-	books, pageCount, err := books.SearchBooks(query, page)
-	if err != nil {
-		utils.Report("Failed to search books: " + err.Error())
-
-		return c.Status(fiber.StatusNotFound).JSON(models.Response{
-			Error: "No search results for " + query,
-			Code:  "empty_page",
-			Data:  nil,
-		})
-	}
+	language := utils.NormalizeLanguage(raw)
+	return language, language != ""
+}
 
-	return c.Status(fiber.StatusOK).JSON(models.Response{
-		Error: "",
-		Code:  "success",
-		Data: models.BooksResponse{
-			PageCount: pageCount,
-			Books:     *books,
-		},
+func invalidLanguageFilter(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+		Error: "Invalid 'lang' query parameter, expected an ISO 639-1 code such as fr, en or ar",
+		Code:  "invalid_params",
+		Data:  nil,
 	})
 }
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/internal/utils"
+	"amazon/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CoverHandler struct {
+	Service *services.CoverService
+}
+
+func NewCoverHandler() CoverHandler {
+	return CoverHandler{
+		Service: services.NewCoverService(),
+	}
+}
+
+// GetCover serves /covers/:provider/:id?w=500&format=webp|jpeg&title=...
+// Without an explicit format, WebP is served to clients that accept it.
+func (h CoverHandler) GetCover(c *fiber.Ctx) error {
+	provider := c.Params("provider")
+	id, err := url.PathUnescape(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid cover id",
+			Code:  "invalid_params",
+		})
+	}
+
+	format := strings.ToLower(c.Query("format"))
+	switch format {
+	case "webp":
+		format = services.COVER_FORMAT_WEBP
+	case "jpeg", "jpg":
+		format = services.COVER_FORMAT_JPEG
+	default:
+		format = services.COVER_FORMAT_JPEG
+		if strings.Contains(c.Get(fiber.HeaderAccept), "image/webp") {
+			format = services.COVER_FORMAT_WEBP
+		}
+		c.Vary(fiber.HeaderAccept)
+	}
+
+	cover, err := h.Service.GetCover(provider, id, c.QueryInt("w"), format, c.Query("title"))
+
+	if errors.Is(err, services.ErrUnknownCoverProvider) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: "Unknown cover provider: " + provider,
+			Code:  "not_found",
+		})
+	}
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to load cover: " + err.Error(),
+			Code:  "server_error",
+		})
+	}
+
+	maxAge := int(utils.COVER_CACHE_MAX_AGE.Seconds())
+	if cover.Placeholder {
+		// retry the upstream soon, it may get a cover later
+		maxAge = 3600
+	}
+
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", maxAge))
+	c.Set(fiber.HeaderLastModified, cover.ModTime.UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderContentType, cover.ContentType)
+
+	return c.Status(fiber.StatusOK).Send(cover.Data)
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/internal/utils"
+	"amazon/models"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ExchangeRateHandler struct {
+	Service *services.ExchangeRateService
+}
+
+func NewExchangeRateHandler() ExchangeRateHandler {
+	return ExchangeRateHandler{
+		Service: services.NewExchangeRateService(),
+	}
+}
+
+// GetRates lists the rates of ?currency=EUR, latest first, ?limit=100.
+func (h ExchangeRateHandler) GetRates(c *fiber.Ctx) error {
+	currency := strings.ToUpper(c.Query("currency", utils.DEFAULT_CURRENCY))
+
+	rates, err := h.Service.History(currency, c.QueryInt("limit", 100))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to load the exchange rates: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: rates,
+	})
+}
+
+// GetCurrentRate returns the rate of ?currency=EUR in effect now.
+func (h ExchangeRateHandler) GetCurrentRate(c *fiber.Ctx) error {
+	currency := strings.ToUpper(c.Query("currency", utils.DEFAULT_CURRENCY))
+
+	rate, err := h.Service.CurrentRate(currency)
+	if errors.Is(err, services.ErrNoExchangeRate) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "not_found",
+			Data:  nil,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to load the exchange rate: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: rate,
+	})
+}
+
+// PostRate sets a rate: {"rate": 265, "currency": "EUR", "effective_at":
+// "2025-02-01T00:00:00+01:00", "note": "..."}, effective now without a date.
+func (h ExchangeRateHandler) PostRate(c *fiber.Ctx) error {
+	var rate models.ExchangeRate
+	if err := c.BodyParser(&rate); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid JSON format: " + err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	rate.Currency = strings.ToUpper(rate.Currency)
+	if err := h.Service.SetRate(&rate, fmt.Sprint(c.Locals("adminID"))); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_rate",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.Response{
+		Code: "success",
+		Data: rate,
+	})
+}
+
+// PostImport imports rates from a CSV file, uploaded as the "file" form field
+// or sent as the request body.
+func (h ExchangeRateHandler) PostImport(c *fiber.Ctx) error {
+	var file io.Reader = bytes.NewReader(c.Body())
+
+	if upload, err := c.FormFile("file"); err == nil {
+		opened, err := upload.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+				Error: "Cannot read the uploaded file: " + err.Error(),
+				Code:  "invalid_params",
+				Data:  nil,
+			})
+		}
+		defer opened.Close()
+		file = opened
+	}
+
+	imported, err := h.Service.ImportCSV(file, fmt.Sprint(c.Locals("adminID")))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Import failed, nothing was imported: " + err.Error(),
+			Code:  "invalid_csv",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: fiber.Map{
+			"imported": imported,
+		},
+	})
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/models"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetInvoice prints the invoice of an order as a PDF.
+func (h OrderHandler) GetInvoice(c *fiber.Ctx) error {
+	return h.sendOrderDocument(c, "facture", h.Service.WriteInvoice)
+}
+
+// GetSlip prints the delivery slip of an order as a PDF.
+func (h OrderHandler) GetSlip(c *fiber.Ctx) error {
+	return h.sendOrderDocument(c, "bon-livraison", h.Service.WriteSlip)
+}
+
+// GetSlips prints the delivery slips of a shipping batch in one PDF, the
+// orders chosen with the filters of the order list: ?ids=1,2,3,
+// ?purchase=4 for the orders of a purchase batch or ?status=received.
+func (h OrderHandler) GetSlips(c *fiber.Ctx) error {
+	query, err := orderQueryFromRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_params",
+		})
+	}
+	if query.Sort == "" {
+		query.Sort = "created_at"
+	}
+
+	var document bytes.Buffer
+	err = h.Service.WriteSlips(query, &document)
+	switch {
+	case errors.Is(err, services.ErrNoSlips):
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "not_found",
+		})
+	case errors.Is(err, services.ErrTooManySlips), errors.Is(err, services.ErrInvalidOrderQuery):
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_params",
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to print the slips: " + err.Error(),
+			Code:  "error",
+		})
+	}
+
+	fileName := fmt.Sprintf("bons-livraison-%s.pdf", time.Now().Format(time.DateOnly))
+	return sendPDF(c, fileName, document.Bytes())
+}
+
+func (h OrderHandler) sendOrderDocument(c *fiber.Ctx, name string, write func(*models.Order, io.Writer) error) error {
+	order, err := h.Service.GetOrderByID(c.Params("id"))
+	if errors.Is(err, services.ErrOrderNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: "Order not found",
+			Code:  "not_found",
+		})
+	}
+
+	var document bytes.Buffer
+	if err == nil {
+		err = write(order, &document)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to print the document: " + err.Error(),
+			Code:  "error",
+		})
+	}
+
+	return sendPDF(c, fmt.Sprintf("%s-%d.pdf", name, order.ID), document.Bytes())
+}
+
+// sendPDF answers with a document the browser shows, saved under fileName.
+func sendPDF(c *fiber.Ctx, fileName string, document []byte) error {
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="`+fileName+`"`)
+	return c.Status(fiber.StatusOK).Send(document)
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/models"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OrderEventHandler struct {
+	Service *services.OrderEventService
+}
+
+func NewOrderEventHandler() OrderEventHandler {
+	return OrderEventHandler{
+		Service: services.NewOrderEventService(),
+	}
+}
+
+type orderComment struct {
+	Comment string `json:"comment"`
+}
+
+// GetOrderHistory lists the status changes, edits and notes of an order,
+// oldest first, for the timeline of the admin panel.
+func (h OrderEventHandler) GetOrderHistory(c *fiber.Ctx) error {
+	events, err := h.Service.History(c.Params("id"))
+	if err != nil {
+		return orderEventError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  events,
+	})
+}
+
+// PostOrderNote adds a note to the history of an order: {"comment": "..."}.
+func (h OrderEventHandler) PostOrderNote(c *fiber.Ctx) error {
+	var body orderComment
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid JSON format: " + err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	event, err := h.Service.AddNote(c.Params("id"), fmt.Sprint(c.Locals("adminID")), body.Comment)
+	if err != nil {
+		return orderEventError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  event,
+	})
+}
+
+func orderEventError(c *fiber.Ctx, err error) error {
+	status, code := fiber.StatusInternalServerError, "error"
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		status, code = fiber.StatusNotFound, "not_found"
+	case errors.Is(err, services.ErrEmptyNote):
+		status, code = fiber.StatusBadRequest, "invalid_params"
+	}
+
+	return c.Status(status).JSON(models.Response{
+		Error: err.Error(),
+		Code:  code,
+		Data:  nil,
+	})
+}
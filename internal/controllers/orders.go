@@ -2,10 +2,17 @@ package controllers
 
 import (
 	"amazon/internal/services"
+	"amazon/internal/utils"
 	"amazon/models"
 	"amazon/notification"
+	"amazon/sheet"
+	"bufio"
+	"errors"
 	"fmt"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -20,6 +27,13 @@ func NewOrderHandler() OrderHandler {
 	}
 }
 
+const (
+	maxIdempotencyKeyLength = 255
+
+	defaultOrderListLimit = 50
+	maxOrderListLimit     = 200
+)
+
 func isOneEmpty(elements ...string) bool {
 	return slices.Contains(elements, "")
 }
@@ -49,27 +63,25 @@ func (h OrderHandler) PostOrder(c *fiber.Ctx) error {
 			Error: "Order must contain at least one item",
 		})
 	}
+	if len(order.OrderItems) > utils.MAX_ORDER_ITEMS {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: fmt.Sprintf("An order can contain at most %d items", utils.MAX_ORDER_ITEMS),
+		})
+	}
 
-	// Validate each order item
-	for i, item := range order.OrderItems {
-		if item.ItemType != "book" && item.ItemType != "subscription" {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
-				Error: "Invalid item type. Must be 'book' or 'subscription'",
-			})
-		}
-		if item.ItemID == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
-				Error: "Item ID is required for all order items",
-			})
-		}
-		if item.Quantity <= 0 {
+	// items are checked against the catalog by the service, one error per item
+	for i := range order.OrderItems {
+		// Clear the OrderID as it will be set by the database after order creation
+		order.OrderItems[i].OrderID = 0
+	}
+
+	// the wilaya is optional for older clients, the quote then includes shipping
+	if order.Wilaya != 0 || order.DeliveryMethod != "" {
+		if err := services.ValidateDelivery(order.Wilaya, order.DeliveryMethod); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
-				Error: "Quantity must be greater than 0 for all order items",
+				Error: err.Error(),
 			})
 		}
-
-		// Clear the OrderID as it will be set by the database after order creation
-		order.OrderItems[i].OrderID = 0
 	}
 
 	// 	file, fileErr := c.FormFile("screenshot")
@@ -105,24 +117,80 @@ func (h OrderHandler) PostOrder(c *fiber.Ctx) error {
 	// 	}
 	// 	order.Screenshot = fileName
 
-	err := h.Service.CreateOrder(&order)
+	// a checkout submitted twice carries the same key, the first order is returned
+	order.IdempotencyKey = nil
+	if key := strings.TrimSpace(c.Get("Idempotency-Key")); key != "" {
+		if len(key) > maxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+				Error: fmt.Sprintf("Idempotency-Key can't be longer than %d characters", maxIdempotencyKeyLength),
+				Code:  "invalid_params",
+			})
+		}
+		order.IdempotencyKey = &key
+	}
+
+	created, err := h.Service.CreateOrder(&order)
 
+	var invalidItems *services.InvalidItemsError
+	if errors.As(err, &invalidItems) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Some items can't be ordered",
+			Code:  "invalid_items",
+			Data:  invalidItems.Items,
+		})
+	}
+	if errors.Is(err, services.ErrNoShippingRate) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.Response{
+			Error: err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrIdempotencyKeyReused) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "idempotency_key_reused",
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
 			Error: "Failed to create order: " + err.Error(),
 		})
 	}
 
-	notification.Send("New Order - "+order.Name, fmt.Sprint("Livres: ", len(order.OrderItems)))
+	if !created {
+		c.Set("Idempotent-Replayed", "true")
+		return h.sendCustomerView(c, fiber.StatusOK, &order)
+	}
 
-	// Reload the order with OrderItems to return complete data
-	createdOrder, err := h.Service.GetOrderByID(fmt.Sprintf("%d", order.ID))
-	if err != nil {
-		// Return the order anyway, but OrderItems might be empty
-		return c.Status(fiber.StatusCreated).JSON(order)
+	message := fmt.Sprint("Livres: ", len(order.OrderItems))
+	if unavailable := services.UnavailableItems(&order); len(unavailable) > 0 {
+		message += fmt.Sprint(", indisponibles: ", len(unavailable))
+	}
+	if order.DuplicateOfID != nil {
+		message += fmt.Sprintf(", doublon probable de la commande n°%d", *order.DuplicateOfID)
+	}
+	notification.Send("New Order - "+order.Name, message)
+
+	// the customer follows the order with the link, the email lookup is for admins
+	if err := h.Service.SendTrackingLink(&order); err != nil {
+		utils.Report(fmt.Sprintf("Can't email the tracking link of order %d: %s", order.ID, err.Error()))
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(createdOrder)
+	// the order was created with its items, reloading it would also look up
+	// its probable duplicates, which only admins may see
+	return h.sendCustomerView(c, fiber.StatusCreated, &order)
+}
+
+// sendCustomerView answers the checkout with what the customer may see of
+// their order, the prices come with the quote once an admin reviewed them.
+func (h OrderHandler) sendCustomerView(c *fiber.Ctx, status int, order *models.Order) error {
+	view, err := h.Service.CustomerView(order)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to retrieve the order: " + err.Error(),
+		})
+	}
+	view.TrackingURL = services.TrackingURL(order.TrackingToken)
+	return c.Status(status).JSON(view)
 }
 
 func (h OrderHandler) GetOrderByID(c *fiber.Ctx) error {
@@ -138,15 +206,177 @@ func (h OrderHandler) GetOrderByID(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(order)
 }
 
+// GetAllOrders lists the orders for the admin dashboard:
+// ?page=1&limit=50 or ?cursor=...&limit=50, ?sort=-created_at (created_at,
+// updated_at, total, status or name), filters status=confirmed,shipped,
+// from=2025-01-01, to=2025-01-31, email, phone, item_id, q searching the
+// name and the address, ids=1,2,3 and purchase, the ID of a purchase batch.
 func (h OrderHandler) GetAllOrders(c *fiber.Ctx) error {
-	orders, err := h.Service.GetAllOrders()
+	query, err := orderQueryFromRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_params",
+		})
+	}
+
+	page, err := h.Service.ListOrders(query)
+	if errors.Is(err, services.ErrInvalidOrderQuery) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_params",
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
 			Error: "Failed to retrieve orders",
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(orders)
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: page,
+	})
+}
+
+// ExportOrders downloads the orders matching the filters of GetAllOrders as
+// ?format=csv or xlsx, with the columns of ?preset=orders, items, supplier
+// (books to buy, grouped) or courier (delivery manifest). The file is
+// streamed, exports aren't paginated.
+func (h OrderHandler) ExportOrders(c *fiber.Ctx) error {
+	query, err := orderQueryFromRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_params",
+		})
+	}
+
+	preset := c.Query("preset", services.EXPORT_ORDERS)
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		err = errors.New("unknown export format, expected csv or xlsx")
+	} else {
+		err = services.CheckExport(query, preset)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_params",
+		})
+	}
+
+	fileName := fmt.Sprintf("commandes-%s-%s.%s", preset, time.Now().Format(time.DateOnly), format)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+fileName+`"`)
+	if format == "xlsx" {
+		c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	} else {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var out sheet.Writer
+		var err error
+		if format == "xlsx" {
+			out, err = sheet.NewXLSX(w, "Commandes")
+		} else {
+			out, err = sheet.NewCSV(w)
+		}
+		if err == nil {
+			err = h.Service.ExportOrders(query, preset, out)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			utils.Report("Can't export the orders: " + err.Error())
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// orderQueryFromRequest reads the filters, sort and pagination of the order
+// list from the query string.
+func orderQueryFromRequest(c *fiber.Ctx) (services.OrderQuery, error) {
+	query := services.OrderQuery{
+		Page:   c.QueryInt("page", 1),
+		Limit:  c.QueryInt("limit", defaultOrderListLimit),
+		Cursor: c.Query("cursor"),
+		Email:  c.Query("email"),
+		Phone:  c.Query("phone"),
+		ItemID: c.Query("item_id"),
+		Search: c.Query("q"),
+		Sort:   c.Query("sort"),
+	}
+	query.Limit = min(query.Limit, maxOrderListLimit)
+
+	for _, status := range strings.Split(c.Query("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+
+	for _, id := range strings.Split(c.Query("ids"), ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		parsed, err := strconv.ParseUint(id, 10, 0)
+		if err != nil {
+			return query, errors.New("invalid order ID in ids: " + id)
+		}
+		query.IDs = append(query.IDs, uint(parsed))
+	}
+	if purchase := c.QueryInt("purchase"); purchase > 0 {
+		query.Purchase = uint(purchase)
+	}
+
+	var err error
+	if query.From, err = parseDateQuery(c.Query("from"), false); err == nil {
+		query.To, err = parseDateQuery(c.Query("to"), true)
+	}
+	if err != nil {
+		return query, errors.New("invalid date, expected YYYY-MM-DD or RFC 3339: " + err.Error())
+	}
+	return query, nil
+}
+
+// parseDateQuery reads a date of a query string, a day given as the end of a
+// range includes the whole day.
+func parseDateQuery(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// GetTracking is the customer's view of an order, from the tracking link
+// emailed when it was placed.
+func (h OrderHandler) GetTracking(c *fiber.Ctx) error {
+	tracking, err := h.Service.TrackOrder(c.Params("token"))
+	if errors.Is(err, services.ErrTrackingNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "not_found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to retrieve the order: " + err.Error(),
+			Code:  "error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: tracking,
+	})
 }
 
 func (h OrderHandler) GetOrdersByEmail(c *fiber.Ctx) error {
@@ -168,6 +398,9 @@ func (h OrderHandler) GetOrdersByEmail(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(orders)
 }
 
+// SetOrderStatus moves an order to the next status of its lifecycle, see
+// services.OrderTransitions. An optional {"comment": "..."} body is kept in
+// the order history.
 func (h OrderHandler) SetOrderStatus(c *fiber.Ctx) error {
 	id := c.Params("id")
 	status := c.Params("status")
@@ -178,26 +411,66 @@ func (h OrderHandler) SetOrderStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	o := NewOrderHandler()
-	err := o.Service.SetOrderStatus(id, status)
+	var body orderComment
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+				Error: "Invalid JSON format: " + err.Error(),
+				Code:  "invalid_params",
+			})
+		}
+	}
 
-	if err != nil {
-		return err
+	order, err := h.Service.SetOrderStatus(id, status, fmt.Sprint(c.Locals("adminID")), body.Comment)
+
+	switch {
+	case errors.Is(err, services.ErrUnknownOrderStatus):
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error() + ": " + status,
+			Code:  "invalid_params",
+		})
+	case errors.Is(err, services.ErrOrderNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: "Order not found",
+			Code:  "not_found",
+		})
+	case errors.Is(err, services.ErrIllegalTransition):
+		return c.Status(fiber.StatusConflict).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "illegal_transition",
+			Data: fiber.Map{
+				"status":  order.Status,
+				"allowed": services.OrderTransitions[order.Status],
+			},
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to update the order status: " + err.Error(),
+		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(models.Response{
 		Code: "success",
 		Data: fiber.Map{
 			"message": "Order status updated successfully",
+			"status":  order.Status,
 		},
 	})
 }
 
+// GetOrderStatuses lists the statuses and the moves allowed from each.
+func (h OrderHandler) GetOrderStatuses(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: services.OrderTransitions,
+	})
+}
+
 func (h OrderHandler) DeleteOrder(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	// Call the service to delete the order
-	err := h.Service.DeleteOrder(id)
+	err := h.Service.DeleteOrder(id, fmt.Sprint(c.Locals("adminID")))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
 			Error: "Failed to delete order: " + err.Error(),
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/models"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultPriceHistoryLimit = 100
+	maxPriceHistoryLimit     = 1000
+)
+
+type PriceHistoryHandler struct {
+	Service *services.PriceHistoryService
+}
+
+func NewPriceHistoryHandler() PriceHistoryHandler {
+	return PriceHistoryHandler{
+		Service: services.NewPriceHistoryService(),
+	}
+}
+
+// GetPriceHistory lists the prices and stock statuses observed for a book,
+// latest first: ?provider=amazon&limit=100. Provider prices are in euros.
+func (h PriceHistoryHandler) GetPriceHistory(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultPriceHistoryLimit)
+	if limit < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid 'limit' query parameter",
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	observations, err := h.Service.History(c.Params("id"), c.Query("provider"), min(limit, maxPriceHistoryLimit))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to load the price history: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  observations,
+	})
+}
+
+// GetPriceAlerts lists the open price alerts, ?all=true includes the
+// acknowledged ones.
+func (h PriceHistoryHandler) GetPriceAlerts(c *fiber.Ctx) error {
+	alerts, err := h.Service.Alerts(c.QueryBool("all"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to load the price alerts: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  alerts,
+	})
+}
+
+func (h PriceHistoryHandler) AcknowledgePriceAlert(c *fiber.Ctx) error {
+	alert, err := h.Service.AcknowledgeAlert(c.Params("id"), fmt.Sprint(c.Locals("adminID")))
+	if errors.Is(err, services.ErrPriceAlertNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "not_found",
+			Data:  nil,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to acknowledge the price alert: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  alert,
+	})
+}
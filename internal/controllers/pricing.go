@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"amazon/internal/scrapers/books"
+	"amazon/internal/services"
+	"amazon/models"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PricingHandler struct {
+	Service *services.PricingService
+}
+
+func NewPricingHandler() PricingHandler {
+	return PricingHandler{
+		Service: services.NewPricingService(),
+	}
+}
+
+// GetPreview shows what a book would cost: ?book=<id> prices a book at its
+// provider price, ?price=12.50 prices any euro amount. ?category= (book by
+// default) selects category margins and fees, ?version= previews older rules.
+func (h PricingHandler) GetPreview(c *fiber.Ctx) error {
+	category := c.Query("category", "book")
+
+	rules, err := h.Service.CurrentRules()
+	if version := c.QueryInt("version"); err == nil && version > 0 {
+		rules, err = h.Service.GetRules(version)
+	}
+	if errors.Is(err, services.ErrPricingRulesNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "not_found",
+			Data:  nil,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to load the pricing rules: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	var book *models.Book
+	var basePrice float64
+
+	if id := c.Query("book"); id != "" {
+		var errCode string
+		book, errCode, err = books.FetchBook(id)
+		if err != nil {
+			status := fiber.StatusInternalServerError
+			if errCode == "not_found" {
+				status = fiber.StatusNotFound
+			}
+			return c.Status(status).JSON(models.Response{
+				Error: err.Error(),
+				Code:  errCode,
+				Data:  nil,
+			})
+		}
+		basePrice = float64(book.Price)
+	} else {
+		basePrice, err = strconv.ParseFloat(c.Query("price"), 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+				Error: "Missing 'book' or invalid 'price' query parameter",
+				Code:  "invalid_params",
+				Data:  nil,
+			})
+		}
+	}
+
+	breakdown, err := h.Service.PriceWithRules(*rules, basePrice, category)
+	if errors.Is(err, services.ErrPriceUnavailable) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "price_unavailable",
+			Data:  nil,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to compute the price: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	data := fiber.Map{"breakdown": breakdown}
+	if book != nil {
+		data["book"] = fiber.Map{"id": book.ID, "title": book.Title}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: data,
+	})
+}
+
+func (h PricingHandler) GetRules(c *fiber.Ctx) error {
+	rules, err := h.Service.CurrentRules()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to load the pricing rules: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: rules,
+	})
+}
+
+func (h PricingHandler) GetRulesHistory(c *fiber.Ctx) error {
+	history, err := h.Service.RulesHistory()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to load the pricing rules: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: history,
+	})
+}
+
+func (h PricingHandler) GetRulesVersion(c *fiber.Ctx) error {
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid version",
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	rules, err := h.Service.GetRules(version)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrPricingRulesNotFound) {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "not_found",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Code: "success",
+		Data: rules,
+	})
+}
+
+// PostRules saves the body as the new version of the rules.
+func (h PricingHandler) PostRules(c *fiber.Ctx) error {
+	var rules models.PricingRuleSet
+	if err := c.BodyParser(&rules); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid JSON format: " + err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	if err := services.ValidatePricingRules(&rules); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_rules",
+			Data:  nil,
+		})
+	}
+
+	if err := h.Service.SaveRules(&rules, fmt.Sprint(c.Locals("adminID"))); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to save the pricing rules: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.Response{
+		Code: "success",
+		Data: rules,
+	})
+}
+
+// PostRestoreRules makes an older version current again.
+func (h PricingHandler) PostRestoreRules(c *fiber.Ctx) error {
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid version",
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	rules, err := h.Service.RestoreRules(version, fmt.Sprint(c.Locals("adminID")))
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrPricingRulesNotFound) {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.Response{
+		Code: "success",
+		Data: rules,
+	})
+}
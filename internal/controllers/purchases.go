@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/models"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PurchaseHandler struct {
+	Service *services.PurchaseService
+}
+
+func NewPurchaseHandler() PurchaseHandler {
+	return PurchaseHandler{
+		Service: services.NewPurchaseService(),
+	}
+}
+
+type purchaseBatchRequest struct {
+	Provider string `json:"provider"` // every provider when empty
+}
+
+// GetPurchases lists the purchase batches, newest first, ?status=draft to
+// see only those in a status.
+func (h PurchaseHandler) GetPurchases(c *fiber.Ctx) error {
+	batches, err := h.Service.ListPurchases(c.Query("status"))
+	if err != nil {
+		return purchaseError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  batches,
+	})
+}
+
+// PostPurchases gathers the books of the confirmed orders into draft
+// batches, one per provider: {"provider": "amazon"} to batch only its books.
+func (h PurchaseHandler) PostPurchases(c *fiber.Ctx) error {
+	var body purchaseBatchRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+				Error: "Invalid JSON format: " + err.Error(),
+				Code:  "invalid_params",
+				Data:  nil,
+			})
+		}
+	}
+
+	batches, err := h.Service.BatchConfirmedOrders(body.Provider, fmt.Sprint(c.Locals("adminID")))
+	if err != nil {
+		return purchaseError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  batches,
+	})
+}
+
+func (h PurchaseHandler) GetPurchase(c *fiber.Ctx) error {
+	batch, err := h.Service.GetPurchase(c.Params("id"))
+	if err != nil {
+		return purchaseError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  batch,
+	})
+}
+
+// PutPurchase fills in a batch: {"cost": 123.4, "reference": "...", "note": "..."},
+// the cost in EUR.
+func (h PurchaseHandler) PutPurchase(c *fiber.Ctx) error {
+	var changes services.PurchaseChanges
+	if err := c.BodyParser(&changes); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid JSON format: " + err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	batch, err := h.Service.UpdatePurchase(c.Params("id"), changes)
+	if err != nil {
+		return purchaseError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  batch,
+	})
+}
+
+// SetPurchaseStatus places or receives a batch, moving its orders along.
+func (h PurchaseHandler) SetPurchaseStatus(c *fiber.Ctx) error {
+	batch, err := h.Service.SetPurchaseStatus(c.Params("id"), c.Params("status"), fmt.Sprint(c.Locals("adminID")))
+	if errors.Is(err, services.ErrIllegalPurchaseChange) {
+		return c.Status(fiber.StatusConflict).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "illegal_transition",
+			Data: fiber.Map{
+				"status":  batch.Status,
+				"allowed": services.PurchaseTransitions[batch.Status],
+			},
+		})
+	}
+	if err != nil {
+		return purchaseError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  batch,
+	})
+}
+
+func (h PurchaseHandler) DeletePurchase(c *fiber.Ctx) error {
+	if err := h.Service.DeletePurchase(c.Params("id")); err != nil {
+		return purchaseError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  fiber.Map{"message": "Purchase batch deleted successfully"},
+	})
+}
+
+func purchaseError(c *fiber.Ctx, err error) error {
+	status, code := fiber.StatusInternalServerError, "error"
+	switch {
+	case errors.Is(err, services.ErrPurchaseNotFound):
+		status, code = fiber.StatusNotFound, "not_found"
+	case errors.Is(err, services.ErrUnknownPurchaseStatus), errors.Is(err, services.ErrUnknownPurchaseProvider),
+		errors.Is(err, services.ErrInvalidPurchaseCost):
+		status, code = fiber.StatusBadRequest, "invalid_params"
+	case errors.Is(err, services.ErrPurchaseNotDraft), errors.Is(err, services.ErrEmptyPurchase):
+		status, code = fiber.StatusConflict, "illegal_transition"
+	case errors.Is(err, services.ErrNothingToPurchase):
+		status, code = fiber.StatusUnprocessableEntity, "nothing_to_purchase"
+	}
+
+	return c.Status(status).JSON(models.Response{
+		Error: err.Error(),
+		Code:  code,
+		Data:  nil,
+	})
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/mail"
+	"amazon/models"
+	"amazon/notification"
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type QuoteHandler struct {
+	Service *services.QuoteService
+}
+
+func NewQuoteHandler() QuoteHandler {
+	return QuoteHandler{
+		Service: services.NewQuoteService(),
+	}
+}
+
+type quoteUpdate struct {
+	Items []struct {
+		ID        uint    `json:"id"`
+		UnitPrice float64 `json:"unit_price"`
+	} `json:"items"`
+	ShippingCost *float64 `json:"shipping_cost"`
+	Fees         *float64 `json:"fees"`
+	Note         *string  `json:"note"`
+}
+
+// UpdateQuote adjusts the prices of an order before the quote is sent:
+// {"items": [{"id": 3, "unit_price": 2450}], "shipping_cost": 600, "fees": 0, "note": "..."}.
+func (h QuoteHandler) UpdateQuote(c *fiber.Ctx) error {
+	var body quoteUpdate
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid JSON format: " + err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	prices := make(map[uint]float64, len(body.Items))
+	for _, item := range body.Items {
+		prices[item.ID] = item.UnitPrice
+	}
+
+	order, err := h.Service.UpdateQuote(c.Params("id"), services.QuoteChanges{
+		Prices:       prices,
+		ShippingCost: body.ShippingCost,
+		Fees:         body.Fees,
+		Note:         body.Note,
+	}, fmt.Sprint(c.Locals("adminID")))
+	if err != nil {
+		return quoteError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  order,
+	})
+}
+
+// SendQuote emails the quote to the customer.
+func (h QuoteHandler) SendQuote(c *fiber.Ctx) error {
+	order, err := h.Service.SendQuote(c.Params("id"), fmt.Sprint(c.Locals("adminID")))
+	if err != nil {
+		return quoteError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  order,
+	})
+}
+
+// GetQuote is the page the quote email links to. Accepting takes a POST so
+// mail scanners opening the link don't accept on the customer's behalf.
+func (h QuoteHandler) GetQuote(c *fiber.Ctx) error {
+	order, err := h.Service.GetQuote(c.Params("token"))
+	if err != nil {
+		return quotePageError(c, err)
+	}
+
+	data := fiber.Map{
+		"Order":    order,
+		"Lines":    h.Service.QuoteLines(order),
+		"Total":    services.FormatDZD(order.QuoteTotal),
+		"Accepted": order.QuoteAcceptedAt != nil,
+		"Open":     order.Status == models.ORDER_STATUS_QUOTED && !services.QuoteExpired(order, time.Now()),
+	}
+	return renderQuotePage(c, fiber.StatusOK, quotePageTemplate, data)
+}
+
+func (h QuoteHandler) AcceptQuote(c *fiber.Ctx) error {
+	order, err := h.Service.AcceptQuote(c.Params("token"))
+	if err != nil {
+		return quotePageError(c, err)
+	}
+
+	notification.Send("Quote accepted - "+order.Name, fmt.Sprintf("Commande n°%d : %s", order.ID, services.FormatDZD(order.QuoteTotal)))
+
+	return renderQuotePage(c, fiber.StatusOK, quoteMessageTemplate, fiber.Map{
+		"Message": fmt.Sprintf("Merci, votre commande n°%d est confirmée. Nous vous contacterons pour la livraison.", order.ID),
+	})
+}
+
+func quoteError(c *fiber.Ctx, err error) error {
+	status, code := fiber.StatusBadRequest, "invalid_params"
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		status, code = fiber.StatusNotFound, "not_found"
+	case errors.Is(err, services.ErrQuoteState), errors.Is(err, services.ErrQuoteIncomplete):
+		status, code = fiber.StatusConflict, "conflict"
+	case errors.Is(err, mail.ErrDisabled):
+		status, code = fiber.StatusServiceUnavailable, "mail_disabled"
+	}
+
+	return c.Status(status).JSON(models.Response{
+		Error: err.Error(),
+		Code:  code,
+		Data:  nil,
+	})
+}
+
+// quotePageError answers customers in their language, they open these pages
+// from the email.
+func quotePageError(c *fiber.Ctx, err error) error {
+	status, message := fiber.StatusInternalServerError, "Une erreur est survenue, veuillez réessayer plus tard."
+	switch {
+	case errors.Is(err, services.ErrQuoteNotFound):
+		status, message = fiber.StatusNotFound, "Ce devis n'existe pas ou a été remplacé par un devis plus récent."
+	case errors.Is(err, services.ErrQuoteExpired):
+		status, message = fiber.StatusGone, "Ce devis a expiré, contactez-nous pour en recevoir un nouveau."
+	case errors.Is(err, services.ErrQuoteState):
+		status, message = fiber.StatusConflict, "Ce devis ne peut plus être accepté."
+	}
+
+	return renderQuotePage(c, status, quoteMessageTemplate, fiber.Map{"Message": message})
+}
+
+func renderQuotePage(c *fiber.Ctx, status int, page *template.Template, data fiber.Map) error {
+	var html bytes.Buffer
+	if err := page.Execute(&html, data); err != nil {
+		return err
+	}
+	return c.Status(status).Type("html").Send(html.Bytes())
+}
+
+var quotePageTemplate = template.Must(template.New("quote").Parse(`<!DOCTYPE html>
+<html lang="fr"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Devis n°{{.Order.ID}}</title></head>
+<body style="font-family: sans-serif; max-width: 640px; margin: 2em auto">
+<h1>Devis de la commande n°{{.Order.ID}}</h1>
+<table cellpadding="6" style="border-collapse: collapse; width: 100%">
+{{- range .Lines}}
+<tr><td>{{.Title}}</td><td>{{.Quantity}} × {{.UnitPrice}}</td><td align="right">{{.Total}}</td></tr>
+{{- end}}
+<tr><td colspan="2"><strong>Total</strong></td><td align="right"><strong>{{.Total}}</strong></td></tr>
+</table>
+{{- if .Order.QuoteNote}}
+<p>{{.Order.QuoteNote}}</p>
+{{- end}}
+{{- if .Accepted}}
+<p>Vous avez accepté ce devis, votre commande est confirmée.</p>
+{{- else if .Open}}
+<form method="post" action="{{.Order.QuoteToken}}/accept"><button type="submit">Accepter le devis</button></form>
+{{- else}}
+<p>Ce devis a expiré, contactez-nous pour en recevoir un nouveau.</p>
+{{- end}}
+</body></html>
+`))
+
+var quoteMessageTemplate = template.Must(template.New("message").Parse(`<!DOCTYPE html>
+<html lang="fr"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Devis</title></head>
+<body style="font-family: sans-serif; max-width: 640px; margin: 2em auto">
+<p>{{.Message}}</p>
+</body></html>
+`))
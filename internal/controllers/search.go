@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/internal/utils"
+	"amazon/models"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultSuggestionCount = 8
+	maxSuggestionCount     = 20
+)
+
+type SearchHandler struct {
+	Service     *services.SearchService
+	Suggestions *services.SuggestService
+	Logs        *services.SearchLogService
+	Pricing     *services.PricingService
+}
+
+func NewSearchHandler() SearchHandler {
+	return SearchHandler{
+		Service:     services.NewSearchService(),
+		Suggestions: services.NewSuggestService(),
+		Logs:        services.NewSearchLogService(),
+		Pricing:     services.NewPricingService(),
+	}
+}
+
+// Search serves /books/search?query=...&page=1, merging the local index,
+// Lireka, Amazon and Google Books. Only Amazon paginates, later pages come
+// from it alone. Results are narrowed with facet parameters, repeated to
+// select several values: &lang=fr&lang=en&price=2000-4000&year=2024.
+func (h SearchHandler) Search(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("query"))
+	if query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Missing 'query' query parameter",
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Missing or invalid 'page' query parameter",
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	descFormat, ok := descriptionFormat(c)
+	if !ok {
+		return invalidDescriptionFormat(c)
+	}
+
+	filters, err := searchFilters(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	startedAt := time.Now()
+	result := h.Service.Search(query, page)
+
+	// fiber reuses the request strings once the handler returns
+	logged, latency, ip, searched := result, time.Since(startedAt), strings.Clone(c.IP()), strings.Clone(query)
+	go func() {
+		if err := h.Logs.Record(searched, page, ip, logged, latency); err != nil {
+			utils.Report("Can't record the search: " + err.Error())
+		}
+	}()
+
+	if len(result.Books) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Error: "No search results for " + query,
+			Code:  "empty_page",
+			Data:  nil,
+		})
+	}
+
+	// books are grouped by the price customers pay, without it there is no
+	// price facet
+	if err := h.Pricing.PriceSearchHits(result.Books); err != nil {
+		utils.Report("Can't price the search results: " + err.Error())
+	}
+
+	// an empty selection still answers with the facets to widen it again
+	result.Books, result.Facets = services.FacetSearchHits(result.Books, filters)
+
+	for i := range result.Books {
+		hit := &result.Books[i]
+		hit.Cover = services.CoverProxyURL(hit.Cover, utils.COVER_IMG_SIZE)
+		hit.ShortDescription = utils.ShortDescription(hit.Description, utils.SHORT_DESCRIPTION_LENGTH)
+		hit.Description = utils.RenderDescription(hit.Description, descFormat)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  result,
+	})
+}
+
+// Suggest serves /books/suggest?q=...&limit=8 for the search box. It only
+// reads memory, so it can be called on every keystroke.
+func (h SearchHandler) Suggest(c *fiber.Ctx) error {
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(defaultSuggestionCount)))
+	if err != nil || limit < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid 'limit' query parameter",
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	suggestions := h.Suggestions.Suggest(c.Query("q"), min(limit, maxSuggestionCount))
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  suggestions,
+	})
+}
+
+// searchFilters reads the facet filters of a search. The language facet is
+// read from ?lang= like the other listings and accepts language names too.
+func searchFilters(c *fiber.Ctx) (services.SearchFilters, error) {
+	filters := make(services.SearchFilters)
+
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		name := string(key)
+		if name == "lang" {
+			name = services.SEARCH_FACET_LANGUAGE
+		}
+
+		if services.IsSearchFacet(name) && len(value) > 0 {
+			filters[name] = append(filters[name], string(value))
+		}
+	})
+
+	for i, language := range filters[services.SEARCH_FACET_LANGUAGE] {
+		code := utils.NormalizeLanguage(language)
+		if code == "" {
+			return nil, errors.New("Invalid 'lang' query parameter, expected an ISO 639-1 code such as fr, en or ar")
+		}
+		filters[services.SEARCH_FACET_LANGUAGE][i] = code
+	}
+
+	for name, values := range filters {
+		if err := services.ValidateSearchFilter(name, values); err != nil {
+			return nil, err
+		}
+	}
+
+	return filters, nil
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultStatsDays  = 30
+	maxStatsDays      = 365
+	defaultStatsLimit = 50
+)
+
+type SearchStatsHandler struct {
+	Service *services.SearchLogService
+}
+
+func NewSearchStatsHandler() SearchStatsHandler {
+	return SearchStatsHandler{
+		Service: services.NewSearchLogService(),
+	}
+}
+
+// GetTopQueries lists the most searched queries of the last ?days=30.
+func (h SearchStatsHandler) GetTopQueries(c *fiber.Ctx) error {
+	since, limit, ok := statsWindow(c)
+	if !ok {
+		return invalidStatsWindow(c)
+	}
+
+	queries, err := h.Service.TopQueries(since, limit)
+	return sendStats(c, queries, err)
+}
+
+// GetZeroResultQueries lists the queries of the last ?days=30 that found
+// nothing, most searched first.
+func (h SearchStatsHandler) GetZeroResultQueries(c *fiber.Ctx) error {
+	since, limit, ok := statsWindow(c)
+	if !ok {
+		return invalidStatsWindow(c)
+	}
+
+	queries, err := h.Service.ZeroResultQueries(since, limit)
+	return sendStats(c, queries, err)
+}
+
+// GetTrends counts the searches per ?interval=day|week over the last ?days=30,
+// optionally for a single ?query=.
+func (h SearchStatsHandler) GetTrends(c *fiber.Ctx) error {
+	since, _, ok := statsWindow(c)
+	if !ok {
+		return invalidStatsWindow(c)
+	}
+
+	trends, err := h.Service.Trends(since, c.Query("interval", services.SEARCH_TREND_DAY), c.Query("query"))
+	if err == services.ErrInvalidTrendInterval {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	return sendStats(c, trends, err)
+}
+
+// GetProviderStats shows how each search provider answered over the last
+// ?days=30: failures, timeouts and latency.
+func (h SearchStatsHandler) GetProviderStats(c *fiber.Ctx) error {
+	since, _, ok := statsWindow(c)
+	if !ok {
+		return invalidStatsWindow(c)
+	}
+
+	stats, err := h.Service.ProviderStats(since)
+	return sendStats(c, stats, err)
+}
+
+// statsWindow reads ?days= and ?limit=.
+func statsWindow(c *fiber.Ctx) (time.Time, int, bool) {
+	days := c.QueryInt("days", defaultStatsDays)
+	limit := c.QueryInt("limit", defaultStatsLimit)
+	if days < 1 || days > maxStatsDays || limit < 1 {
+		return time.Time{}, 0, false
+	}
+
+	return time.Now().AddDate(0, 0, -days), limit, true
+}
+
+func invalidStatsWindow(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+		Error: "Invalid 'days' or 'limit' query parameter, days must be between 1 and 365",
+		Code:  "invalid_params",
+		Data:  nil,
+	})
+}
+
+func sendStats(c *fiber.Ctx, stats any, err error) error {
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Error: "Failed to compute search statistics: " + err.Error(),
+			Code:  "error",
+			Data:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  stats,
+	})
+}
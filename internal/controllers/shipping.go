@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"amazon/internal/services"
+	"amazon/internal/utils"
+	"amazon/models"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ShippingHandler struct {
+	Service *services.ShippingService
+}
+
+func NewShippingHandler() ShippingHandler {
+	return ShippingHandler{
+		Service: services.NewShippingService(),
+	}
+}
+
+type shippingQuoteRequest struct {
+	Wilaya int                `json:"wilaya"`
+	Method string             `json:"method"` // every method serving the wilaya when empty
+	Items  []models.OrderItem `json:"items"`
+}
+
+// GetWilayas lists the wilayas for the checkout form.
+func (h ShippingHandler) GetWilayas(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  services.Wilayas(),
+	})
+}
+
+// PostQuote computes the delivery of a cart:
+// {"wilaya": 16, "method": "home", "items": [{"itemType": "book", "itemId": "...", "quantity": 1}]}.
+// It answers with one quote per method, only the requested one when given.
+func (h ShippingHandler) PostQuote(c *fiber.Ctx) error {
+	var body shippingQuoteRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid JSON format: " + err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	if len(body.Items) > utils.MAX_QUOTED_ITEMS {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: fmt.Sprintf("At most %d items can be quoted at once", utils.MAX_QUOTED_ITEMS),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+	for _, item := range body.Items {
+		if item.Quantity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+				Error: "Quantity must be greater than 0 for all items",
+				Code:  "invalid_params",
+				Data:  nil,
+			})
+		}
+	}
+
+	var quotes []models.ShippingQuote
+	var err error
+	if body.Method == "" {
+		if services.WilayaName(body.Wilaya) == "" {
+			err = services.ErrUnknownWilaya
+		} else {
+			quotes, err = h.Service.QuoteAll(body.Wilaya, body.Items)
+		}
+	} else {
+		var quote *models.ShippingQuote
+		if quote, err = h.Service.Quote(body.Wilaya, body.Method, body.Items); err == nil {
+			quotes = []models.ShippingQuote{*quote}
+		}
+	}
+
+	if err != nil {
+		return shippingError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  quotes,
+	})
+}
+
+func (h ShippingHandler) GetRates(c *fiber.Ctx) error {
+	rates, err := h.Service.Rates()
+	if err != nil {
+		return shippingError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  rates,
+	})
+}
+
+// PutRates creates or replaces rates, keyed by wilaya and method. Wilaya 0
+// holds the rates of the wilayas without their own.
+func (h ShippingHandler) PutRates(c *fiber.Ctx) error {
+	var rates []models.ShippingRate
+	if err := c.BodyParser(&rates); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: "Invalid JSON format, expected a list of rates: " + err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	if err := h.Service.SaveRates(rates, fmt.Sprint(c.Locals("adminID"))); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Error: err.Error(),
+			Code:  "invalid_params",
+			Data:  nil,
+		})
+	}
+
+	return h.GetRates(c)
+}
+
+func (h ShippingHandler) DeleteRate(c *fiber.Ctx) error {
+	if err := h.Service.DeleteRate(c.Params("id")); err != nil {
+		return shippingError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.Response{
+		Error: "",
+		Code:  "success",
+		Data:  fiber.Map{"message": "Shipping rate deleted successfully"},
+	})
+}
+
+func shippingError(c *fiber.Ctx, err error) error {
+	status, code := fiber.StatusInternalServerError, "error"
+	switch {
+	case errors.Is(err, services.ErrUnknownWilaya), errors.Is(err, services.ErrUnknownDeliveryMethod):
+		status, code = fiber.StatusBadRequest, "invalid_params"
+	case errors.Is(err, services.ErrNoShippingRate):
+		status, code = fiber.StatusUnprocessableEntity, "no_shipping_rate"
+	case errors.Is(err, services.ErrShippingRateNotFound):
+		status, code = fiber.StatusNotFound, "not_found"
+	}
+
+	return c.Status(status).JSON(models.Response{
+		Error: err.Error(),
+		Code:  code,
+		Data:  nil,
+	})
+}
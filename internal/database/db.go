@@ -5,9 +5,11 @@ import (
 	"amazon/models"
 	"fmt"
 	"os"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var DB *gorm.DB
@@ -28,11 +30,94 @@ func ConnectDB() *gorm.DB {
 	return DB
 }
 
+// Models are the tables of the database, the tests migrate them too.
+var Models = []any{
+	&models.Order{},
+	&models.OrderItem{},
+	&models.Admin{},
+	&models.Email{},
+	&models.SearchLog{},
+	&models.SearchLogProvider{},
+	&models.PricingRuleSet{},
+	&models.ExchangeRate{},
+	&models.PriceObservation{},
+	&models.PriceAlert{},
+	&models.ShippingRate{},
+	&models.OrderEvent{},
+	&models.PurchaseBatch{},
+	&models.Migration{},
+}
+
 func Migrate() {
-	DB.AutoMigrate(&models.Order{})
-	DB.AutoMigrate(&models.OrderItem{}) // Add OrderItem migration
-	DB.AutoMigrate(&models.Admin{})
-	DB.AutoMigrate(&models.Email{})
+	for _, model := range Models {
+		DB.AutoMigrate(model)
+	}
+
+	// seed the historical rate so prices never lack one
+	var rates int64
+	DB.Model(&models.ExchangeRate{}).Count(&rates)
+	if rates == 0 {
+		DB.Create(&models.ExchangeRate{
+			Currency:    utils.DEFAULT_CURRENCY,
+			Rate:        utils.DEFAULT_EXCHANGE_RATE,
+			EffectiveAt: time.Time{},
+			Source:      "default",
+			Note:        "Rate used before the exchange rate table",
+		})
+	}
+
+	// default shipping rates, every wilaya uses them until given its own
+	var shippingRates int64
+	DB.Model(&models.ShippingRate{}).Count(&shippingRates)
+	if shippingRates == 0 {
+		DB.Create(&[]models.ShippingRate{
+			{Method: models.DELIVERY_HOME, Price: 800, IncludedWeight: 1, ExtraKgPrice: 100},
+			{Method: models.DELIVERY_STOP_DESK, Price: 500, IncludedWeight: 1, ExtraKgPrice: 100},
+		})
+	}
+
+	// orders placed before the quote workflow still await their quote
+	runOnce("awaiting_quote_status", func(tx *gorm.DB) error {
+		return tx.Model(&models.Order{}).Where("status = ?", "new").Update("status", models.ORDER_STATUS_AWAITING_QUOTE).Error
+	})
+
+	// statuses admins wrote before the lifecycle are mapped to it, the unknown
+	// ones await a new quote
+	runOnce("legacy_order_statuses", func(tx *gorm.DB) error {
+		for legacy, status := range legacyOrderStatuses {
+			if err := tx.Model(&models.Order{}).Where("status = ?", legacy).Update("status", status).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Order{}).Where("status NOT IN ?", orderStatuses).Update("status", models.ORDER_STATUS_AWAITING_QUOTE).Error
+	})
+
+	// orders placed before tracking links get one, admins can share it
+	runOnce("tracking_tokens", func(tx *gorm.DB) error {
+		var untracked []models.Order
+		if err := tx.Select("id").Where("tracking_token = '' OR tracking_token IS NULL").Find(&untracked).Error; err != nil {
+			return err
+		}
+		for _, order := range untracked {
+			if err := tx.Model(&order).UpdateColumn("tracking_token", utils.RandomToken(24)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	// totals of the orders placed before they were stored, once: orders can
+	// legitimately total 0 since
+	runOnce("order_totals", func(tx *gorm.DB) error {
+		err := tx.Exec("UPDATE order_items SET line_total = unit_price * quantity WHERE line_total = 0 AND unit_price > 0").Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE orders SET
+			subtotal = (SELECT COALESCE(SUM(line_total), 0) FROM order_items WHERE order_items.order_id = orders.id),
+			total = (SELECT COALESCE(SUM(line_total), 0) FROM order_items WHERE order_items.order_id = orders.id) + fees + shipping_cost
+			WHERE total = 0`).Error
+	})
 
 	// add the default admin if it doesn't exist, checking by username
 	username := os.Getenv("ADMIN_USERNAME")
@@ -55,3 +140,38 @@ func Migrate() {
 		}
 	}
 }
+
+// runOnce applies a data migration the first time the server starts with it,
+// recorded in the migrations table. A failed migration is tried again at the
+// next start.
+// orderStatuses are the statuses of the order lifecycle.
+var orderStatuses = []string{
+	models.ORDER_STATUS_AWAITING_QUOTE, models.ORDER_STATUS_QUOTED, models.ORDER_STATUS_CONFIRMED,
+	models.ORDER_STATUS_ORDERED_FROM_SUPPLIER, models.ORDER_STATUS_RECEIVED, models.ORDER_STATUS_SHIPPED,
+	models.ORDER_STATUS_DELIVERED, models.ORDER_STATUS_CANCELLED, models.ORDER_STATUS_REFUNDED,
+}
+
+// legacyOrderStatuses maps the free statuses of the orders from before the
+// lifecycle to its closest status.
+var legacyOrderStatuses = map[string]string{
+	"pending":    models.ORDER_STATUS_AWAITING_QUOTE,
+	"processing": models.ORDER_STATUS_CONFIRMED,
+	"paid":       models.ORDER_STATUS_CONFIRMED,
+	"ordered":    models.ORDER_STATUS_ORDERED_FROM_SUPPLIER,
+	"sent":       models.ORDER_STATUS_SHIPPED,
+	"completed":  models.ORDER_STATUS_DELIVERED,
+	"canceled":   models.ORDER_STATUS_CANCELLED,
+}
+
+func runOnce(name string, migrate func(tx *gorm.DB) error) {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Migration{Name: name})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return migrate(tx)
+	})
+	if err != nil {
+		utils.Report("Failed to migrate " + name + ": " + err.Error())
+	}
+}
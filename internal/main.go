@@ -4,12 +4,16 @@ import (
 	"amazon/internal/database"
 	"amazon/internal/routes"
 	"amazon/internal/scrapers/books"
+	"amazon/internal/search"
+	"amazon/internal/services"
 	"amazon/internal/utils"
+	"amazon/mail"
 	"amazon/notification"
 	"fmt"
 	"log"
 	"os"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -32,8 +36,69 @@ func setup() {
 		ownerKey,
 	})
 
+	// quotes are emailed to customers, mails fail until a host is set
+	mail.Configure(mail.Config{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	})
+
+	// emailed links, QR codes and covers point there, customers can't use relative links
+	if err := services.SetPublicURL(os.Getenv("PUBLIC_URL")); err != nil {
+		if mail.Enabled() {
+			log.Fatal(err)
+		}
+		utils.Report("Links to the site are relative: " + err.Error())
+	}
+
 	database.ConnectDB()
 
+	// orders of a customer repeating their items within this are flagged as duplicates
+	if hours, err := strconv.Atoi(os.Getenv("DUPLICATE_ORDER_WINDOW_HOURS")); err == nil {
+		services.DuplicateOrderWindow = time.Duration(hours) * time.Hour
+	}
+
+	// opt-in archive of raw upstream responses, used to debug failed parses
+	retentionDays, _ := strconv.Atoi(os.Getenv("RAW_ARCHIVE_RETENTION_DAYS"))
+	maxEntries, _ := strconv.Atoi(os.Getenv("RAW_ARCHIVE_MAX_ENTRIES"))
+	err = utils.ConfigureArchive(
+		os.Getenv("RAW_ARCHIVE_DIR"),
+		time.Duration(retentionDays)*24*time.Hour,
+		maxEntries,
+	)
+	if err != nil {
+		utils.Report("Raw response archive disabled: " + err.Error())
+	}
+
+	// local full-text index over the cached books, first tier of the search
+	indexed, err := search.Books.LoadDirectory(utils.BOOKS_CACHE_DIRECTORY)
+	if err != nil {
+		utils.Report("Can't build the search index: " + err.Error())
+	}
+	utils.Report(fmt.Sprintf("Search index ready with %d books", indexed))
+
+	go func() {
+		for range time.Tick(utils.SEARCH_INDEX_REFRESH) {
+			if _, err := search.Books.LoadDirectory(utils.BOOKS_CACHE_DIRECTORY); err != nil {
+				utils.Report("Can't refresh the search index: " + err.Error())
+			}
+		}
+	}()
+
+	// keep the price history of every book read from a provider
+	priceHistory := services.NewPriceHistoryService()
+	books.PriceObserver = priceHistory.Observe
+
+	go func() {
+		for range time.Tick(utils.PRICE_REFRESH_INTERVAL) {
+			if _, err := priceHistory.RefreshQuotedBooks(); err != nil {
+				utils.Report("Can't refresh the prices of the quoted orders: " + err.Error())
+			}
+		}
+	}()
+
 	// make uploads directory if not exists
 	if _, err := os.Stat("uploads"); os.IsNotExist(err) {
 		err = os.Mkdir("uploads", 0755)
@@ -60,6 +125,8 @@ func main() {
 
 	app := fiber.New(fiber.Config{
 		BodyLimit: utils.MAX_FILE_SIZE,
+		// behind a reverse proxy the customer's address is in this header
+		ProxyHeader: os.Getenv("PROXY_HEADER"),
 	})
 
 	PORT := os.Getenv("PORT")
@@ -74,7 +141,7 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Content-Type,Authorization,Cookie",
+		AllowHeaders:     "Content-Type,Authorization,Cookie,Idempotency-Key",
 		AllowCredentials: true,
 	}))
 
@@ -83,6 +150,11 @@ func main() {
 	routes.RegisterEmailRoutes(app.Group("/emails"))
 	routes.RegisterAuthorRoutes(app.Group("/authors"))
 	routes.RegisterOrderRoutes(app.Group("/orders"))
+	routes.RegisterCoverRoutes(app.Group("/covers"))
+	routes.RegisterPricingRoutes(app.Group("/pricing"))
+	routes.RegisterShippingRoutes(app.Group("/shipping"))
+	routes.RegisterTrackingRoutes(app.Group("/track"))
+	routes.RegisterPurchaseRoutes(app.Group("/purchases"))
 
 	app.Get("/", func(client *fiber.Ctx) error {
 		return client.Status(200).Type("html").SendString(`<h1>Made by <a href="https://agency.codiha.com" style="color: royalblue">CODIHA</a> Agency.</h1>`)
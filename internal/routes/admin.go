@@ -19,13 +19,15 @@ func RequireAdminLogin(c *fiber.Ctx) error {
 		})
 	}
 
-	if valid, adminID, err := AdminService.IsValidAdmin(token); !valid || err != nil {
+	valid, adminID, err := AdminService.IsValidAdmin(token)
+	if !valid || err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": fmt.Sprintf("Unauthorized: %s", adminID),
 		})
 	}
 
-	// Token and admin are valid, proceed
+	// Token and admin are valid, proceed, handlers read the admin from locals
+	c.Locals("adminID", adminID)
 	return c.Next()
 }
 
@@ -38,6 +40,26 @@ func RegisterAdminRoutes(router fiber.Router) {
 
 	router.Get("/verify", adminHandler.VerifyToken)
 
+	var archiveHandler controllers.ArchiveHandler = controllers.NewArchiveHandler()
+
+	// raw upstream responses, registered before /:id so they are not shadowed
+	router.Get("/archive", RequireAdminLogin, archiveHandler.GetArchive)
+	router.Get("/archive/:id", RequireAdminLogin, archiveHandler.GetArchivedResponse)
+
+	var searchStatsHandler controllers.SearchStatsHandler = controllers.NewSearchStatsHandler()
+
+	// search analytics, ?days=30&limit=50
+	router.Get("/search/top", RequireAdminLogin, searchStatsHandler.GetTopQueries)
+	router.Get("/search/zero-results", RequireAdminLogin, searchStatsHandler.GetZeroResultQueries)
+	router.Get("/search/trends", RequireAdminLogin, searchStatsHandler.GetTrends)
+	router.Get("/search/providers", RequireAdminLogin, searchStatsHandler.GetProviderStats)
+
+	var priceHistoryHandler controllers.PriceHistoryHandler = controllers.NewPriceHistoryHandler()
+
+	// books of quoted orders that got more expensive, ?all=true
+	router.Get("/price-alerts", RequireAdminLogin, priceHistoryHandler.GetPriceAlerts)
+	router.Post("/price-alerts/:id/acknowledge", RequireAdminLogin, priceHistoryHandler.AcknowledgePriceAlert)
+
 	router.Get("/:id", RequireAdminLogin, adminHandler.GetAdminByID)
 	router.Get("/", RequireAdminLogin, adminHandler.GetAllAdmins)
 	router.Delete("/:id", RequireAdminLogin, adminHandler.DeleteAdmin)
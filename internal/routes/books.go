@@ -10,16 +10,24 @@ import (
 func RegisterBookRoutes(router fiber.Router) {
 
 	var bookHandler controllers.BookHandler = *controllers.NewBookHandler()
+	var searchHandler controllers.SearchHandler = controllers.NewSearchHandler()
+	var priceHistoryHandler controllers.PriceHistoryHandler = controllers.NewPriceHistoryHandler()
 
 	// domain.com/books
 	router.Get("/", bookHandler.GetBooks)
 
-	// domain.com/books/search
-	router.Get("/search", bookHandler.SearchLirekaBooks)
+	// domain.com/books/search?query=camus&page=1
+	router.Get("/search", searchHandler.Search)
+
+	// domain.com/books/suggest?q=one pi
+	router.Get("/suggest", searchHandler.Suggest)
 
 	// domain.com/books/book/alt
 	router.Get("/:id", bookHandler.GetBookByID)
 
 	router.Get("/:id/alt", bookHandler.GetGBookByID)
 
+	// prices stay hidden from customers, admins follow them here
+	router.Get("/:id/price-history", RequireAdminLogin, priceHistoryHandler.GetPriceHistory)
+
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"amazon/internal/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterCoverRoutes registers the cover image proxy to the provided router group.
+func RegisterCoverRoutes(router fiber.Router) {
+
+	var coverHandler controllers.CoverHandler = controllers.NewCoverHandler()
+
+	// domain.com/covers/amazon/91PYK8yKFXL?w=500
+	router.Get("/:provider/:id", coverHandler.GetCover)
+}
@@ -9,13 +9,32 @@ import (
 func RegisterOrderRoutes(router fiber.Router) {
 
 	var orderHandler controllers.OrderHandler = controllers.NewOrderHandler()
+	var quoteHandler controllers.QuoteHandler = controllers.NewQuoteHandler()
+	var eventHandler controllers.OrderEventHandler = controllers.NewOrderEventHandler()
+
+	// quote links sent to customers
+	router.Get("/quote/:token", quoteHandler.GetQuote)
+	router.Post("/quote/:token/accept", quoteHandler.AcceptQuote)
+
+	// registered before /:id so they are not shadowed
+	router.Get("/statuses", RequireAdminLogin, orderHandler.GetOrderStatuses)
+	router.Get("/export", RequireAdminLogin, orderHandler.ExportOrders)
+	router.Get("/slips.pdf", RequireAdminLogin, orderHandler.GetSlips)
 
 	router.Post("/", orderHandler.PostOrder)
 	router.Get("/:id", RequireAdminLogin, orderHandler.GetOrderByID)
 	router.Get("/", RequireAdminLogin, orderHandler.GetAllOrders)
 
 	router.Delete("/:id", RequireAdminLogin, orderHandler.DeleteOrder)
-	router.Get("/user/:email", orderHandler.GetOrdersByEmail)
+	router.Get("/user/:email", RequireAdminLogin, orderHandler.GetOrdersByEmail)
 
 	router.Put("/:id/status/:status", RequireAdminLogin, orderHandler.SetOrderStatus)
+	router.Get("/:id/history", RequireAdminLogin, eventHandler.GetOrderHistory)
+	router.Post("/:id/notes", RequireAdminLogin, eventHandler.PostOrderNote)
+
+	router.Get("/:id/invoice.pdf", RequireAdminLogin, orderHandler.GetInvoice)
+	router.Get("/:id/slip.pdf", RequireAdminLogin, orderHandler.GetSlip)
+
+	router.Put("/:id/quote", RequireAdminLogin, quoteHandler.UpdateQuote)
+	router.Post("/:id/quote/send", RequireAdminLogin, quoteHandler.SendQuote)
 }
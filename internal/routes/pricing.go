@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"amazon/internal/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterPricingRoutes registers the pricing engine routes, all admin only as
+// prices are not shown to customers.
+func RegisterPricingRoutes(router fiber.Router) {
+
+	var pricingHandler controllers.PricingHandler = controllers.NewPricingHandler()
+
+	// domain.com/pricing/preview?book=2070360024
+	router.Get("/preview", RequireAdminLogin, pricingHandler.GetPreview)
+
+	router.Get("/rules", RequireAdminLogin, pricingHandler.GetRules)
+	router.Post("/rules", RequireAdminLogin, pricingHandler.PostRules)
+	router.Get("/rules/history", RequireAdminLogin, pricingHandler.GetRulesHistory)
+	router.Get("/rules/:version", RequireAdminLogin, pricingHandler.GetRulesVersion)
+	router.Post("/rules/:version/restore", RequireAdminLogin, pricingHandler.PostRestoreRules)
+
+	var exchangeRateHandler controllers.ExchangeRateHandler = controllers.NewExchangeRateHandler()
+
+	// domain.com/pricing/exchange-rates?currency=EUR
+	router.Get("/exchange-rates", RequireAdminLogin, exchangeRateHandler.GetRates)
+	router.Get("/exchange-rates/current", RequireAdminLogin, exchangeRateHandler.GetCurrentRate)
+	router.Post("/exchange-rates", RequireAdminLogin, exchangeRateHandler.PostRate)
+	router.Post("/exchange-rates/import", RequireAdminLogin, exchangeRateHandler.PostImport)
+}
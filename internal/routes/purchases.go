@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"amazon/internal/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterPurchaseRoutes registers the admin routes buying the books of the
+// confirmed orders from the providers.
+func RegisterPurchaseRoutes(router fiber.Router) {
+
+	var purchaseHandler controllers.PurchaseHandler = controllers.NewPurchaseHandler()
+
+	router.Get("/", RequireAdminLogin, purchaseHandler.GetPurchases)
+	router.Post("/", RequireAdminLogin, purchaseHandler.PostPurchases)
+
+	router.Get("/:id", RequireAdminLogin, purchaseHandler.GetPurchase)
+	router.Put("/:id", RequireAdminLogin, purchaseHandler.PutPurchase)
+	router.Delete("/:id", RequireAdminLogin, purchaseHandler.DeletePurchase)
+	router.Put("/:id/status/:status", RequireAdminLogin, purchaseHandler.SetPurchaseStatus)
+}
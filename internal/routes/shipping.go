@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"amazon/internal/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterShippingRoutes registers the delivery routes. Customers see the
+// shipping cost of their cart, admins manage the rates.
+func RegisterShippingRoutes(router fiber.Router) {
+
+	var shippingHandler controllers.ShippingHandler = controllers.NewShippingHandler()
+
+	router.Get("/wilayas", shippingHandler.GetWilayas)
+
+	// domain.com/shipping/quote {"wilaya": 16, "items": [...]}
+	router.Post("/quote", shippingHandler.PostQuote)
+
+	router.Get("/rates", RequireAdminLogin, shippingHandler.GetRates)
+	router.Put("/rates", RequireAdminLogin, shippingHandler.PutRates)
+	router.Delete("/rates/:id", RequireAdminLogin, shippingHandler.DeleteRate)
+}
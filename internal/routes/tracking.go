@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"amazon/internal/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterTrackingRoutes registers the tracking links emailed to customers,
+// the token is the only credential.
+func RegisterTrackingRoutes(router fiber.Router) {
+
+	var orderHandler controllers.OrderHandler = controllers.NewOrderHandler()
+
+	// domain.com/track/<token>
+	router.Get("/:token", orderHandler.GetTracking)
+}
@@ -3,7 +3,6 @@ package authors
 import (
 	"amazon/internal/utils"
 	"amazon/models"
-	"os"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
@@ -23,25 +22,6 @@ func FetchAuthor(authorID string) (*models.Author, error) {
 		return nil, error
 	}
 
-	{ // Create File author.html containing content
-		file, err := os.Create("author.html")
-
-		if err != nil {
-			return nil, utils.Report("Failed to create file: author.html")
-		}
-
-		defer file.Close()
-		_, err = file.WriteString(content)
-
-		if err != nil {
-			return nil, utils.Report("Failed to write content to file: author.html")
-		}
-
-		if err := file.Close(); err != nil {
-			return nil, utils.Report("Failed to close file: author.html")
-		}
-	}
-
 	contentReader := strings.NewReader(content)
 	document, error := goquery.NewDocumentFromReader(contentReader)
 
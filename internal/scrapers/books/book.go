@@ -7,10 +7,10 @@ import (
 	"strings"
 	"time"
 
+	"amazon/internal/search"
 	"amazon/internal/utils"
 	"amazon/models"
 
-	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/nleeper/goment"
 )
@@ -24,6 +24,8 @@ func castBookData(json string) (*models.Book, string, error) {
 	}
 
 	book := response.Migrate()
+	book.Description = utils.SanitizeDescription(book.Description)
+	book.Language = utils.BookLanguage(book.Language, book.Title, book.Description)
 
 	return &book, "", nil
 
@@ -41,6 +43,9 @@ func loadBookFromCache(fileName string) (*models.Book, string, error) {
 		return nil, "failed_to_parse_cache_file_json", utils.Report("Failed to parse cached content: " + err.Error())
 	}
 
+	// books cached before languages were normalised hold names like "Français"
+	cachedBook.Language = utils.BookLanguage(cachedBook.Language, cachedBook.Title, cachedBook.Description)
+
 	return &cachedBook, "", nil
 }
 
@@ -50,49 +55,90 @@ func saveBookToCache(fileName string, book models.Book) error {
 		return utils.Report("Failed to convert books to JSON: " + err.Error())
 	}
 
-	return utils.WriteFile(fileName, cacheContent)
+	if err := utils.WriteFile(fileName, cacheContent); err != nil {
+		return err
+	}
+
+	search.Books.Add(book)
+	return nil
 }
 
 func FetchBook(id string) (*models.Book, string, error) {
-	var result models.Book = models.Book{}
-
 	fileName := fmt.Sprintf("%s/%s.json", utils.BOOKS_CACHE_DIRECTORY, id)
 	if utils.CacheValid(fileName, utils.CACHE_DURATION) {
 		return loadBookFromCache(fileName)
 	}
 
-	var url string = utils.AMAZON_URL + "/dp/" + id
-	content, statusCode, error, isDirect := utils.Fetch(url)
+	return scrapeBook(id, fileName)
+}
 
-	if error != nil {
-		return nil, "unhandled_error", error
+// FetchProviderBook reads a book from the provider it was found at, Amazon
+// when provider is empty.
+func FetchProviderBook(provider string, id string) (*models.Book, string, error) {
+	switch provider {
+	case "", PROVIDER_AMAZON:
+		return FetchBook(id)
+	case PROVIDER_GOOGLE:
+		return FetchGBook(id)
+	case PROVIDER_LIREKA:
+		return FetchLirekaBook(id)
 	}
+	return nil, "unknown_provider", utils.Report("Unknown book provider: " + provider)
+}
 
-	if isDirect {
-		return castBookData(content)
+// RefreshProviderBook reads a book again at the provider it was found at,
+// Amazon when provider is empty, skipping the cache so its price is observed.
+func RefreshProviderBook(provider string, id string) (*models.Book, string, error) {
+	switch provider {
+	case "", PROVIDER_AMAZON:
+		return RefreshBook(id)
+	case PROVIDER_GOOGLE, PROVIDER_LIREKA:
+		// never cached
+		return FetchProviderBook(provider, id)
 	}
+	return nil, "unknown_provider", utils.Report("Unknown book provider: " + provider)
+}
 
-	if statusCode == 404 {
-		return nil, "not_found", utils.Report("Book not found")
+// CachedBook reads a book from the cache only, it never scrapes. Ids come
+// from customers, those that can't name a cache file are not found.
+func CachedBook(id string) (*models.Book, string, error) {
+	if id == "" || strings.ContainsAny(id, `/\*?[`) {
+		return nil, "not_found", fmt.Errorf("invalid book id %q", id)
 	}
 
-	if utils.IS_DEVELOPMENT { // Create File book.html containing content
-		file, err := os.Create("book.html")
+	fileName := fmt.Sprintf("%s/%s.json", utils.BOOKS_CACHE_DIRECTORY, id)
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, "cache_file_not_found", err
+	}
+	return loadBookFromCache(fileName)
+}
 
-		if err != nil {
-			return nil, "fs_error", utils.Report("Failed to create file: book.html")
-		}
+// RefreshBook scrapes a book again even when it is cached, to follow its
+// price and stock status.
+func RefreshBook(id string) (*models.Book, string, error) {
+	return scrapeBook(id, fmt.Sprintf("%s/%s.json", utils.BOOKS_CACHE_DIRECTORY, id))
+}
 
-		defer file.Close()
-		_, err = file.WriteString(content)
+func scrapeBook(id string, fileName string) (*models.Book, string, error) {
+	var result models.Book = models.Book{}
 
-		if err != nil {
-			return nil, "fs_error", utils.Report("Failed to write content to file: book.html")
-		}
+	var url string = utils.AMAZON_URL + "/dp/" + id
+	content, statusCode, error, isDirect := utils.Fetch(url)
+
+	if error != nil {
+		return nil, "unhandled_error", error
+	}
 
-		if err := file.Close(); err != nil {
-			return nil, "fs_error", utils.Report("Failed to close file: book.html")
+	if isDirect {
+		book, errCode, err := castBookData(content)
+		if err == nil {
+			observePrices(PROVIDER_AMAZON, *book)
 		}
+		return book, errCode, err
+	}
+
+	if statusCode == 404 {
+		return nil, "not_found", utils.Report("Book not found")
 	}
 
 	contentReader := strings.NewReader(content)
@@ -133,7 +179,7 @@ func FetchBook(id string) (*models.Book, string, error) {
 
 	// if bookFrame contains Audible then don't add
 	if utils.IsAudible(bookFrame.Text()) {
-		return nil, "server_error", utils.Report("Book is an Audible/Audio book")
+		return nil, "audiobook", utils.Report("Book is an Audible/Audio book")
 	}
 
 	{ // Title
@@ -167,13 +213,7 @@ func FetchBook(id string) (*models.Book, string, error) {
 		})
 
 		html, _ := descriptionWrapper.Html()
-		markdown, err := htmltomarkdown.ConvertString(html)
-
-		if err != nil {
-			return nil, "server_error", utils.Report("Can't convert the description into markdown...")
-		}
-
-		result.Description = markdown
+		result.Description = utils.SanitizeDescription(html)
 	}
 
 	{ // Cover
@@ -231,7 +271,7 @@ func FetchBook(id string) (*models.Book, string, error) {
 			// return nil, "server_error", utils.Report("Language text is empty")
 		}
 
-		result.Language = languageText
+		result.Language = utils.BookLanguage(languageText, result.Title, result.Description)
 	}
 
 	{ // Publisher
@@ -395,6 +435,15 @@ func FetchBook(id string) (*models.Book, string, error) {
 		result.Dimension = d
 	}
 
+	{ // Weight, listed with the product details
+		for _, detail := range document.Find("#detailBullets_feature_div li, #productDetails_detailBullets_sections1 tr").EachIter() {
+			if weight := parseWeight(detail.Text()); weight > 0 {
+				result.Dimension.Weight = weight
+				break
+			}
+		}
+	}
+
 	{ // Price (NOW OPTIONAL)
 		priceWrapper := bookPriceFrame.Find(".aok-offscreen")
 		if priceWrapper.Length() == 0 {
@@ -435,9 +484,15 @@ func FetchBook(id string) (*models.Book, string, error) {
 		result.Price = float32(price)
 	}
 
+	{ // Availability
+		result.Availability = parseAvailability(bookPriceFrame.Find("#availability").Text())
+	}
+
 	if err := saveBookToCache(fileName, result); err != nil {
 		utils.Report("Failed to write cache file: " + err.Error())
 	}
 
+	observePrices(PROVIDER_AMAZON, result)
+
 	return &result, "", nil
 }
@@ -4,8 +4,11 @@ import (
 	"amazon/internal/utils"
 	"amazon/models"
 	"fmt"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -16,6 +19,15 @@ func parseDocument(content string) (*goquery.Document, error) {
 	return goquery.NewDocumentFromReader(strings.NewReader(content))
 }
 
+// listingSelector returns the selector matching one book in a listing page,
+// which differs between search results and regular pages.
+func listingSelector(isSearch bool) string {
+	if isSearch {
+		return `[data-asin]:not([data-asin=""])[role=listitem]`
+	}
+	return "[data-asin]"
+}
+
 func extractBookData(book *goquery.Selection, isSearch bool) (models.BookThumbnail, error) {
 	var bookThumbnail models.BookThumbnail
 
@@ -329,15 +341,7 @@ func fetchAndParseBooks(url string, isSearch bool) (*[]models.BookThumbnail, int
 		return nil, 0, err
 	}
 
-	// Different selectors for search vs regular pages
-	var bookSelector string
-	if isSearch {
-		bookSelector = `[data-asin]:not([data-asin=""])[role=listitem]`
-	} else {
-		bookSelector = "[data-asin]"
-	}
-
-	books := document.Find(bookSelector)
+	books := document.Find(listingSelector(isSearch))
 	if books.Length() == 0 {
 		return nil, 0, utils.Report("Can't find books")
 	}
@@ -386,6 +390,27 @@ func saveToCache(fileName string, books []models.BookThumbnail) error {
 	return utils.WriteFile(fileName, cacheContent)
 }
 
+// FillThumbnailLanguages sets the language of listing thumbnails, from the
+// cached book when we have it and from the title otherwise.
+func FillThumbnailLanguages(thumbnails []models.BookThumbnail) {
+	for i := range thumbnails {
+		thumbnail := &thumbnails[i]
+		if thumbnail.Language != "" {
+			continue
+		}
+
+		fileName := fmt.Sprintf("%s/%s.json", utils.BOOKS_CACHE_DIRECTORY, thumbnail.ID)
+		if _, err := os.Stat(fileName); err == nil {
+			if book, _, err := loadBookFromCache(fileName); err == nil {
+				thumbnail.Language = book.Language
+				continue
+			}
+		}
+
+		thumbnail.Language = utils.DetectLanguage(thumbnail.Title)
+	}
+}
+
 func FetchBooks(page int) (*[]models.BookThumbnail, int, error) {
 	// Check cache first
 	fileName := fmt.Sprintf("%s/%d-*.json", utils.CACHE_DIRECTORY, page)
@@ -415,6 +440,79 @@ func FetchBooks(page int) (*[]models.BookThumbnail, int, error) {
 	return books, pageCount, nil
 }
 
+// FetchBooksInLanguage returns a page of the bestsellers in language. The
+// bestseller pages are filtered before paging, so pages are as full as the
+// unfiltered ones and the page count only counts books in language. Only the
+// first bestseller page may be scraped here, the others are read from the
+// cache and the missing ones are scraped in the background for later requests.
+func FetchBooksInLanguage(language string, page int) (*[]models.BookThumbnail, int, error) {
+	first, listingPages, err := FetchBooks(1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// an empty cached page leaves nothing to page
+	pageSize := len(*first)
+	if pageSize == 0 {
+		return &[]models.BookThumbnail{}, 0, nil
+	}
+
+	matching := thumbnailsInLanguage(*first, language)
+	var missing []int
+	for listing := 2; listing <= min(listingPages, utils.LANGUAGE_LISTING_PAGES); listing++ {
+		books, ok := cachedListing(listing)
+		if !ok {
+			missing = append(missing, listing)
+			continue
+		}
+		matching = append(matching, thumbnailsInLanguage(*books, language)...)
+	}
+	if len(missing) > 0 && fillingListings.CompareAndSwap(false, true) {
+		go fillListings(missing)
+	}
+
+	pageCount := (len(matching) + pageSize - 1) / pageSize
+	if page < 1 || page > pageCount {
+		return nil, pageCount, utils.Report("No books in " + language + " on page " + strconv.Itoa(page))
+	}
+
+	start := (page - 1) * pageSize
+	books := matching[start:min(start+pageSize, len(matching))]
+	return &books, pageCount, nil
+}
+
+// fillingListings is set while missing bestseller pages are scraped.
+var fillingListings atomic.Bool
+
+// fillListings scrapes the bestseller pages, one after the other.
+func fillListings(pages []int) {
+	defer fillingListings.Store(false)
+
+	for _, page := range pages {
+		if _, _, err := FetchBooks(page); err != nil {
+			utils.Report("Can't fill bestseller page " + strconv.Itoa(page) + ": " + err.Error())
+		}
+	}
+}
+
+// cachedListing returns a bestseller page from the cache only.
+func cachedListing(page int) (*[]models.BookThumbnail, bool) {
+	fileName := fmt.Sprintf("%s/%d-*.json", utils.CACHE_DIRECTORY, page)
+	if !utils.CacheValid(fileName, utils.CACHE_DURATION) {
+		return nil, false
+	}
+
+	books, _, err := loadFromCache(fileName)
+	return books, err == nil
+}
+
+func thumbnailsInLanguage(thumbnails []models.BookThumbnail, language string) []models.BookThumbnail {
+	FillThumbnailLanguages(thumbnails)
+	return slices.DeleteFunc(thumbnails, func(thumbnail models.BookThumbnail) bool {
+		return thumbnail.Language != language
+	})
+}
+
 func SearchBooks(query string, page int) (*[]models.BookThumbnail, int, error) {
 	normalizedQuery := utils.NormalizeQuery(query)
 
@@ -0,0 +1,51 @@
+package books
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"amazon/internal/utils"
+	"amazon/models"
+)
+
+func TestFetchBooksInLanguage(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(utils.CACHE_DIRECTORY, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// two cached bestseller pages of three books
+	listings := [][]string{{"fr", "en", "fr"}, {"fr", "fr", "en"}}
+	for i, languages := range listings {
+		var thumbnails []models.BookThumbnail
+		for j, language := range languages {
+			thumbnails = append(thumbnails, models.BookThumbnail{ID: fmt.Sprintf("B%d%d", i+1, j), Language: language})
+		}
+		if err := saveToCache(fmt.Sprintf("%s/%d-%d.json", utils.CACHE_DIRECTORY, i+1, len(listings)), thumbnails); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, pageCount, err := FetchBooksInLanguage("fr", 1)
+	if err != nil || pageCount != 2 || len(*first) != 3 || (*first)[2].ID != "B20" {
+		t.Fatalf("first page = %+v, %d, %v", first, pageCount, err)
+	}
+	second, _, err := FetchBooksInLanguage("fr", 2)
+	if err != nil || len(*second) != 1 || (*second)[0].ID != "B21" {
+		t.Fatalf("second page = %+v, %v", second, err)
+	}
+
+	if _, pageCount, err := FetchBooksInLanguage("en", 2); err == nil || pageCount != 1 {
+		t.Errorf("page past the English books: %d, %v", pageCount, err)
+	}
+
+	// a null cached first page has no pages
+	if err := saveToCache(fmt.Sprintf("%s/1-2.json", utils.CACHE_DIRECTORY), nil); err != nil {
+		t.Fatal(err)
+	}
+	empty, pageCount, err := FetchBooksInLanguage("fr", 1)
+	if err != nil || pageCount != 0 || len(*empty) != 0 {
+		t.Errorf("empty listing = %+v, %d, %v", empty, pageCount, err)
+	}
+}
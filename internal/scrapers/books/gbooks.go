@@ -1,13 +1,17 @@
 package books
 
 import (
+	"amazon/internal/utils"
 	"amazon/models"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/nleeper/goment"
 	gbooks "google.golang.org/api/books/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -16,7 +20,8 @@ var (
 	apiKey string
 )
 
-func maxCoverURL(volumeID string) string {
+// MaxCoverURL returns the largest cover Google Books serves for a volume.
+func MaxCoverURL(volumeID string) string {
 	return fmt.Sprintf("https://books.google.com/books/publisher/content/images/frontcover/%s?fife=w800-h1200&source=gbs_api", volumeID)
 }
 
@@ -64,7 +69,7 @@ func CastVolumeToBook(book *gbooks.Volume) models.Book {
 	res.Title = info.Title
 
 	// Cover
-	res.Cover = maxCoverURL(book.Id)
+	res.Cover = MaxCoverURL(book.Id)
 
 	// Rating (not available)
 	res.Rating = -1
@@ -75,9 +80,9 @@ func CastVolumeToBook(book *gbooks.Volume) models.Book {
 	date := parseGoogleBooksDate(info.PublishedDate)
 	res.PubDate = date.ToTime().Format(time.RFC3339)
 
-	res.Language = info.Language
+	res.Description = utils.SanitizeDescription(info.Description)
 
-	res.Description = info.Description
+	res.Language = utils.BookLanguage(info.Language, res.Title, res.Description)
 
 	res.Pages = int(info.PageCount)
 
@@ -105,11 +110,13 @@ func CastVolumeToBookThumbnail(book *gbooks.Volume) models.BookThumbnail {
 	res.Title = info.Title
 
 	// Cover
-	res.Cover = maxCoverURL(book.Id)
+	res.Cover = MaxCoverURL(book.Id)
 
 	// Rating (not available)
 	res.Rating = -1
 
+	res.Language = utils.NormalizeLanguage(info.Language)
+
 	// Turn on GBook because it's a google book
 	res.IsGBook = true
 
@@ -180,10 +187,15 @@ func FetchGBook(id string) (*models.Book, string, error) {
 	}
 
 	vol, err := srv.Volumes.Get(id).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return nil, "not_found", utils.Report("Book not found")
+	}
 	if err != nil {
 		return nil, "cannot_get_volume", err
 	}
 
 	book := CastVolumeToBook(vol)
+	observePrices(PROVIDER_GOOGLE, book)
 	return &book, "", nil
 }
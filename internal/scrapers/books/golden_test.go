@@ -0,0 +1,184 @@
+package books
+
+// Golden-file tests for the Amazon HTML parsers.
+//
+// Every case fetches its page through a utils.ReplayFetcher reading
+// testdata/fixtures, runs the parser and compares the result with the JSON
+// stored in testdata/golden.
+//
+// After an intentional parser change, rewrite the golden files with:
+//
+//	go test ./internal/scrapers/books -run Golden -update
+//
+// When Amazon changes its markup, re-record the fixtures from the live site
+// (scraping-bot credentials required) and refresh the golden files with:
+//
+//	SCRAPING_BOT_USER=... SCRAPING_BOT_KEY=... go test ./internal/scrapers/books -run Golden -record -update
+
+import (
+	"amazon/internal/utils"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	update = flag.Bool("update", false, "rewrite golden files with the current parser output")
+	record = flag.Bool("record", false, "re-record HTML fixtures from the live site before parsing")
+)
+
+// absolute, as every test runs from a scratch directory
+var fixturesDirectory, goldenDirectory string
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	var err error
+	if fixturesDirectory, err = filepath.Abs("testdata/fixtures"); err != nil {
+		panic(err)
+	}
+	if goldenDirectory, err = filepath.Abs("testdata/golden"); err != nil {
+		panic(err)
+	}
+
+	// publication dates are parsed in the local zone, pin it so goldens are stable
+	time.Local = time.UTC
+
+	os.Exit(m.Run())
+}
+
+// useReplayFetcher routes utils.Fetch through the fixtures and runs the test from
+// a scratch directory, so FetchBook's cache never touches the package tree.
+func useReplayFetcher(t *testing.T) {
+	t.Helper()
+
+	previous := utils.Fetch
+	utils.Fetch = utils.NewReplayFetcher(fixturesDirectory, *record).Fetch
+	t.Cleanup(func() { utils.Fetch = previous })
+
+	t.Chdir(t.TempDir())
+}
+
+// assertGolden compares got, encoded as JSON, with testdata/golden/<name>.json.
+func assertGolden(t *testing.T, name string, got any) {
+	t.Helper()
+
+	path := filepath.Join(goldenDirectory, name+".json")
+
+	actual, err := utils.ToJson(got)
+	if err != nil {
+		t.Fatalf("failed to encode result: %v", err)
+	}
+	actual += "\n"
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := utils.WriteFile(path, actual); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden file %s (run with -update to create it): %v", path, err)
+	}
+
+	if string(expected) != actual {
+		t.Errorf("result differs from %s\n--- expected\n%s\n--- actual\n%s", path, expected, actual)
+	}
+}
+
+func TestGoldenFetchBook(t *testing.T) {
+	cases := []struct {
+		name string
+		id   string
+	}{
+		{name: "book-paperback", id: "0981531644"},
+		{name: "book-no-details", id: "2070360024"},
+		{name: "book-audible", id: "B0CHRXK5X1"},
+		{name: "book-missing", id: "0000000000"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useReplayFetcher(t)
+
+			book, errCode, err := FetchBook(tc.id)
+
+			result := struct {
+				Book    any    `json:"book"`
+				ErrCode string `json:"err_code"`
+				Error   string `json:"error"`
+			}{Book: book, ErrCode: errCode}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			assertGolden(t, "fetch-"+tc.name, result)
+		})
+	}
+}
+
+func TestGoldenListings(t *testing.T) {
+	cases := []struct {
+		name     string
+		url      string
+		isSearch bool
+	}{
+		{
+			name: "bestsellers",
+			url:  fmt.Sprintf("%s/gp/bestsellers/books/ref=zg_bs_pg_2_books?ie=UTF8&pg=%d", utils.AMAZON_URL, 1),
+		},
+		{
+			name:     "search",
+			url:      fmt.Sprintf("%s/s?k=%s&i=stripbooks&crid=2G90TZW10SV2H&sprefix=%sstripbooks%%2C273&ref=nb_sb_ss_mvt-t11-ranker_2_9&page=%d", utils.AMAZON_URL, "learn+scala", "learn+scala", 1),
+			isSearch: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useReplayFetcher(t)
+
+			content, status, err, _ := utils.Fetch(tc.url)
+			if err != nil || status != 200 {
+				t.Fatalf("no fixture for %s (status %d): %v", tc.url, status, err)
+			}
+
+			document, err := parseDocument(content)
+			if err != nil {
+				t.Fatalf("failed to parse fixture: %v", err)
+			}
+
+			type extracted struct {
+				Book  any    `json:"book,omitempty"`
+				Error string `json:"error,omitempty"`
+			}
+
+			result := struct {
+				PageCount int         `json:"page_count"`
+				Books     []extracted `json:"books"`
+			}{
+				PageCount: extractPageCount(document, tc.isSearch),
+				Books:     []extracted{},
+			}
+
+			for _, book := range document.Find(listingSelector(tc.isSearch)).EachIter() {
+				thumbnail, err := extractBookData(book, tc.isSearch)
+				if err != nil {
+					result.Books = append(result.Books, extracted{Error: err.Error()})
+					continue
+				}
+				result.Books = append(result.Books, extracted{Book: thumbnail})
+			}
+
+			assertGolden(t, "listing-"+tc.name, result)
+		})
+	}
+}
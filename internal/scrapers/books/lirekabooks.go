@@ -1,6 +1,7 @@
 package books
 
 import (
+	"amazon/internal/utils"
 	"amazon/models"
 	"bytes"
 	"encoding/json"
@@ -20,6 +21,34 @@ type algoliaResponse struct {
 
 // LirekaSearchBooks fetches and parses Lireka’s Algolia API results.
 func LirekaSearchBooks(query string) ([]models.Book, error) {
+	return lirekaQuery(fmt.Sprintf(
+		"query=%s&hitsPerPage=40&page=0"+
+			"&filters=channels:11 AND NOT suppliedByLireka:true"+
+			"&clickAnalytics=true",
+		url.QueryEscape(query),
+	))
+}
+
+// FetchLirekaBook retrieves one book by its ID.
+func FetchLirekaBook(id string) (*models.Book, string, error) {
+	if id == "" || strings.ContainsAny(id, `"\`) {
+		return nil, "not_found", utils.Report("Book not found")
+	}
+
+	books, err := lirekaQuery("query=&hitsPerPage=1&page=0&filters=" +
+		url.QueryEscape(`objectID:"`+id+`" AND channels:11 AND NOT suppliedByLireka:true`))
+	if err != nil {
+		return nil, "lireka_error", err
+	}
+	if len(books) == 0 {
+		return nil, "not_found", utils.Report("Book not found")
+	}
+	return &books[0], "", nil
+}
+
+// lirekaQuery runs an Algolia query on Lireka's book index, params in the
+// Algolia format.
+func lirekaQuery(query string) ([]models.Book, error) {
 	urlStr := "https://mwx92vzv2w-dsn.algolia.net/1/indexes/*/queries"
 
 	params := url.Values{}
@@ -31,12 +60,7 @@ func LirekaSearchBooks(query string) ([]models.Book, error) {
 		"requests": []map[string]string{
 			{
 				"indexName": "books",
-				"params": fmt.Sprintf(
-					"query=%s&hitsPerPage=40&page=0"+
-						"&filters=channels:11 AND NOT suppliedByLireka:true"+
-						"&clickAnalytics=true",
-					url.QueryEscape(query),
-				),
+				"params":    query,
 			},
 		},
 	}
@@ -76,7 +100,7 @@ func LirekaSearchBooks(query string) ([]models.Book, error) {
 		book := models.Book{
 			ID:          getString(h["objectID"]),
 			Title:       getString(h["title"]),
-			Description: getString(h["description"]),
+			Description: utils.SanitizeDescription(getString(h["description"])),
 			Publisher:   getString(h["imprints"]),
 			PubDate:     getString(h["publicationDateStr"]),
 			IsGBook:     false,
@@ -137,11 +161,13 @@ func LirekaSearchBooks(query string) ([]models.Book, error) {
 			Depth:  getFloat(h["weight"]) / 100,
 		}
 
-		book.Language = getString(h["language"])
+		book.Language = utils.BookLanguage(getString(h["language"]), book.Title, book.Description)
 
 		books = append(books, book)
 	}
 
+	observePrices(PROVIDER_LIREKA, books...)
+
 	return books, nil
 }
 
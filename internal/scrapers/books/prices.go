@@ -0,0 +1,57 @@
+package books
+
+import (
+	"amazon/internal/utils"
+	"amazon/models"
+	"strings"
+)
+
+const (
+	PROVIDER_AMAZON = "amazon"
+	PROVIDER_LIREKA = "lireka"
+	PROVIDER_GOOGLE = "google"
+)
+
+// PriceObserver receives the books freshly read from a provider, never the
+// cached ones, to keep their price history. It is set at startup.
+var PriceObserver func(provider string, books ...models.Book)
+
+func observePrices(provider string, books ...models.Book) {
+	if PriceObserver != nil && len(books) > 0 {
+		PriceObserver(provider, books...)
+	}
+}
+
+// availabilityMessages maps the start of Amazon's #availability message,
+// lower case and accents folded, to a stock status. Checked in order.
+var availabilityMessages = []struct {
+	prefix       string
+	availability string
+}{
+	{"temporairement en rupture", models.AVAILABILITY_OUT_OF_STOCK},
+	{"temporarily out of stock", models.AVAILABILITY_OUT_OF_STOCK},
+	{"actuellement indisponible", models.AVAILABILITY_UNAVAILABLE},
+	{"currently unavailable", models.AVAILABILITY_UNAVAILABLE},
+	{"en stock", models.AVAILABILITY_IN_STOCK},
+	{"in stock", models.AVAILABILITY_IN_STOCK},
+	{"il ne reste plus", models.AVAILABILITY_IN_STOCK}, // "Il ne reste plus que 3 exemplaire(s) en stock"
+	{"only", models.AVAILABILITY_IN_STOCK},             // "Only 3 left in stock"
+	{"habituellement expedie", models.AVAILABILITY_OUT_OF_STOCK},
+	{"usually ships", models.AVAILABILITY_OUT_OF_STOCK},
+}
+
+// parseAvailability reads the stock status of a product page, "" when the
+// page has no availability message.
+func parseAvailability(text string) string {
+	text = utils.FoldAccents(strings.ToLower(strings.Join(strings.Fields(text), " ")))
+	if text == "" {
+		return ""
+	}
+
+	for _, message := range availabilityMessages {
+		if strings.HasPrefix(text, message.prefix) {
+			return message.availability
+		}
+	}
+	return models.AVAILABILITY_UNKNOWN
+}
@@ -0,0 +1,41 @@
+package books
+
+import (
+	"amazon/models"
+	"math"
+	"testing"
+)
+
+func TestParseAvailability(t *testing.T) {
+	cases := map[string]string{
+		"":                  "",
+		"\n   En stock  \n": models.AVAILABILITY_IN_STOCK,
+		"Il ne reste plus que 2 exemplaire(s) en stock.": models.AVAILABILITY_IN_STOCK,
+		"Temporairement en rupture de stock.":            models.AVAILABILITY_OUT_OF_STOCK,
+		"Habituellement expédié sous 1 à 2 mois.":        models.AVAILABILITY_OUT_OF_STOCK,
+		"Actuellement indisponible.":                     models.AVAILABILITY_UNAVAILABLE,
+		"Précommande":                                    models.AVAILABILITY_UNKNOWN,
+	}
+
+	for text, want := range cases {
+		if got := parseAvailability(text); got != want {
+			t.Errorf("parseAvailability(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestParseWeight(t *testing.T) {
+	cases := map[string]float64{
+		"Poids de l'article ‏ : ‎ 340 g":       340,
+		"Poids de l'article : 1,2 kilogrammes": 1200,
+		"Item Weight : 1.5 pounds":             680.388,
+		"Dimensions : 14 x 2 x 21 cm":          0,
+		"Éditeur : Gallimard (12 mai 2022)":    0,
+	}
+
+	for text, want := range cases {
+		if got := parseWeight(text); math.Abs(got-want) > 0.001 {
+			t.Errorf("parseWeight(%q) = %g, want %g", text, got, want)
+		}
+	}
+}
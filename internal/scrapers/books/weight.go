@@ -0,0 +1,38 @@
+package books
+
+import (
+	"amazon/internal/utils"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var weightPattern = regexp.MustCompile(`(\d+(?:[.,]\d+)?)\s*(kilogrammes?|kg|grammes?|grams?|g|pounds?|lbs?|ounces?|onces?|oz)\b`)
+
+// gramsPerUnit converts the units of weightPattern.
+var gramsPerUnit = map[string]float64{
+	"kg": 1000, "kilogramme": 1000, "kilogrammes": 1000,
+	"g": 1, "gramme": 1, "grammes": 1, "gram": 1, "grams": 1,
+	"lb": 453.592, "lbs": 453.592, "pound": 453.592, "pounds": 453.592,
+	"oz": 28.3495, "once": 28.3495, "onces": 28.3495, "ounce": 28.3495, "ounces": 28.3495,
+}
+
+// parseWeight reads the weight of a product detail such as
+// "Poids de l'article : 340 g" in grams, 0 for other details.
+func parseWeight(detail string) float64 {
+	detail = utils.FoldAccents(strings.ToLower(strings.Join(strings.Fields(detail), " ")))
+	if !strings.Contains(detail, "poids") && !strings.Contains(detail, "weight") {
+		return 0
+	}
+
+	match := weightPattern.FindStringSubmatch(detail)
+	if match == nil {
+		return 0
+	}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(match[1], ",", "."), 64)
+	if err != nil {
+		return 0
+	}
+	return value * gramsPerUnit[match[2]]
+}
@@ -10,6 +10,7 @@ var FetchGBook = books.FetchGBook
 
 var FetchBook = books.FetchBook
 var FetchBooks = books.FetchBooks
+var FetchBooksInLanguage = books.FetchBooksInLanguage
 
 var SearchBooks = books.SearchBooks
 var LirekaSearchBooks = books.LirekaSearchBooks
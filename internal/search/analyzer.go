@@ -0,0 +1,98 @@
+package search
+
+import (
+	"amazon/internal/utils"
+	"strings"
+	"unicode"
+)
+
+// stopWords are dropped from both documents and queries. French and English
+// cover almost all of the catalog.
+var stopWords = map[string]bool{
+	// fr
+	"le": true, "la": true, "les": true, "un": true, "une": true, "des": true, "du": true, "de": true,
+	"et": true, "ou": true, "en": true, "au": true, "aux": true, "a": true, "ce": true, "ces": true,
+	"dans": true, "par": true, "pour": true, "sur": true, "son": true, "sa": true, "ses": true,
+	"qui": true, "que": true, "est": true, "il": true, "elle": true, "se": true, "ne": true, "pas": true,
+	// en
+	"the": true, "an": true, "and": true, "or": true, "of": true, "to": true, "in": true, "on": true,
+	"for": true, "with": true, "is": true, "by": true, "at": true, "as": true, "it": true, "its": true,
+}
+
+// elisions are the French prefixes glued to the next word: l'étranger, d'amour.
+var elisions = []string{"l'", "d'", "j'", "m'", "n'", "s'", "t'", "c'", "qu'", "jusqu'", "lorsqu'", "puisqu'"}
+
+// Analyze turns text into index terms: lower case, accents folded, elisions
+// and stop words removed, then stemmed.
+func Analyze(text string) []string {
+	var terms []string
+	for _, token := range tokenize(text) {
+		if stopWords[token] {
+			continue
+		}
+		terms = append(terms, Stem(token))
+	}
+	return terms
+}
+
+func tokenize(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "’", "'")
+
+	tokens := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+
+	result := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		for _, elision := range elisions {
+			if strings.HasPrefix(token, elision) {
+				token = token[len(elision):]
+				break
+			}
+		}
+
+		token = utils.FoldAccents(strings.Trim(token, "'"))
+		if token != "" {
+			result = append(result, token)
+		}
+	}
+
+	return result
+}
+
+// Stem is a light French/English stemmer. It only needs to map the forms a
+// customer types to the forms found in titles, so it strips inflections and a
+// few common derivations rather than being linguistically exact.
+func Stem(term string) string {
+	length := len([]rune(term))
+
+	switch {
+	case length > 6 && strings.HasSuffix(term, "ements"):
+		term = strings.TrimSuffix(term, "ements")
+	case length > 5 && strings.HasSuffix(term, "ement"):
+		term = strings.TrimSuffix(term, "ement")
+	case length > 6 && strings.HasSuffix(term, "ations"):
+		term = strings.TrimSuffix(term, "s")
+	case length > 5 && strings.HasSuffix(term, "ing"):
+		term = strings.TrimSuffix(term, "ing")
+	case length > 4 && strings.HasSuffix(term, "aux"):
+		term = strings.TrimSuffix(term, "aux") + "al"
+	case length > 4 && strings.HasSuffix(term, "ies"):
+		term = strings.TrimSuffix(term, "ies") + "y"
+	case length > 5 && strings.HasSuffix(term, "euses"):
+		term = strings.TrimSuffix(term, "euses") + "eu"
+	case length > 4 && strings.HasSuffix(term, "euse"):
+		term = strings.TrimSuffix(term, "euse") + "eu"
+	case length > 3 && strings.HasSuffix(term, "eux"):
+		term = strings.TrimSuffix(term, "x")
+	case length > 3 && (strings.HasSuffix(term, "s") || strings.HasSuffix(term, "x")) && !strings.HasSuffix(term, "ss"):
+		term = term[:len(term)-1]
+	}
+
+	// feminine and silent final e: "petite" and "petit", "guide" and "guid"
+	if len([]rune(term)) > 4 && strings.HasSuffix(term, "e") {
+		term = strings.TrimSuffix(term, "e")
+	}
+
+	return term
+}
@@ -0,0 +1,363 @@
+package search
+
+import (
+	"amazon/internal/utils"
+	"amazon/models"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MAX_RESULTS    = 40
+	MIN_LOCAL_HITS = 5 // below this the upstream providers are queried too
+
+	minTermsForPartialMatch = 3
+
+	prefixMatchFactor = 0.8
+	typoMatchFactor   = 0.6
+)
+
+// field weights, a title match is worth much more than a description match
+const (
+	titleWeight       = 4.0
+	authorWeight      = 3.0
+	publisherWeight   = 1.5
+	descriptionWeight = 0.5
+)
+
+// Hit is one search result with its relevance score.
+type Hit struct {
+	Book  models.Book `json:"book"`
+	Score float64     `json:"score"`
+}
+
+// Index is an in-memory inverted index over books.
+type Index struct {
+	mu       sync.RWMutex
+	books    map[string]models.Book
+	postings map[string]map[string]float64 // term -> book ID -> weight
+	docTerms map[string][]string           // book ID -> terms, to unindex on update
+
+	suggester *Suggester // built on first use after a change
+	loadedAt  time.Time
+	files     map[string]string // file loaded by LoadDirectory -> book ID
+}
+
+// Books indexes every book of the local cache.
+var Books = NewIndex()
+
+func NewIndex() *Index {
+	return &Index{
+		books:    make(map[string]models.Book),
+		postings: make(map[string]map[string]float64),
+		docTerms: make(map[string][]string),
+		files:    make(map[string]string),
+	}
+}
+
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.books)
+}
+
+// Add indexes a book, replacing any previous version of it.
+func (i *Index) Add(book models.Book) {
+	if book.ID == "" {
+		return
+	}
+
+	weights := make(map[string]float64)
+	addField := func(text string, weight float64) {
+		counts := make(map[string]int)
+		for _, term := range Analyze(text) {
+			counts[term]++
+		}
+		for term, count := range counts {
+			weights[term] += weight * (1 + math.Log(float64(count)))
+		}
+	}
+
+	addField(book.Title, titleWeight)
+	for _, author := range book.Authors {
+		addField(author.Name, authorWeight)
+	}
+	addField(book.Publisher, publisherWeight)
+	addField(utils.RenderDescription(book.Description, utils.DESCRIPTION_FORMAT_TEXT), descriptionWeight)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(book.ID)
+
+	terms := make([]string, 0, len(weights))
+	for term, weight := range weights {
+		if i.postings[term] == nil {
+			i.postings[term] = make(map[string]float64)
+		}
+		i.postings[term][book.ID] = weight
+		terms = append(terms, term)
+	}
+
+	i.books[book.ID] = book
+	i.docTerms[book.ID] = terms
+	i.suggester = nil
+}
+
+func (i *Index) Remove(id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(id)
+}
+
+func (i *Index) remove(id string) {
+	for _, term := range i.docTerms[id] {
+		delete(i.postings[term], id)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+		}
+	}
+
+	delete(i.docTerms, id)
+	delete(i.books, id)
+	i.suggester = nil
+}
+
+// LoadDirectory indexes the cached book files of dir that changed since the
+// previous call and drops the books whose file was deleted, so it can be
+// called periodically to follow the books cached by another process. It
+// returns the number of books (re)indexed.
+func (i *Index) LoadDirectory(dir string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	i.mu.RLock()
+	since := i.loadedAt
+	i.mu.RUnlock()
+	startedAt := time.Now()
+
+	seen := make(map[string]bool, len(files))
+	loaded := 0
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		seen[file] = true
+		if !info.ModTime().After(since) {
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		var book models.Book
+		if err := utils.ParseJson(string(content), &book); err != nil {
+			utils.Report("Skipping unreadable cached book " + file + ": " + err.Error())
+			continue
+		}
+
+		book.Language = utils.BookLanguage(book.Language, book.Title, book.Description)
+		i.Add(book)
+		loaded++
+
+		i.mu.Lock()
+		i.files[file] = book.ID
+		i.mu.Unlock()
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.loadedAt = startedAt
+	for file, id := range i.files {
+		if !seen[file] && filepath.Dir(file) == filepath.Clean(dir) {
+			delete(i.files, file)
+			i.remove(id)
+		}
+	}
+
+	return loaded, nil
+}
+
+// Search returns the books matching query, best first. Every query term must
+// match, exactly, as a prefix (last term only, for as-you-type queries) or
+// within a small edit distance. For longer queries, when no book matches every
+// term, books missing a single term are returned instead.
+func (i *Index) Search(query string, limit int) []Hit {
+	terms := uniqueTerms(Analyze(query))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	total := float64(len(i.books))
+	scores := make(map[string]float64)
+	matched := make(map[string]int)
+
+	for position, term := range terms {
+		isLast := position == len(terms)-1
+
+		// best contribution of this query term per book
+		best := make(map[string]float64)
+		for candidate, factor := range i.expand(term, isLast) {
+			postings := i.postings[candidate]
+			idf := math.Log(1 + total/float64(len(postings)))
+
+			for id, weight := range postings {
+				if score := weight * idf * factor; score > best[id] {
+					best[id] = score
+				}
+			}
+		}
+
+		for id, score := range best {
+			scores[id] += score
+			matched[id]++
+		}
+	}
+
+	required := len(terms)
+	if len(terms) >= minTermsForPartialMatch && !anyMatches(matched, required) {
+		required--
+	}
+
+	hits := make([]Hit, 0)
+	for id, score := range scores {
+		if matched[id] >= required {
+			hits = append(hits, Hit{Book: i.books[id], Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].Book.Title < hits[b].Book.Title
+	})
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits
+}
+
+// expand returns the indexed terms a query term stands for, with the factor
+// applied to their score.
+func (i *Index) expand(term string, allowPrefix bool) map[string]float64 {
+	candidates := make(map[string]float64)
+	if _, ok := i.postings[term]; ok {
+		candidates[term] = 1
+	}
+
+	length := len([]rune(term))
+	maxDistance := 0
+	switch {
+	case length >= 8:
+		maxDistance = 2
+	case length >= 4:
+		maxDistance = 1
+	}
+
+	for candidate := range i.postings {
+		if candidate == term {
+			continue
+		}
+
+		if allowPrefix && length >= 2 && strings.HasPrefix(candidate, term) {
+			candidates[candidate] = prefixMatchFactor
+			continue
+		}
+
+		if maxDistance > 0 && editDistance(term, candidate, maxDistance) <= maxDistance {
+			candidates[candidate] = typoMatchFactor
+		}
+	}
+
+	return candidates
+}
+
+func anyMatches(matched map[string]int, required int) bool {
+	for _, count := range matched {
+		if count >= required {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			result = append(result, term)
+		}
+	}
+	return result
+}
+
+// editDistance is the Damerau-Levenshtein distance between a and b, giving up
+// with max+1 as soon as it exceeds max.
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > max {
+		return max + 1
+	}
+
+	previous2 := make([]int, len(rb)+1)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for x := 1; x <= len(ra); x++ {
+		current[0] = x
+		rowMin := current[0]
+
+		for y := 1; y <= len(rb); y++ {
+			cost := 1
+			if ra[x-1] == rb[y-1] {
+				cost = 0
+			}
+
+			current[y] = min(previous[y]+1, current[y-1]+1, previous[y-1]+cost)
+			if x > 1 && y > 1 && ra[x-1] == rb[y-2] && ra[x-2] == rb[y-1] {
+				current[y] = min(current[y], previous2[y-2]+1)
+			}
+
+			rowMin = min(rowMin, current[y])
+		}
+
+		if rowMin > max {
+			return max + 1
+		}
+
+		previous2, previous, current = previous, current, previous2
+	}
+
+	return previous[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"amazon/models"
+)
+
+func testIndex() *Index {
+	index := NewIndex()
+	index.Add(models.Book{
+		ID:        "2070360024",
+		Title:     "L'Étranger",
+		Authors:   []models.AuthorType{{Name: "Albert Camus"}},
+		Publisher: "Gallimard",
+	})
+	index.Add(models.Book{
+		ID:          "0981531644",
+		Title:       "Programming in Scala",
+		Authors:     []models.AuthorType{{Name: "Martin Odersky"}},
+		Publisher:   "Artima",
+		Description: "The **definitive** guide to the Scala language.",
+	})
+	index.Add(models.Book{
+		ID:        "2253004227",
+		Title:     "Les Misérables",
+		Authors:   []models.AuthorType{{Name: "Victor Hugo"}},
+		Publisher: "Le Livre de Poche",
+	})
+	return index
+}
+
+func TestSearch(t *testing.T) {
+	index := testIndex()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"etranger", "2070360024"},         // accents folded
+		{"l'étranger camus", "2070360024"}, // elision and author
+		{"miserable", "2253004227"},        // stemmed plural
+		{"camsu", "2070360024"},            // transposition typo
+		{"odersky progr", "0981531644"},    // prefix on the last term
+		{"definitive guide", "0981531644"}, // description, markdown stripped
+	}
+
+	for _, test := range tests {
+		hits := index.Search(test.query, 10)
+		if len(hits) == 0 || hits[0].Book.ID != test.want {
+			t.Errorf("Search(%q) = %v, want %s first", test.query, hits, test.want)
+		}
+	}
+
+	if hits := index.Search("harry potter", 10); len(hits) != 0 {
+		t.Errorf("Search(%q) = %v, want no hits", "harry potter", hits)
+	}
+}
+
+func TestAddReplacesBook(t *testing.T) {
+	index := testIndex()
+	index.Add(models.Book{ID: "2070360024", Title: "La Peste"})
+
+	if hits := index.Search("etranger", 10); len(hits) != 0 {
+		t.Errorf("old title still indexed: %v", hits)
+	}
+	if hits := index.Search("peste", 10); len(hits) != 1 {
+		t.Errorf("new title not indexed: %v", hits)
+	}
+	if index.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", index.Len())
+	}
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(book models.Book) {
+		t.Helper()
+		content, _ := json.Marshal(book)
+		if err := os.WriteFile(filepath.Join(dir, book.ID+".json"), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(models.Book{ID: "2070360024", Title: "L'Étranger"})
+	write(models.Book{ID: "2070360423", Title: "La Peste"})
+
+	index := NewIndex()
+	index.Add(models.Book{ID: "0981531644", Title: "Programming in Scala"}) // not from the directory
+	if loaded, err := index.LoadDirectory(dir); err != nil || loaded != 2 || index.Len() != 3 {
+		t.Fatalf("loaded %d books, %d indexed: %v", loaded, index.Len(), err)
+	}
+
+	// unchanged files are skipped, deleted ones leave the index
+	if err := os.Remove(filepath.Join(dir, "2070360423.json")); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := index.LoadDirectory(dir); err != nil || loaded != 0 {
+		t.Fatalf("reloaded %d books: %v", loaded, err)
+	}
+	if hits := index.Search("peste", 10); len(hits) != 0 || index.Len() != 2 {
+		t.Errorf("deleted book still indexed: %v", hits)
+	}
+	if hits := index.Search("etranger", 10); len(hits) != 1 {
+		t.Errorf("cached book dropped: %v", hits)
+	}
+}
@@ -0,0 +1,201 @@
+package search
+
+import (
+	"amazon/internal/utils"
+	"amazon/models"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	SUGGESTION_QUERY  = "query"
+	SUGGESTION_TITLE  = "title"
+	SUGGESTION_AUTHOR = "author"
+	SUGGESTION_SERIES = "series"
+
+	maxSuggestionTitleLength = 80 // longer titles are cut at their subtitle
+)
+
+// volumePattern finds the volume marker of a series title: "Solo Leveling T19",
+// "Valentina - Tome 04", "Astérix - Astérix en Lusitanie - n°41".
+var volumePattern = regexp.MustCompile(`(?i)(?:^|[\s,(-])(?:tome|t|vol|volume|n°|#)\.?\s*\d+\b`)
+
+type suggestionKey struct {
+	key   string
+	entry int
+}
+
+// Suggester completes prefixes from a fixed set of suggestions. Each one is
+// reachable from the start of any of its words, so "potter" finds
+// "Harry Potter".
+type Suggester struct {
+	entries []models.SearchSuggestion
+	keys    []suggestionKey // sorted by key
+}
+
+func NewSuggester(entries []models.SearchSuggestion) *Suggester {
+	suggester := &Suggester{entries: entries}
+
+	for i, entry := range entries {
+		words := strings.Fields(QueryKey(entry.Text))
+		for w := range words {
+			suggester.keys = append(suggester.keys, suggestionKey{strings.Join(words[w:], " "), i})
+		}
+	}
+
+	sort.Slice(suggester.keys, func(a, b int) bool {
+		return suggester.keys[a].key < suggester.keys[b].key
+	})
+
+	return suggester
+}
+
+// Suggest returns the heaviest suggestions completing prefix. Completions of
+// the start of a suggestion rank before completions of a later word.
+func (s *Suggester) Suggest(prefix string, limit int) []models.SearchSuggestion {
+	prefix = QueryKey(prefix)
+	if prefix == "" || s == nil {
+		return nil
+	}
+
+	type match struct {
+		entry   int
+		leading bool
+	}
+
+	matches := make(map[int]match)
+	first := sort.Search(len(s.keys), func(i int) bool { return s.keys[i].key >= prefix })
+	for i := first; i < len(s.keys) && strings.HasPrefix(s.keys[i].key, prefix); i++ {
+		key := s.keys[i]
+		leading := strings.HasPrefix(QueryKey(s.entries[key.entry].Text), prefix)
+		if existing, ok := matches[key.entry]; !ok || (leading && !existing.leading) {
+			matches[key.entry] = match{key.entry, leading}
+		}
+	}
+
+	ranked := make([]match, 0, len(matches))
+	for _, m := range matches {
+		ranked = append(ranked, m)
+	}
+
+	sort.Slice(ranked, func(a, b int) bool {
+		ea, eb := s.entries[ranked[a].entry], s.entries[ranked[b].entry]
+		if ranked[a].leading != ranked[b].leading {
+			return ranked[a].leading
+		}
+		if ea.Weight != eb.Weight {
+			return ea.Weight > eb.Weight
+		}
+		return ea.Text < eb.Text
+	})
+
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	suggestions := make([]models.SearchSuggestion, 0, len(ranked))
+	for _, m := range ranked {
+		suggestions = append(suggestions, s.entries[m.entry])
+	}
+	return suggestions
+}
+
+// Suggest completes prefix with the titles, authors and series of the
+// indexed books. The suggester is rebuilt after the index changes.
+func (i *Index) Suggest(prefix string, limit int) []models.SearchSuggestion {
+	i.mu.RLock()
+	suggester := i.suggester
+	i.mu.RUnlock()
+
+	if suggester == nil {
+		i.mu.Lock()
+		if i.suggester == nil {
+			i.suggester = NewSuggester(bookSuggestions(i.books))
+		}
+		suggester = i.suggester
+		i.mu.Unlock()
+	}
+
+	return suggester.Suggest(prefix, limit)
+}
+
+// bookSuggestions weighs authors and series by their number of books.
+func bookSuggestions(books map[string]models.Book) []models.SearchSuggestion {
+	entries := make([]models.SearchSuggestion, 0, len(books))
+	grouped := make(map[string]int) // kind and key -> entry
+
+	add := func(kind string, text string, bookID string) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+
+		group := kind + ":" + QueryKey(text)
+		if kind == SUGGESTION_TITLE {
+			group += ":" + bookID
+		}
+
+		if entry, ok := grouped[group]; ok {
+			entries[entry].Weight++
+			return
+		}
+
+		grouped[group] = len(entries)
+		entries = append(entries, models.SearchSuggestion{Text: text, Kind: kind, BookID: bookID, Weight: 1})
+	}
+
+	for _, book := range books {
+		add(SUGGESTION_TITLE, suggestionTitle(book.Title), book.ID)
+		for _, author := range book.Authors {
+			add(SUGGESTION_AUTHOR, author.Name, "")
+		}
+		add(SUGGESTION_SERIES, SeriesName(book.Title), "")
+	}
+
+	return entries
+}
+
+// SeriesName returns the series of a volume title, "" for standalone books:
+// "One Piece - Édition originale - Tome 01" is in the "One Piece" series.
+func SeriesName(title string) string {
+	location := volumePattern.FindStringIndex(title)
+	if location == nil || location[0] == 0 {
+		return ""
+	}
+
+	series := title[:location[0]]
+	if dash := strings.Index(series, " - "); dash > 0 {
+		series = series[:dash]
+	}
+
+	series = strings.TrimRightFunc(series, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("-,:(", r)
+	})
+	if len([]rune(series)) < 2 {
+		return ""
+	}
+	return series
+}
+
+// suggestionTitle drops the subtitle of long titles, the storefront box has
+// little room.
+func suggestionTitle(title string) string {
+	if len([]rune(title)) <= maxSuggestionTitleLength {
+		return title
+	}
+	if colon := strings.Index(title, ":"); colon > 0 {
+		return strings.TrimSpace(title[:colon])
+	}
+	return string([]rune(title)[:maxSuggestionTitleLength])
+}
+
+// QueryKey is the form queries and prefixes are compared in: lower case,
+// accents folded, punctuation dropped.
+func QueryKey(text string) string {
+	text = utils.FoldAccents(strings.ToLower(text))
+	return strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
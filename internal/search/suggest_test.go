@@ -0,0 +1,46 @@
+package search
+
+import (
+	"testing"
+
+	"amazon/models"
+)
+
+func TestSeriesName(t *testing.T) {
+	tests := map[string]string{
+		"One Piece - Édition originale - Tome 01: À l'aube d'une grande aventure": "One Piece",
+		"Solo Leveling T19":                        "Solo Leveling",
+		"Astérix - Astérix en Lusitanie - n°41":    "Astérix",
+		"Valentina - Tome 04":                      "Valentina",
+		"Le temps et la loi":                       "",
+		"Prépabac - Philo Tle générale - Bac 2026": "",
+	}
+
+	for title, want := range tests {
+		if got := SeriesName(title); got != want {
+			t.Errorf("SeriesName(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestIndexSuggest(t *testing.T) {
+	index := testIndex()
+	index.Add(models.Book{ID: "2344059710", Title: "One Piece - Édition originale - Tome 01"})
+	index.Add(models.Book{ID: "2344062339", Title: "One Piece - Édition originale - Tome 110"})
+
+	suggestions := index.Suggest("one pi", 5)
+	if len(suggestions) == 0 || suggestions[0].Kind != SUGGESTION_SERIES || suggestions[0].Text != "One Piece" {
+		t.Errorf("Suggest(one pi) = %+v, want the One Piece series first", suggestions)
+	}
+
+	// any word of a suggestion completes, accents folded
+	suggestions = index.Suggest("miser", 5)
+	if len(suggestions) != 1 || suggestions[0].BookID != "2253004227" {
+		t.Errorf("Suggest(miser) = %+v, want Les Misérables", suggestions)
+	}
+
+	suggestions = index.Suggest("cam", 5)
+	if len(suggestions) != 1 || suggestions[0].Kind != SUGGESTION_AUTHOR {
+		t.Errorf("Suggest(cam) = %+v, want Albert Camus", suggestions)
+	}
+}
@@ -0,0 +1,408 @@
+package services
+
+import (
+	"amazon/internal/scrapers/books"
+	"amazon/internal/utils"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	COVER_FORMAT_WEBP = "webp"
+	COVER_FORMAT_JPEG = "jpeg"
+
+	coverJPEGQuality     = 85
+	coverMaxOriginalSize = 15 * 1024 * 1024
+	placeholderWidth     = 200
+	placeholderHeight    = 300
+)
+
+// coverWidths are the only variants generated, requests are rounded up to the
+// next one so the cache stays bounded.
+var coverWidths = []int{160, 320, 500, 800, 1000, 1500}
+
+var ErrUnknownCoverProvider = errors.New("unknown cover provider")
+
+// coverProviders maps each provider to the URL of its full-size original.
+var coverProviders = map[string]func(id string) string{
+	"amazon": func(id string) string {
+		return fmt.Sprintf("%s/images/I/%s._SL1500_.jpg", utils.AMAZON_MEDIA_URL, id)
+	},
+	"google": books.MaxCoverURL,
+	"lireka": func(id string) string {
+		return "https://media.lireka.com/" + id
+	},
+}
+
+// Cover is one encoded variant, ready to be served.
+type Cover struct {
+	Data        []byte
+	ContentType string
+	Placeholder bool
+	ModTime     time.Time
+}
+
+type CoverService struct {
+	client *http.Client
+
+	// one lock per cover being produced, so an original is fetched once
+	locksMu sync.Mutex
+	locks   map[string]*coverLock
+}
+
+// coverLock is dropped from the map once no request holds or waits for it.
+type coverLock struct {
+	sync.Mutex
+	users int
+}
+
+func NewCoverService() *CoverService {
+	return &CoverService{
+		client: &http.Client{Timeout: 20 * time.Second},
+		locks:  make(map[string]*coverLock),
+	}
+}
+
+// lockCover takes the lock of a cover, the returned function releases it.
+func (s *CoverService) lockCover(key string) func() {
+	s.locksMu.Lock()
+	lock := s.locks[key]
+	if lock == nil {
+		lock = &coverLock{}
+		s.locks[key] = lock
+	}
+	lock.users++
+	s.locksMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		s.locksMu.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(s.locks, key)
+		}
+		s.locksMu.Unlock()
+	}
+}
+
+// GetCover returns the cover of a book resized to width and encoded in format.
+// The original is downloaded once and every variant is cached on disk. When the
+// upstream has no usable image a generated placeholder is returned instead.
+func (s *CoverService) GetCover(provider, id string, width int, format, title string) (*Cover, error) {
+	upstream, ok := coverProviders[provider]
+	if !ok {
+		return nil, ErrUnknownCoverProvider
+	}
+	if id == "" {
+		return nil, errors.New("cover id is required")
+	}
+
+	width = coverWidth(width)
+	if format != COVER_FORMAT_WEBP {
+		format = COVER_FORMAT_JPEG
+	}
+
+	sum := sha1.Sum([]byte(provider + "/" + id))
+	key := hex.EncodeToString(sum[:])[:20]
+	directory := filepath.Join(utils.COVERS_CACHE_DIRECTORY, provider, key)
+	variantPath := filepath.Join(directory, fmt.Sprintf("%d.%s", width, format))
+
+	if cover, err := readCover(variantPath, format); err == nil {
+		return cover, nil
+	}
+
+	unlock := s.lockCover(provider + "/" + id)
+	defer unlock()
+
+	// another request may have produced it while we waited
+	if cover, err := readCover(variantPath, format); err == nil {
+		return cover, nil
+	}
+
+	original, err := s.loadOriginal(upstream(id), filepath.Join(directory, "original"))
+	if err != nil {
+		utils.Report(fmt.Sprintf("Using placeholder cover for %s/%s: %s", provider, id, err.Error()))
+		return placeholderCover(provider+"/"+id, title, width, format)
+	}
+
+	data, err := encodeCover(resizeCover(original, width, false), format)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFileAtomic(variantPath, data); err != nil {
+		utils.Report("Failed to cache cover variant: " + err.Error())
+	}
+
+	return &Cover{Data: data, ContentType: coverContentType(format), ModTime: time.Now()}, nil
+}
+
+// loadOriginal decodes the stored original, downloading it first if needed.
+func (s *CoverService) loadOriginal(upstreamURL, path string) (image.Image, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		content, err = s.download(upstreamURL)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		path = ""
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode cover: %w", err)
+	}
+
+	// Amazon answers unknown image IDs with a 1x1 transparent gif
+	if img.Bounds().Dx() < 10 || img.Bounds().Dy() < 10 {
+		return nil, errors.New("upstream returned an empty image")
+	}
+
+	if path != "" {
+		if err := writeFileAtomic(path, content); err != nil {
+			utils.Report("Failed to store original cover: " + err.Error())
+		}
+	}
+
+	return img, nil
+}
+
+func (s *CoverService) download(upstreamURL string) ([]byte, error) {
+	resp, err := s.client.Get(upstreamURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream answered %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, coverMaxOriginalSize))
+}
+
+// CoverProxyURL rewrites a cover hosted by Amazon, Google Books or Lireka into a
+// /covers URL on our own server. Other URLs are returned unchanged.
+func CoverProxyURL(rawURL string, width int) string {
+	provider, id := parseCoverURL(rawURL)
+	if provider == "" {
+		return rawURL
+	}
+
+	return fmt.Sprintf("%s/covers/%s/%s?w=%d", publicURL, provider, url.PathEscape(id), width)
+}
+
+func parseCoverURL(rawURL string) (string, string) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "", ""
+	}
+
+	switch {
+	case strings.Contains(parsed.Host, "amazon"):
+		parts := strings.Split(parsed.Path, "/I/")
+		if len(parts) < 2 {
+			return "", ""
+		}
+		return "amazon", strings.Split(parts[1], ".")[0]
+
+	case parsed.Host == "books.google.com":
+		if id := parsed.Query().Get("id"); id != "" {
+			return "google", id
+		}
+		if parts := strings.Split(parsed.Path, "/frontcover/"); len(parts) == 2 && parts[1] != "" {
+			return "google", parts[1]
+		}
+
+	case parsed.Host == "media.lireka.com":
+		if id := strings.TrimPrefix(parsed.Path, "/"); id != "" {
+			return "lireka", id
+		}
+	}
+
+	return "", ""
+}
+
+func coverWidth(requested int) int {
+	if requested <= 0 {
+		return utils.COVER_IMG_SIZE
+	}
+
+	for _, width := range coverWidths {
+		if width >= requested {
+			return width
+		}
+	}
+	return coverWidths[len(coverWidths)-1]
+}
+
+func coverContentType(format string) string {
+	if format == COVER_FORMAT_WEBP {
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
+
+func readCover(path, format string) (*Cover, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cover{Data: data, ContentType: coverContentType(format), ModTime: info.ModTime()}, nil
+}
+
+// resizeCover scales img to width keeping its aspect ratio. Originals are never
+// upscaled unless allowUpscale is set.
+func resizeCover(img image.Image, width int, allowUpscale bool) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width && !allowUpscale {
+		return img
+	}
+
+	height := bounds.Dy() * width / bounds.Dx()
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Over, nil)
+
+	return resized
+}
+
+func encodeCover(img image.Image, format string) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	var err error
+	if format == COVER_FORMAT_WEBP {
+		// pure Go encoder, lossless only
+		err = nativewebp.Encode(&buffer, img, nil)
+	} else {
+		err = jpeg.Encode(&buffer, opaque(img), &jpeg.Options{Quality: coverJPEGQuality})
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode cover: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// opaque flattens transparent images (gif, png) on white before JPEG encoding.
+func opaque(img image.Image) image.Image {
+	if _, isGif := img.(*image.Paletted); !isGif {
+		if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+			return img
+		}
+	}
+
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return flat
+}
+
+// placeholderCover draws a plain cover, tinted from the book key, with the
+// title when one is known. It is never cached so a later upload can replace it.
+func placeholderCover(key, title string, width int, format string) (*Cover, error) {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	tint := hash.Sum32()
+
+	background := color.RGBA{R: 40 + uint8(tint)%80, G: 40 + uint8(tint>>8)%80, B: 60 + uint8(tint>>16)%80, A: 255}
+	frame := color.RGBA{R: background.R + 60, G: background.G + 60, B: background.B + 60, A: 255}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, placeholderWidth, placeholderHeight))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	inner := canvas.Bounds().Inset(10)
+	draw.Draw(canvas, inner, image.NewUniform(frame), image.Point{}, draw.Src)
+	draw.Draw(canvas, inner.Inset(2), image.NewUniform(background), image.Point{}, draw.Src)
+
+	drawer := &font.Drawer{
+		Dst:  canvas,
+		Src:  image.White,
+		Face: basicfont.Face7x13,
+	}
+
+	lines := wrapText(title, (inner.Dx()-20)/basicfont.Face7x13.Advance)
+	top := placeholderHeight/2 - len(lines)*basicfont.Face7x13.Height/2
+	for i, line := range lines {
+		lineWidth := drawer.MeasureString(line).Round()
+		drawer.Dot = fixed.P((placeholderWidth-lineWidth)/2, top+(i+1)*basicfont.Face7x13.Height)
+		drawer.DrawString(line)
+	}
+
+	data, err := encodeCover(resizeCover(canvas, width, true), format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cover{Data: data, ContentType: coverContentType(format), Placeholder: true, ModTime: time.Now()}, nil
+}
+
+// wrapText splits text into at most 8 lines of maxChars characters.
+func wrapText(text string, maxChars int) []string {
+	const maxLines = 8
+
+	var lines []string
+	var current string
+	for _, word := range strings.Fields(text) {
+		if current != "" && len([]rune(current))+1+len([]rune(word)) > maxChars {
+			lines = append(lines, current)
+			current = ""
+		}
+		if current != "" {
+			current += " "
+		}
+		current += word
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines-1], "...")
+	}
+	return lines
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"amazon/internal/utils"
+)
+
+func TestCoverWidth(t *testing.T) {
+	cases := map[int]int{0: utils.COVER_IMG_SIZE, 100: 160, 160: 160, 161: 320, 5000: 1500}
+	for requested, want := range cases {
+		if got := coverWidth(requested); got != want {
+			t.Errorf("coverWidth(%d) = %d, want %d", requested, got, want)
+		}
+	}
+}
+
+func TestResizeCover(t *testing.T) {
+	large := image.NewRGBA(image.Rect(0, 0, 1000, 1500))
+	if bounds := resizeCover(large, 320, false).Bounds(); bounds.Dx() != 320 || bounds.Dy() != 480 {
+		t.Errorf("resized = %v", bounds)
+	}
+
+	small := image.NewRGBA(image.Rect(0, 0, 100, 150))
+	if bounds := resizeCover(small, 320, false).Bounds(); bounds.Dx() != 100 {
+		t.Errorf("small cover upscaled to %v", bounds)
+	}
+	if bounds := resizeCover(small, 320, true).Bounds(); bounds.Dx() != 320 || bounds.Dy() != 480 {
+		t.Errorf("placeholder = %v", bounds)
+	}
+}
+
+func TestCoverProxyURL(t *testing.T) {
+	usePublicURL(t, "https://example.com")
+
+	cases := map[string]string{
+		"https://m.media-amazon.com/images/I/51abcDEF._SY300_.jpg":                "https://example.com/covers/amazon/51abcDEF?w=320",
+		"https://books.google.com/books/content?id=XYZ&printsec=frontcover&img=1": "https://example.com/covers/google/XYZ?w=320",
+		"https://media.lireka.com/a/b.jpg?resize=fit":                             "https://example.com/covers/lireka/a%2Fb.jpg?w=320",
+		"https://example.org/cover.jpg":                                           "https://example.org/cover.jpg",
+	}
+	for raw, want := range cases {
+		if got := CoverProxyURL(raw, 320); got != want {
+			t.Errorf("CoverProxyURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestGetCover(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	var original bytes.Buffer
+	cover := image.NewRGBA(image.Rect(0, 0, 600, 900))
+	for y := range 900 {
+		for x := range 600 {
+			cover.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 80, A: 255})
+		}
+	}
+	if err := png.Encode(&original, cover); err != nil {
+		t.Fatal(err)
+	}
+
+	var downloads atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/B1" {
+			http.NotFound(w, r)
+			return
+		}
+		downloads.Add(1)
+		w.Write(original.Bytes())
+	}))
+	t.Cleanup(upstream.Close)
+
+	coverProviders["test"] = func(id string) string { return upstream.URL + "/" + id }
+	t.Cleanup(func() { delete(coverProviders, "test") })
+
+	service := NewCoverService()
+	if _, err := service.GetCover("fnac", "B1", 320, COVER_FORMAT_JPEG, ""); !errors.Is(err, ErrUnknownCoverProvider) {
+		t.Fatalf("unknown provider: %v", err)
+	}
+
+	// concurrent requests download the original once
+	var wg sync.WaitGroup
+	covers := make([]*Cover, 8)
+	for i := range covers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			covers[i], _ = service.GetCover("test", "B1", 300, COVER_FORMAT_JPEG, "")
+		}()
+	}
+	wg.Wait()
+
+	for _, got := range covers {
+		if got == nil || got.Placeholder || got.ContentType != "image/jpeg" {
+			t.Fatalf("cover = %+v", got)
+		}
+		config, format, err := image.DecodeConfig(bytes.NewReader(got.Data))
+		if err != nil || format != "jpeg" || config.Width != 320 || config.Height != 480 {
+			t.Fatalf("cover is a %s of %dx%d: %v", format, config.Width, config.Height, err)
+		}
+	}
+
+	// other variants resize the stored original
+	webp, err := service.GetCover("test", "B1", 500, COVER_FORMAT_WEBP, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config, format, err := image.DecodeConfig(bytes.NewReader(webp.Data)); err != nil || format != "webp" || config.Width != 500 || webp.ContentType != "image/webp" {
+		t.Fatalf("webp cover is a %s %d wide: %v", format, config.Width, err)
+	}
+	if downloads.Load() != 1 {
+		t.Errorf("original downloaded %d times", downloads.Load())
+	}
+
+	missing, err := service.GetCover("test", "unknown", 320, COVER_FORMAT_JPEG, "Le Petit Prince")
+	if err != nil || !missing.Placeholder {
+		t.Fatalf("missing cover = %+v, %v", missing, err)
+	}
+
+	if len(service.locks) != 0 {
+		t.Errorf("%d cover locks left", len(service.locks))
+	}
+}
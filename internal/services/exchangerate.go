@@ -0,0 +1,189 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/internal/utils"
+	"amazon/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RATE_SOURCE_MANUAL = "manual"
+	RATE_SOURCE_CSV    = "csv"
+)
+
+var (
+	ErrNoExchangeRate = errors.New("no exchange rate in effect")
+
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	rateDateLayouts = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02", "02/01/2006"}
+)
+
+type ExchangeRateService struct{}
+
+func NewExchangeRateService() *ExchangeRateService {
+	return &ExchangeRateService{}
+}
+
+// RateAt returns the rate of currency in effect at the given time: the one
+// with the latest effective date before it, the latest recorded on a tie.
+func (s *ExchangeRateService) RateAt(currency string, at time.Time) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := database.DB.
+		Where("currency = ? AND effective_at <= ?", currency, at).
+		Order("effective_at DESC, id DESC").
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoExchangeRate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (s *ExchangeRateService) CurrentRate(currency string) (*models.ExchangeRate, error) {
+	return s.RateAt(currency, time.Now())
+}
+
+// History returns the rates of currency, latest effective date first,
+// including the ones scheduled in the future.
+func (s *ExchangeRateService) History(currency string, limit int) ([]models.ExchangeRate, error) {
+	rates := make([]models.ExchangeRate, 0)
+	err := database.DB.
+		Where("currency = ?", currency).
+		Order("effective_at DESC, id DESC").
+		Limit(limit).
+		Find(&rates).Error
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// SetRate records a rate, effective now when EffectiveAt is zero.
+func (s *ExchangeRateService) SetRate(rate *models.ExchangeRate, adminID string) error {
+	if rate.EffectiveAt.IsZero() {
+		rate.EffectiveAt = time.Now()
+	}
+	if err := validateExchangeRate(rate); err != nil {
+		return err
+	}
+
+	rate.ID = 0
+	rate.Source = RATE_SOURCE_MANUAL
+	rate.CreatedBy = adminID
+	return database.DB.Create(rate).Error
+}
+
+// ImportCSV records the rates of a CSV file with the columns effective_at,
+// rate and optionally currency (EUR by default) and note. A header row is
+// allowed. The file is imported entirely or not at all, and rates already
+// recorded are skipped so a file can be imported twice.
+func (s *ExchangeRateService) ImportCSV(file io.Reader, adminID string) (int, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, fmt.Errorf("invalid csv: %w", err)
+	}
+
+	var rates []models.ExchangeRate
+	for i, record := range records {
+		line := i + 1
+		if len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "") {
+			continue
+		}
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "effective_at") {
+			continue
+		}
+		if len(record) < 2 {
+			return 0, fmt.Errorf("line %d: expected effective_at,rate[,currency[,note]]", line)
+		}
+
+		rate := models.ExchangeRate{
+			Currency:  utils.DEFAULT_CURRENCY,
+			Source:    RATE_SOURCE_CSV,
+			CreatedBy: adminID,
+		}
+
+		if rate.EffectiveAt, err = parseRateDate(record[0]); err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rate.Rate, err = strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(record[1]), ",", "."), 64); err != nil {
+			return 0, fmt.Errorf("line %d: invalid rate %q", line, record[1])
+		}
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			rate.Currency = strings.ToUpper(strings.TrimSpace(record[2]))
+		}
+		if len(record) > 3 {
+			rate.Note = strings.TrimSpace(record[3])
+		}
+
+		if err := validateExchangeRate(&rate); err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+
+	imported := 0
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, rate := range rates {
+			var existing int64
+			err := tx.Model(&models.ExchangeRate{}).
+				Where("currency = ? AND effective_at = ? AND rate = ?", rate.Currency, rate.EffectiveAt, rate.Rate).
+				Count(&existing).Error
+			if err != nil {
+				return err
+			}
+			if existing > 0 {
+				continue
+			}
+
+			if err := tx.Create(&rate).Error; err != nil {
+				return err
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return imported, nil
+}
+
+func validateExchangeRate(rate *models.ExchangeRate) error {
+	if rate.Currency == "" {
+		rate.Currency = utils.DEFAULT_CURRENCY
+	}
+	if !currencyPattern.MatchString(rate.Currency) {
+		return fmt.Errorf("invalid currency %q, expected an ISO 4217 code such as EUR", rate.Currency)
+	}
+	if rate.Rate <= 0 {
+		return errors.New("rate must be greater than 0")
+	}
+	return nil
+}
+
+func parseRateDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range rateDateLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExchangeRateImport(t *testing.T) {
+	useTestDatabase(t)
+	service := NewExchangeRateService()
+
+	csv := `effective_at,rate,currency,note
+2025-01-01,255
+2025-02-01,"262,5",EUR,black market average
+2025-03-01,1.18,USD
+`
+
+	imported, err := service.ImportCSV(strings.NewReader(csv), "1")
+	if err != nil || imported != 3 {
+		t.Fatalf("ImportCSV = %d, %v", imported, err)
+	}
+
+	// importing the same file again adds nothing
+	if imported, err := service.ImportCSV(strings.NewReader(csv), "1"); err != nil || imported != 0 {
+		t.Errorf("second ImportCSV = %d, %v", imported, err)
+	}
+
+	// a bad line rejects the whole file
+	if _, err := service.ImportCSV(strings.NewReader("2025-04-01,270\n2025-05-01,abc\n"), "1"); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("invalid csv error = %v", err)
+	}
+
+	at := func(date string) float64 {
+		day, _ := time.ParseInLocation("2006-01-02", date, time.Local)
+		rate, err := service.RateAt("EUR", day)
+		if err != nil {
+			t.Fatalf("RateAt(%s): %v", date, err)
+		}
+		return rate.Rate
+	}
+
+	if rate := at("2025-01-15"); rate != 255 {
+		t.Errorf("rate on 2025-01-15 = %g, want 255", rate)
+	}
+	if rate := at("2025-02-01"); rate != 262.5 {
+		t.Errorf("rate on 2025-02-01 = %g, want 262.5", rate)
+	}
+	if _, err := service.RateAt("EUR", time.Date(2024, 12, 31, 0, 0, 0, 0, time.Local)); err != ErrNoExchangeRate {
+		t.Errorf("rate before the first one: %v", err)
+	}
+
+	history, err := service.History("EUR", 10)
+	if err != nil || len(history) != 2 || history[0].Rate != 262.5 {
+		t.Errorf("history = %+v, %v", history, err)
+	}
+}
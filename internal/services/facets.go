@@ -0,0 +1,257 @@
+package services
+
+import (
+	"amazon/internal/utils"
+	"amazon/models"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SEARCH_FACET_LANGUAGE  = "language"
+	SEARCH_FACET_PUBLISHER = "publisher"
+	SEARCH_FACET_AUTHOR    = "author"
+	SEARCH_FACET_PRICE     = "price"
+	SEARCH_FACET_PAGES     = "pages"
+	SEARCH_FACET_YEAR      = "year"
+	SEARCH_FACET_PROVIDER  = "provider"
+
+	maxFacetValues = 20 // publishers and authors, selected values are always kept
+)
+
+// facetBucket is one range of a numeric facet, Max excluded and 0 meaning no
+// upper bound.
+type facetBucket struct {
+	Key      string
+	Min, Max float64
+}
+
+// priceBuckets are in dinars, the prices customers pay.
+var priceBuckets = []facetBucket{
+	{"0-2000", 0, 2000},
+	{"2000-4000", 2000, 4000},
+	{"4000-6000", 4000, 6000},
+	{"6000-10000", 6000, 10000},
+	{"10000+", 10000, 0},
+}
+
+var pageBuckets = []facetBucket{
+	{"0-100", 0, 100},
+	{"100-200", 100, 200},
+	{"200-400", 200, 400},
+	{"400-800", 400, 800},
+	{"800+", 800, 0},
+}
+
+var yearPattern = regexp.MustCompile(`\b(1[5-9]\d\d|20\d\d)\b`)
+
+// SearchFilters holds the selected values of each facet, a hit must match one
+// of the values of every facet with a selection.
+type SearchFilters map[string][]string
+
+type searchFacet struct {
+	name   string
+	values func(hit models.SearchHit) []string
+	sorted bool // values keep their natural order instead of most frequent first
+	limit  int
+}
+
+// searchFacets in the order they are returned, customers mostly narrow by
+// language and price first.
+var searchFacets = []searchFacet{
+	{SEARCH_FACET_LANGUAGE, func(hit models.SearchHit) []string { return nonEmpty(hit.Language) }, false, 0},
+	{SEARCH_FACET_PRICE, func(hit models.SearchHit) []string {
+		if hit.LocalPrice <= 0 {
+			return nil
+		}
+		return nonEmpty(bucketOf(priceBuckets, hit.LocalPrice))
+	}, true, 0},
+	{SEARCH_FACET_AUTHOR, func(hit models.SearchHit) []string {
+		names := make([]string, 0, len(hit.Authors))
+		for _, author := range hit.Authors {
+			names = append(names, strings.TrimSpace(author.Name))
+		}
+		return nonEmpty(names...)
+	}, false, maxFacetValues},
+	{SEARCH_FACET_PUBLISHER, func(hit models.SearchHit) []string { return nonEmpty(strings.TrimSpace(hit.Publisher)) }, false, maxFacetValues},
+	{SEARCH_FACET_PAGES, func(hit models.SearchHit) []string {
+		if hit.Pages <= 0 {
+			return nil
+		}
+		return nonEmpty(bucketOf(pageBuckets, float64(hit.Pages)))
+	}, true, 0},
+	{SEARCH_FACET_YEAR, func(hit models.SearchHit) []string { return nonEmpty(yearPattern.FindString(hit.PubDate)) }, true, 0},
+	{SEARCH_FACET_PROVIDER, func(hit models.SearchHit) []string { return hit.Providers }, false, 0},
+}
+
+// IsSearchFacet reports whether name is a facet the search can filter on.
+func IsSearchFacet(name string) bool {
+	return slices.ContainsFunc(searchFacets, func(facet searchFacet) bool { return facet.name == name })
+}
+
+// ValidateSearchFilter checks the values given for a facet, numeric facets
+// only accept their bucket keys and years.
+func ValidateSearchFilter(name string, values []string) error {
+	for _, value := range values {
+		switch name {
+		case SEARCH_FACET_PRICE:
+			if bucketIndex(priceBuckets, value) < 0 {
+				return fmt.Errorf("invalid price range %q, expected one of %s", value, bucketKeys(priceBuckets))
+			}
+		case SEARCH_FACET_PAGES:
+			if bucketIndex(pageBuckets, value) < 0 {
+				return fmt.Errorf("invalid page range %q, expected one of %s", value, bucketKeys(pageBuckets))
+			}
+		case SEARCH_FACET_YEAR:
+			if !yearPattern.MatchString(value) || len(value) != 4 {
+				return fmt.Errorf("invalid year %q", value)
+			}
+		}
+	}
+	return nil
+}
+
+// FacetSearchHits keeps the hits matching filters and counts the values of
+// every facet. A facet is counted over the hits matching the other facets'
+// filters, so selecting "fr" still shows how many books are in English.
+func FacetSearchHits(hits []models.SearchHit, filters SearchFilters) ([]models.SearchHit, []models.SearchFacet) {
+	selected := make(map[string]map[string]bool)
+	for name, values := range filters {
+		if len(values) == 0 {
+			continue
+		}
+		selected[name] = make(map[string]bool)
+		for _, value := range values {
+			selected[name][facetKey(value)] = true
+		}
+	}
+
+	matches := func(hit models.SearchHit, except string) bool {
+		for _, facet := range searchFacets {
+			wanted, ok := selected[facet.name]
+			if !ok || facet.name == except {
+				continue
+			}
+			if !slices.ContainsFunc(facet.values(hit), func(value string) bool { return wanted[facetKey(value)] }) {
+				return false
+			}
+		}
+		return true
+	}
+
+	filtered := make([]models.SearchHit, 0, len(hits))
+	for _, hit := range hits {
+		if matches(hit, "") {
+			filtered = append(filtered, hit)
+		}
+	}
+
+	facets := make([]models.SearchFacet, 0, len(searchFacets))
+	for _, facet := range searchFacets {
+		counts := make(map[string]*models.SearchFacetValue)
+		for _, hit := range hits {
+			if !matches(hit, facet.name) {
+				continue
+			}
+
+			seen := make(map[string]bool)
+			for _, value := range facet.values(hit) {
+				key := facetKey(value)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				if counts[key] == nil {
+					counts[key] = &models.SearchFacetValue{Value: value, Selected: selected[facet.name][key]}
+				}
+				counts[key].Count++
+			}
+		}
+
+		facets = append(facets, models.SearchFacet{
+			Name:   facet.name,
+			Values: facet.order(counts),
+		})
+	}
+
+	return filtered, facets
+}
+
+// order sorts the counted values of a facet and cuts them to its limit.
+func (facet searchFacet) order(counts map[string]*models.SearchFacetValue) []models.SearchFacetValue {
+	values := make([]models.SearchFacetValue, 0, len(counts))
+	for _, value := range counts {
+		values = append(values, *value)
+	}
+
+	sort.Slice(values, func(a, b int) bool {
+		if facet.sorted {
+			return facetRank(facet.name, values[a].Value) < facetRank(facet.name, values[b].Value)
+		}
+		if values[a].Count != values[b].Count {
+			return values[a].Count > values[b].Count
+		}
+		return values[a].Value < values[b].Value
+	})
+
+	if facet.limit > 0 && len(values) > facet.limit {
+		kept := values[:facet.limit]
+		for _, value := range values[facet.limit:] {
+			if value.Selected {
+				kept = append(kept, value)
+			}
+		}
+		values = kept
+	}
+
+	return values
+}
+
+// facetRank is the natural position of a value: bucket order, newest year first.
+func facetRank(name string, value string) int {
+	switch name {
+	case SEARCH_FACET_PRICE:
+		return bucketIndex(priceBuckets, value)
+	case SEARCH_FACET_PAGES:
+		return bucketIndex(pageBuckets, value)
+	case SEARCH_FACET_YEAR:
+		year, _ := strconv.Atoi(value)
+		return -year
+	}
+	return 0
+}
+
+// facetKey compares facet values regardless of case and accents.
+func facetKey(value string) string {
+	return utils.FoldAccents(strings.ToLower(strings.TrimSpace(value)))
+}
+
+func bucketOf(buckets []facetBucket, value float64) string {
+	for _, bucket := range buckets {
+		if value >= bucket.Min && (bucket.Max == 0 || value < bucket.Max) {
+			return bucket.Key
+		}
+	}
+	return ""
+}
+
+func bucketIndex(buckets []facetBucket, key string) int {
+	return slices.IndexFunc(buckets, func(bucket facetBucket) bool { return bucket.Key == key })
+}
+
+func bucketKeys(buckets []facetBucket) string {
+	keys := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		keys = append(keys, bucket.Key)
+	}
+	return strings.Join(keys, ", ")
+}
+
+func nonEmpty(values ...string) []string {
+	return slices.DeleteFunc(values, func(value string) bool { return value == "" })
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"amazon/models"
+)
+
+func TestFacetSearchHits(t *testing.T) {
+	hits := []models.SearchHit{
+		{ID: "1", Language: "fr", LocalPrice: 1800, Pages: 150, PubDate: "2024-01-03T00:00:00+01:00", Publisher: "Gallimard", Providers: []string{"local", "amazon"}},
+		{ID: "2", Language: "fr", LocalPrice: 6500, Pages: 420, PubDate: "2019", Publisher: "gallimard", Providers: []string{"lireka"}},
+		{ID: "3", Language: "en", LocalPrice: 3100, Pages: 90, Publisher: "Artima", Providers: []string{"amazon"}},
+		{ID: "4", Language: "ar", Providers: []string{"google"}},
+	}
+
+	filtered, facets := FacetSearchHits(hits, SearchFilters{
+		SEARCH_FACET_LANGUAGE: {"fr"},
+		SEARCH_FACET_PRICE:    {"0-2000", "6000-10000"},
+	})
+
+	if len(filtered) != 2 || filtered[0].ID != "1" || filtered[1].ID != "2" {
+		t.Fatalf("filtered = %+v, want books 1 and 2", filtered)
+	}
+
+	counts := make(map[string]map[string]int)
+	for _, facet := range facets {
+		counts[facet.Name] = make(map[string]int)
+		for _, value := range facet.Values {
+			counts[facet.Name][value.Value] = value.Count
+		}
+	}
+
+	// languages are counted among the books in the selected price ranges
+	if counts[SEARCH_FACET_LANGUAGE]["fr"] != 2 || counts[SEARCH_FACET_LANGUAGE]["en"] != 0 {
+		t.Errorf("language facet = %v", counts[SEARCH_FACET_LANGUAGE])
+	}
+	// prices are counted among the French books
+	if counts[SEARCH_FACET_PRICE]["0-2000"] != 1 || counts[SEARCH_FACET_PRICE]["2000-4000"] != 0 {
+		t.Errorf("price facet = %v", counts[SEARCH_FACET_PRICE])
+	}
+	// publishers are grouped regardless of case
+	if counts[SEARCH_FACET_PUBLISHER]["Gallimard"] != 2 {
+		t.Errorf("publisher facet = %v", counts[SEARCH_FACET_PUBLISHER])
+	}
+	if counts[SEARCH_FACET_YEAR]["2024"] != 1 || counts[SEARCH_FACET_YEAR]["2019"] != 1 {
+		t.Errorf("year facet = %v", counts[SEARCH_FACET_YEAR])
+	}
+	if counts[SEARCH_FACET_PROVIDER]["amazon"] != 1 || counts[SEARCH_FACET_PROVIDER]["lireka"] != 1 {
+		t.Errorf("provider facet = %v", counts[SEARCH_FACET_PROVIDER])
+	}
+}
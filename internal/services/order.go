@@ -2,57 +2,185 @@ package services
 
 import (
 	"amazon/internal/database"
+	"amazon/internal/utils"
 	"amazon/models"
 	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type OrderService struct {
+	Pricing  *PricingService
+	Quotes   *QuoteService
+	Shipping *ShippingService
 }
 
 func NewOrderService() *OrderService {
-	return &OrderService{}
+	return &OrderService{
+		Pricing:  NewPricingService(),
+		Quotes:   NewQuoteService(),
+		Shipping: NewShippingService(),
+	}
 }
 
-func (s *OrderService) CreateOrder(order *models.Order) error {
+// CreateOrder places an order. An order submitted again with the same
+// IdempotencyKey isn't placed twice: order is replaced by the original and
+// created is false.
+func (s *OrderService) CreateOrder(order *models.Order) (created bool, err error) {
 	if order.Name == "" || order.Email == "" || order.Phone == "" || order.Address == "" {
-		return errors.New("missing required fields (name, email, phone, address)")
+		return false, errors.New("missing required fields (name, email, phone, address)")
+	}
+
+	if order.IdempotencyKey != nil {
+		original, err := orderByIdempotencyKey(*order.IdempotencyKey)
+		if err != nil {
+			return false, err
+		}
+		if original != nil {
+			return false, s.replay(original, order)
+		}
+	}
+
+	if err := s.ValidateItems(order.OrderItems); err != nil {
+		return false, err
+	}
+
+	// lines are new whatever the client sent, and bought once batched
+	for i := range order.OrderItems {
+		item := &order.OrderItems[i]
+		item.ID, item.OrderID, item.PurchaseBatchID = 0, 0, nil
+	}
+
+	order.Status = models.ORDER_STATUS_AWAITING_QUOTE
+	order.TrackingToken = utils.RandomToken(24)
+	order.QuoteTotal, order.QuoteNote = 0, ""
+	order.QuoteSentAt, order.QuoteAcceptedAt = nil, nil
+
+	// a probable duplicate is still placed, admins see the orders linked
+	order.DuplicateOfID = nil
+	duplicate, err := findDuplicate(order, time.Now())
+	if err != nil {
+		utils.Report("Can't look for duplicates of the order: " + err.Error())
+	}
+	if duplicate != nil {
+		order.DuplicateOfID = &duplicate.ID
+	}
+
+	// keep the rate of the day, the order is paid in dinars later
+	rate, err := s.Pricing.ExchangeRate()
+	if err != nil {
+		utils.Report("Can't record the exchange rate of the order: " + err.Error())
 	}
+	order.ExchangeRate = rate
 
-	order.Status = "new"
+	// prices are suggestions until an admin sends the quote
+	s.Quotes.SnapshotItems(order)
+
+	order.Fees, err = s.Pricing.OrderFees(order.OrderItems)
+	if err != nil {
+		utils.Report("Can't compute the fees of the order: " + err.Error())
+	}
+
+	// orders placed without a wilaya get their shipping cost in the quote
+	order.ShippingCost, order.ShippingWeight = 0, 0
+	if order.Wilaya != 0 || order.DeliveryMethod != "" {
+		shipping, err := s.Shipping.Quote(order.Wilaya, order.DeliveryMethod, order.OrderItems)
+		if err != nil {
+			return false, err
+		}
+		order.ShippingCost, order.ShippingWeight = shipping.Price, shipping.Weight
+	}
+	computeOrderTotals(order)
+
+	event := models.OrderEvent{
+		Type:     models.ORDER_EVENT_CREATED,
+		Actor:    models.ORDER_ACTOR_CUSTOMER,
+		ToStatus: order.Status,
+	}
+	if order.DuplicateOfID != nil {
+		event.Details = map[string]any{"duplicate_of": *order.DuplicateOfID}
+	}
 
 	// Save order
-	return database.DB.Create(order).Error
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		event.OrderID = order.ID
+		return recordOrderEvent(tx, &event)
+	})
+	if err != nil && order.IdempotencyKey != nil {
+		// the same submission may have been placed in the meantime
+		if original, _ := orderByIdempotencyKey(*order.IdempotencyKey); original != nil {
+			return false, s.replay(original, order)
+		}
+	}
+	return err == nil, err
+}
+
+func (s *OrderService) replay(original *models.Order, order *models.Order) error {
+	replayed, err := replayOrder(original, order)
+	if err != nil {
+		return err
+	}
+	*order = *replayed
+	return nil
+}
+
+// computeOrderTotals sums the lines of an order with its fees and shipping.
+func computeOrderTotals(order *models.Order) {
+	order.Subtotal = 0
+	for i := range order.OrderItems {
+		item := &order.OrderItems[i]
+		item.LineTotal = item.UnitPrice * float64(item.Quantity)
+		order.Subtotal += item.LineTotal
+	}
+	order.Total = order.Subtotal + order.Fees + order.ShippingCost
 }
 
 func (s *OrderService) GetOrderByID(id string) (*models.Order, error) {
 	var order models.Order
 	if err := database.DB.Preload("OrderItems").First(&order, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("order not found")
+			return nil, ErrOrderNotFound
 		}
 		return nil, err
 	}
-	return &order, nil
-}
 
-func (s *OrderService) GetAllOrders() ([]models.Order, error) {
-	var orders []models.Order
-	if err := database.DB.Preload("OrderItems").Find(&orders).Error; err != nil {
+	linked, err := s.LinkedOrders(&order)
+	if err != nil {
 		return nil, err
 	}
-
-	return orders, nil
+	order.LinkedOrderIDs = linked
+	return &order, nil
 }
 
-func (s *OrderService) DeleteOrder(id string) error {
-	if err := database.DB.Delete(&models.Order{}, "id = ?", id).Error; err != nil {
-		return err
-	}
-	return nil
+func (s *OrderService) DeleteOrder(id string, adminID string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Select("id", "status").First(&order, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		if err := tx.Delete(&order).Error; err != nil {
+			return err
+		}
+
+		event := adminEvent(adminID, "")
+		event.OrderID = order.ID
+		event.Type = models.ORDER_EVENT_DELETED
+		event.FromStatus = order.Status
+		return recordOrderEvent(tx, &event)
+	})
 }
 
+// GetOrdersByEmail lists the orders of a customer, for admins: customers
+// follow their orders with TrackOrder.
 func (s *OrderService) GetOrdersByEmail(email string) ([]models.Order, error) {
 	var orders []models.Order
 	if err := database.DB.Preload("OrderItems").Where("email = ?", email).Find(&orders).Error; err != nil {
@@ -61,13 +189,29 @@ func (s *OrderService) GetOrdersByEmail(email string) ([]models.Order, error) {
 	return orders, nil
 }
 
-func (s *OrderService) SetOrderStatus(id string, status string) error {
-	// Update order status
-	if err := database.DB.Model(&models.Order{}).Where("id = ?", id).Update("status", status).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("order not found")
+// SetOrderStatus moves an order along its lifecycle. Quotes are sent with
+// QuoteService.SendQuote, which emails the customer, so an order can't be
+// marked as quoted by hand. On ErrIllegalTransition the order is returned
+// too, to tell where it stands.
+func (s *OrderService) SetOrderStatus(id string, status string, adminID string, comment string) (*models.Order, error) {
+	if !IsOrderStatus(status) {
+		return nil, ErrUnknownOrderStatus
+	}
+
+	order, err := s.GetOrderByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == models.ORDER_STATUS_QUOTED {
+		return order, fmt.Errorf("%w: send the quote to mark the order as quoted", ErrIllegalTransition)
+	}
+
+	if err := transitionOrder(order, status, nil, adminEvent(adminID, comment)); err != nil {
+		if errors.Is(err, ErrIllegalTransition) {
+			return order, err
 		}
-		return err
+		return nil, err
 	}
-	return nil
+	return order, nil
 }
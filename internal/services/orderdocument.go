@@ -0,0 +1,433 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/models"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// MAX_SLIPS caps the orders of a bulk print of delivery slips.
+const MAX_SLIPS = 500
+
+var (
+	ErrNoSlips      = errors.New("no order matches, no slip to print")
+	ErrTooManySlips = fmt.Errorf("too many orders, at most %d slips are printed at once", MAX_SLIPS)
+)
+
+// Store is the shop as printed on the invoices and the delivery slips, set
+// with the STORE_NAME, STORE_ADDRESS, STORE_PHONE, STORE_EMAIL and
+// STORE_LEGAL (trade register and tax numbers) environment variables.
+type Store struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+	Legal   string
+}
+
+func CurrentStore() Store {
+	store := Store{
+		Name:    os.Getenv("STORE_NAME"),
+		Address: os.Getenv("STORE_ADDRESS"),
+		Phone:   os.Getenv("STORE_PHONE"),
+		Email:   os.Getenv("STORE_EMAIL"),
+		Legal:   os.Getenv("STORE_LEGAL"),
+	}
+	if store.Name == "" {
+		if public, err := url.Parse(publicURL); err == nil {
+			store.Name = public.Hostname()
+		}
+	}
+	return store
+}
+
+// WriteInvoice renders the invoice of an order as a PDF, with the prices
+// kept on its lines.
+func (s *OrderService) WriteInvoice(order *models.Order, w io.Writer) error {
+	doc := newOrderDocument(fmt.Sprintf("Facture n°%d", order.ID))
+	doc.invoice(order, s.Quotes)
+	return doc.output(w)
+}
+
+// WriteSlip renders the delivery slip of an order as a PDF.
+func (s *OrderService) WriteSlip(order *models.Order, w io.Writer) error {
+	doc := newOrderDocument(fmt.Sprintf("Bon de livraison n°%d", order.ID))
+	doc.slip(order, s.Quotes)
+	return doc.output(w)
+}
+
+// WriteSlips renders the delivery slips of the orders matching query in one
+// PDF, a page each, to print a shipping batch at once.
+func (s *OrderService) WriteSlips(query OrderQuery, w io.Writer) error {
+	if err := s.CheckSlips(query); err != nil {
+		return err
+	}
+
+	doc := newOrderDocument("Bons de livraison")
+	err := eachExportedOrder(query, func(order models.Order) error {
+		doc.slip(&order, s.Quotes)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return doc.output(w)
+}
+
+// CheckSlips tells whether the slips of the orders matching query can be
+// printed, before the response starts.
+func (s *OrderService) CheckSlips(query OrderQuery) error {
+	if _, _, err := query.sortColumn(); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := query.apply(database.DB.Model(&models.Order{})).Count(&count).Error; err != nil {
+		return err
+	}
+	switch {
+	case count == 0:
+		return ErrNoSlips
+	case count > MAX_SLIPS:
+		return ErrTooManySlips
+	}
+	return nil
+}
+
+// orderDocument lays out A4 pages in millimetres. The text goes through
+// printable, in a font embedded for the customers writing in Arabic.
+type orderDocument struct {
+	pdf   *fpdf.Fpdf
+	store Store
+}
+
+const (
+	documentMargin = 15.0
+	documentWidth  = 210 - 2*documentMargin
+	documentFont   = "DejaVu"
+	qrCodeSize     = 32.0
+)
+
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	documentRegularFont []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	documentBoldFont []byte
+)
+
+func newOrderDocument(title string) *orderDocument {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(documentMargin, documentMargin, documentMargin)
+	pdf.SetAutoPageBreak(true, documentMargin)
+	pdf.SetTitle(title, true)
+	pdf.AddUTF8FontFromBytes(documentFont, "", documentRegularFont)
+	pdf.AddUTF8FontFromBytes(documentFont, "B", documentBoldFont)
+
+	store := CurrentStore()
+	pdf.SetAuthor(store.Name, true)
+
+	return &orderDocument{
+		pdf:   pdf,
+		store: store,
+	}
+}
+
+func (d *orderDocument) output(w io.Writer) error {
+	return d.pdf.Output(w)
+}
+
+func (d *orderDocument) invoice(order *models.Order, quotes *QuoteService) {
+	pdf := d.pdf
+	pdf.AddPage()
+	d.header("FACTURE", fmt.Sprintf("n°%d", order.ID), order, true)
+
+	// customer and tracking code, side by side
+	top := pdf.GetY()
+	d.block("Facturé à", customerLines(order, true), documentWidth-qrCodeSize-10)
+	d.trackingCode(order, 210-documentMargin-qrCodeSize, top)
+	pdf.SetY(max(pdf.GetY(), top+qrCodeSize+6) + 4)
+
+	widths := []float64{96, 18, 33, 33}
+	d.tableHeader(widths, "Désignation", "Qté", "Prix unitaire", "Total")
+	for _, item := range order.OrderItems {
+		d.tableRow(widths, itemDescription(item, quotes), fmt.Sprint(item.Quantity), FormatDZD(item.UnitPrice), FormatDZD(item.LineTotal))
+	}
+
+	pdf.Ln(4)
+	d.total("Sous-total", FormatDZD(order.Subtotal), false)
+	if order.Fees > 0 {
+		d.total("Frais de service", FormatDZD(order.Fees), false)
+	}
+	d.total(deliveryTitle(order), FormatDZD(order.ShippingCost), false)
+	d.total("Total à payer", FormatDZD(order.Total), true)
+
+	pdf.Ln(8)
+	d.small("Montants en dinars algériens, payables à la livraison.")
+	if note := strings.TrimSpace(order.QuoteNote); note != "" {
+		d.small(note)
+	}
+}
+
+func (d *orderDocument) slip(order *models.Order, quotes *QuoteService) {
+	pdf := d.pdf
+	pdf.AddPage()
+	d.header("BON DE LIVRAISON", fmt.Sprintf("Commande n°%d", order.ID), order, false)
+
+	top := pdf.GetY()
+	half := (documentWidth - 6) / 2
+	d.block("Expéditeur", storeLines(d.store), half)
+	senderBottom := pdf.GetY()
+
+	pdf.SetXY(documentMargin+half+6, top)
+	pdf.SetLeftMargin(documentMargin + half + 6)
+	d.block("Destinataire", customerLines(order, false), half)
+	pdf.SetLeftMargin(documentMargin)
+	pdf.SetXY(documentMargin, max(pdf.GetY(), senderBottom)+4)
+
+	widths := []float64{documentWidth - 20, 20}
+	d.tableHeader(widths, "Article", "Qté")
+	count := 0
+	for _, item := range order.OrderItems {
+		d.tableRow(widths, itemDescription(item, quotes), fmt.Sprint(item.Quantity))
+		count += item.Quantity
+	}
+
+	pdf.Ln(4)
+	details := fmt.Sprintf("%d article(s)", count)
+	if order.ShippingWeight > 0 {
+		details += fmt.Sprintf(" - %s kg", strings.Replace(fmt.Sprintf("%.2f", order.ShippingWeight), ".", ",", 1))
+	}
+	d.small(details)
+
+	// the amount the courier collects, cash on delivery, kept on a page with
+	// the tracking code
+	pdf.Ln(4)
+	if _, pageHeight := pdf.GetPageSize(); pdf.GetY()+qrCodeSize+6 > pageHeight-documentMargin {
+		pdf.AddPage()
+	}
+	top = pdf.GetY()
+	pdf.SetFont(documentFont, "B", 14)
+	pdf.SetLineWidth(0.6)
+	pdf.CellFormat(documentWidth-qrCodeSize-10, 14, printable("Montant à encaisser : "+FormatDZD(order.Total)), "1", 1, "C", false, 0, "")
+	pdf.SetLineWidth(0.2)
+	d.trackingCode(order, 210-documentMargin-qrCodeSize, top)
+}
+
+// header prints the store on the left, with its details when they are not
+// printed further down, and the document's title on the right.
+func (d *orderDocument) header(title string, number string, order *models.Order, details bool) {
+	pdf := d.pdf
+	top := pdf.GetY()
+
+	pdf.SetFont(documentFont, "B", 16)
+	pdf.CellFormat(documentWidth/2, 8, printable(d.store.Name), "", 2, "L", false, 0, "")
+	if details {
+		pdf.SetFont(documentFont, "", 9)
+		for _, line := range storeLines(d.store)[1:] {
+			d.paragraph(documentWidth/2, 4.5, line)
+		}
+	}
+	storeBottom := pdf.GetY()
+
+	pdf.SetXY(documentMargin+documentWidth/2, top)
+	pdf.SetFont(documentFont, "B", 18)
+	pdf.CellFormat(documentWidth/2, 8, printable(title), "", 2, "R", false, 0, "")
+	pdf.SetFont(documentFont, "", 10)
+	pdf.CellFormat(documentWidth/2, 5, printable(number), "", 2, "R", false, 0, "")
+	pdf.CellFormat(documentWidth/2, 5, printable("Commande du "+order.CreatedAt.Format("02/01/2006")), "", 2, "R", false, 0, "")
+	pdf.CellFormat(documentWidth/2, 5, printable("Édité le "+time.Now().Format("02/01/2006")), "", 2, "R", false, 0, "")
+
+	pdf.SetXY(documentMargin, max(pdf.GetY(), storeBottom)+4)
+	pdf.Line(documentMargin, pdf.GetY(), documentMargin+documentWidth, pdf.GetY())
+	pdf.Ln(6)
+}
+
+// block prints a titled list of lines at the current position.
+func (d *orderDocument) block(title string, lines []string, width float64) {
+	pdf := d.pdf
+	x := pdf.GetX()
+
+	pdf.SetFont(documentFont, "B", 9)
+	pdf.SetTextColor(110, 110, 110)
+	pdf.CellFormat(width, 5, printable(strings.ToUpper(title)), "", 2, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+
+	for i, line := range lines {
+		if i == 0 {
+			pdf.SetFont(documentFont, "B", 12)
+		} else {
+			pdf.SetFont(documentFont, "", 10)
+		}
+		pdf.SetX(x)
+		d.paragraph(width, 5.5, line)
+	}
+}
+
+func (d *orderDocument) tableHeader(widths []float64, titles ...string) {
+	pdf := d.pdf
+	pdf.SetFont(documentFont, "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	for i, title := range titles {
+		pdf.CellFormat(widths[i], 7, printable(title), "1", 0, cellAlign(i), true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// tableRow prints a row, the first cell wrapping over as many lines as needed.
+func (d *orderDocument) tableRow(widths []float64, cells ...string) {
+	pdf := d.pdf
+	pdf.SetFont(documentFont, "", 10)
+
+	const lineHeight = 5.5
+	lines := d.splitText(cells[0], widths[0]-2)
+	height := lineHeight * float64(max(len(lines), 1))
+
+	_, pageHeight := pdf.GetPageSize()
+	if pdf.GetY()+height > pageHeight-documentMargin {
+		pdf.AddPage()
+	}
+
+	x, y := pdf.GetX(), pdf.GetY()
+	pdf.MultiCell(widths[0], lineHeight, strings.Join(lines, "\n"), "1", "L", false)
+	pdf.SetXY(x+widths[0], y)
+	for i, cell := range cells[1:] {
+		pdf.CellFormat(widths[i+1], height, printable(cell), "1", 0, cellAlign(i+1), false, 0, "")
+	}
+	pdf.SetXY(x, y+height)
+}
+
+// splitText wraps text to width, the lines ready to print. Arabic is wrapped
+// before it is reversed, so the lines of a paragraph keep their order.
+func (d *orderDocument) splitText(text string, width float64) []string {
+	lines := d.pdf.SplitText(encodable(text), width)
+	for i, line := range lines {
+		lines[i] = printable(line)
+	}
+	return lines
+}
+
+// paragraph prints text wrapped to width at the current position.
+func (d *orderDocument) paragraph(width float64, lineHeight float64, text string) {
+	lines := d.splitText(text, width)
+	d.pdf.MultiCell(width, lineHeight, strings.Join(lines, "\n"), "", "L", false)
+}
+
+// cellAlign left aligns the first column, quantities are centred and
+// amounts right aligned.
+func cellAlign(column int) string {
+	switch column {
+	case 0:
+		return "LM"
+	case 1:
+		return "CM"
+	}
+	return "RM"
+}
+
+func (d *orderDocument) total(label string, amount string, bold bool) {
+	pdf := d.pdf
+	style, size := "", 10.0
+	if bold {
+		style, size = "B", 12
+	}
+	pdf.SetFont(documentFont, style, size)
+	pdf.SetX(documentMargin + documentWidth - 110)
+	pdf.CellFormat(75, 6.5, printable(label), "", 0, "R", false, 0, "")
+	pdf.CellFormat(35, 6.5, printable(amount), "", 1, "R", false, 0, "")
+}
+
+func (d *orderDocument) small(text string) {
+	d.pdf.SetFont(documentFont, "", 9)
+	d.paragraph(documentWidth, 4.5, text)
+}
+
+// trackingCode draws a QR code of the order's tracking link at x, y, the
+// modules as vector squares so it stays sharp when printed.
+func (d *orderDocument) trackingCode(order *models.Order, x float64, y float64) {
+	if order.TrackingToken == "" {
+		return
+	}
+	code, err := qrcode.New(TrackingURL(order.TrackingToken), qrcode.Medium)
+	if err != nil {
+		d.pdf.SetError(err)
+		return
+	}
+	code.DisableBorder = true
+
+	pdf := d.pdf
+	bitmap := code.Bitmap()
+	module := qrCodeSize / float64(len(bitmap))
+	pdf.SetFillColor(0, 0, 0)
+	for row, modules := range bitmap {
+		for column, dark := range modules {
+			if dark {
+				pdf.Rect(x+float64(column)*module, y+float64(row)*module, module, module, "F")
+			}
+		}
+	}
+
+	pdf.SetFont(documentFont, "", 8)
+	pdf.SetXY(x-4, y+qrCodeSize+1)
+	pdf.CellFormat(qrCodeSize+8, 4, printable("Suivre la commande"), "", 0, "C", false, 0, "")
+}
+
+// storeLines are the store's details, its name first.
+func storeLines(store Store) []string {
+	lines := []string{store.Name}
+	for _, line := range strings.Split(store.Address, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if store.Phone != "" {
+		lines = append(lines, "Tél. "+store.Phone)
+	}
+	if store.Email != "" {
+		lines = append(lines, store.Email)
+	}
+	if store.Legal != "" {
+		lines = append(lines, store.Legal)
+	}
+	return lines
+}
+
+// customerLines are the customer's details, their name first. Slips print
+// the delivery method for the courier, invoices the email.
+func customerLines(order *models.Order, invoice bool) []string {
+	lines := []string{order.Name}
+	if address := strings.TrimSpace(order.Address); address != "" {
+		lines = append(lines, address)
+	}
+	if wilaya := wilayaLabel(order.Wilaya); wilaya != "" {
+		lines = append(lines, wilaya)
+	}
+	if order.Phone != "" {
+		lines = append(lines, "Tél. "+order.Phone)
+	}
+	if invoice {
+		if order.Email != "" {
+			lines = append(lines, order.Email)
+		}
+	} else if order.DeliveryMethod != "" {
+		lines = append(lines, "Livraison : "+deliveryLabel(order.DeliveryMethod))
+	}
+	return lines
+}
+
+func itemDescription(item models.OrderItem, quotes *QuoteService) string {
+	title := quotes.itemTitle(item)
+	if item.Author != "" {
+		title += " - " + item.Author
+	}
+	return title
+}
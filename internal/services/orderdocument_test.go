@@ -0,0 +1,115 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"amazon/internal/database"
+	"amazon/models"
+)
+
+func TestOrderDocuments(t *testing.T) {
+	useTestDatabase(t)
+	t.Setenv("STORE_NAME", "Librairie d'Alger")
+	t.Setenv("STORE_PHONE", "021 00 00 00")
+	usePublicURL(t, "https://example.com")
+	service := NewOrderService()
+
+	order := models.Order{
+		Name: "Amine Benali أمين", Phone: "0555", Email: "amine@example.com", Address: "12 rue Didouche Mourad",
+		Wilaya: 16, DeliveryMethod: models.DELIVERY_HOME, Status: models.ORDER_STATUS_RECEIVED, TrackingToken: "abc",
+		Subtotal: 6300, Fees: 200, ShippingCost: 800, Total: 7300,
+		OrderItems: []models.OrderItem{
+			{ItemType: "book", ItemID: "B1", Title: "L'Étranger", Author: "Albert Camus", Quantity: 1, UnitPrice: 3400, LineTotal: 3400},
+			{ItemType: "book", ItemID: "B2", Title: "Zadig", Quantity: 1, UnitPrice: 2900, LineTotal: 2900},
+		},
+	}
+	other := models.Order{Name: "Sara", Status: models.ORDER_STATUS_RECEIVED, TrackingToken: "def", Total: 500}
+	for _, seed := range []*models.Order{&order, &other} {
+		if err := database.DB.Create(seed).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// uncompressed, to read the text drawn
+	render := func(draw func(d *orderDocument)) string {
+		t.Helper()
+		doc := newOrderDocument("test")
+		doc.pdf.SetCompression(false)
+		draw(doc)
+
+		var out bytes.Buffer
+		if err := doc.output(&out); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(out.String(), "%PDF-") {
+			t.Fatalf("not a PDF: %q", out.String()[:20])
+		}
+		return out.String()
+	}
+
+	invoice := render(func(d *orderDocument) { d.invoice(&order, service.Quotes) })
+	for _, text := range []string{
+		"FACTURE", "Librairie d'Alger", "Tél. 021 00 00 00", "Amine Benali أمين", "16 - Alger",
+		"L'Étranger - Albert Camus", "3 400 DA", "Frais de service", "Livraison à domicile (Alger)", "Total à payer", "7 300 DA",
+	} {
+		if !strings.Contains(invoice, pdfText(text)) {
+			t.Errorf("the invoice lacks %s", text)
+		}
+	}
+	if strings.Count(invoice, " re f") < 100 {
+		t.Error("the invoice lacks the QR code")
+	}
+
+	slip := render(func(d *orderDocument) { d.slip(&order, service.Quotes) })
+	for _, text := range []string{"BON DE LIVRAISON", "DESTINATAIRE", "Livraison : Domicile", "Montant à encaisser : 7 300 DA"} {
+		if !strings.Contains(slip, pdfText(text)) {
+			t.Errorf("the slip lacks %s", text)
+		}
+	}
+	if strings.Contains(slip, pdfText("3 400 DA")) {
+		t.Error("the slip shows the prices")
+	}
+
+	var slips bytes.Buffer
+	if err := service.WriteSlips(OrderQuery{Statuses: []string{models.ORDER_STATUS_RECEIVED}, Sort: "created_at"}, &slips); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(slips.String(), "/Count 2") {
+		t.Error("expected a slip per order")
+	}
+
+	if err := service.WriteSlips(OrderQuery{IDs: []uint{9999}}, &slips); !errors.Is(err, ErrNoSlips) {
+		t.Errorf("no order: %v", err)
+	}
+}
+
+func TestPrintable(t *testing.T) {
+	cases := []struct{ text, want string }{
+		{"L'Étranger", "L'Étranger"},
+		{"سلام", "\uFEE1\uFEFC\uFEB3"},                           // lam-alef ligature, read right to left
+		{"12 شارع", "12 \uFEC9\uFEAD\uFE8E\uFEB7"},               // the number stays first
+		{"بَ", "\uFE8F\u064E"},                                   // the vowel sign after its letter
+		{"محمد علي", printable("علي") + " " + printable("محمد")}, // words in reverse order
+		{"Colis 📦", "Colis ?"},
+	}
+	for _, tc := range cases {
+		if got := printable(tc.text); got != tc.want {
+			t.Errorf("printable(%q) = %+q, want %+q", tc.text, got, tc.want)
+		}
+	}
+}
+
+// pdfText encodes a line the way the embedded fonts print it: UTF-16 with
+// the parentheses escaped.
+func pdfText(text string) string {
+	var encoded strings.Builder
+	for _, unit := range utf16.Encode([]rune(printable(text))) {
+		encoded.WriteByte(byte(unit >> 8))
+		encoded.WriteByte(byte(unit))
+	}
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`).Replace(encoded.String())
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/internal/utils"
+	"amazon/models"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrIdempotencyKeyReused = errors.New("this Idempotency-Key was already used for another order")
+
+// DuplicateOrderWindow is how far back a new order is compared to the orders
+// of the same customer, 0 disables the detection. Set at startup.
+var DuplicateOrderWindow = utils.DUPLICATE_ORDER_WINDOW
+
+// orderByIdempotencyKey returns the order submitted with key, nil when none
+// was.
+func orderByIdempotencyKey(key string) (*models.Order, error) {
+	var order models.Order
+	err := database.DB.Unscoped().Preload("OrderItems").Where("idempotency_key = ?", key).First(&order).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// replayOrder returns the order a repeated submission refers to, refusing a
+// key reused with another customer or other items.
+func replayOrder(original *models.Order, order *models.Order) (*models.Order, error) {
+	if !strings.EqualFold(strings.TrimSpace(original.Email), strings.TrimSpace(order.Email)) ||
+		itemsSignature(original.OrderItems) != itemsSignature(order.OrderItems) {
+		return nil, ErrIdempotencyKeyReused
+	}
+	return original, nil
+}
+
+// findDuplicate returns the latest order of the same phone or email with the
+// same items placed within DuplicateOrderWindow, nil when there is none.
+func findDuplicate(order *models.Order, now time.Time) (*models.Order, error) {
+	if DuplicateOrderWindow <= 0 {
+		return nil, nil
+	}
+
+	var candidates []models.Order
+	err := database.DB.Preload("OrderItems").
+		Where("phone = ? OR LOWER(email) = LOWER(?)", strings.TrimSpace(order.Phone), strings.TrimSpace(order.Email)).
+		Where("created_at >= ?", now.Add(-DuplicateOrderWindow)).
+		Order("created_at DESC, id DESC").
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	signature := itemsSignature(order.OrderItems)
+	for i := range candidates {
+		if itemsSignature(candidates[i].OrderItems) == signature {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// itemsSignature identifies what was ordered, whatever the order of the items.
+func itemsSignature(items []models.OrderItem) string {
+	quantities := make(map[string]int, len(items))
+	for _, item := range items {
+		quantities[item.ItemType+":"+strings.TrimSpace(item.ItemID)] += item.Quantity
+	}
+
+	lines := make([]string, 0, len(quantities))
+	for key, quantity := range quantities {
+		lines = append(lines, fmt.Sprintf("%s×%d", key, quantity))
+	}
+	slices.Sort(lines)
+	return strings.Join(lines, ",")
+}
+
+// LinkedOrders returns the IDs of the probable duplicates of an order: the
+// order it repeats and the orders repeating it.
+func (s *OrderService) LinkedOrders(order *models.Order) ([]uint, error) {
+	var ids []uint
+	if err := database.DB.Model(&models.Order{}).Where("duplicate_of_id = ?", order.ID).Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if order.DuplicateOfID != nil {
+		ids = append([]uint{*order.DuplicateOfID}, ids...)
+	}
+	return ids, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"amazon/models"
+)
+
+func TestIdempotentOrders(t *testing.T) {
+	useTestDatabase(t)
+	currentPricingRules.rules = nil
+	t.Cleanup(func() { currentPricingRules.rules = nil })
+
+	orders := NewOrderService()
+	orders.Quotes.FetchBook = func(provider, id string) (*models.Book, string, error) {
+		return &models.Book{ID: id, Title: "Book " + id}, "", nil
+	}
+
+	newOrder := func(key string, email string, quantity int) *models.Order {
+		order := &models.Order{
+			Name: "Amine", Email: email, Phone: "0555", Address: "Alger",
+			OrderItems: []models.OrderItem{{ItemType: "book", ItemID: "B1", Quantity: quantity}},
+		}
+		if key != "" {
+			order.IdempotencyKey = &key
+		}
+		return order
+	}
+
+	first := newOrder("checkout-1", "amine@example.com", 1)
+	if created, err := orders.CreateOrder(first); err != nil || !created {
+		t.Fatalf("first submission: %v, %v", created, err)
+	}
+
+	again := newOrder("checkout-1", "Amine@example.com", 1)
+	created, err := orders.CreateOrder(again)
+	if err != nil || created || again.ID != first.ID {
+		t.Fatalf("repeated submission: %v, %v, order %d", created, err, again.ID)
+	}
+
+	if _, err := orders.CreateOrder(newOrder("checkout-1", "amine@example.com", 3)); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("key reused for other items: %v", err)
+	}
+
+	// without the key, the same items from the same customer are flagged
+	duplicate := newOrder("", "other@example.com", 1)
+	if created, err := orders.CreateOrder(duplicate); err != nil || !created {
+		t.Fatalf("duplicate: %v, %v", created, err)
+	}
+	if duplicate.DuplicateOfID == nil || *duplicate.DuplicateOfID != first.ID {
+		t.Fatalf("duplicate of = %v", duplicate.DuplicateOfID)
+	}
+
+	// the customer isn't told about the other orders
+	view, err := orders.CustomerView(duplicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded, _ := json.Marshal(view); strings.Contains(string(encoded), "duplicate") || strings.Contains(string(encoded), "linked") {
+		t.Fatalf("customer view = %s", encoded)
+	}
+
+	different := newOrder("", "amine@example.com", 2)
+	if _, err := orders.CreateOrder(different); err != nil || different.DuplicateOfID != nil {
+		t.Fatalf("other items flagged: %v, %v", err, different.DuplicateOfID)
+	}
+
+	stored, err := orders.GetOrderByID(fmt.Sprint(first.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.LinkedOrderIDs) != 1 || stored.LinkedOrderIDs[0] != duplicate.ID {
+		t.Fatalf("linked orders = %v", stored.LinkedOrderIDs)
+	}
+
+	// outside the window orders are not compared
+	previous := DuplicateOrderWindow
+	DuplicateOrderWindow = 0
+	t.Cleanup(func() { DuplicateOrderWindow = previous })
+	late := newOrder("", "amine@example.com", 1)
+	if _, err := orders.CreateOrder(late); err != nil || late.DuplicateOfID != nil {
+		t.Fatalf("detection disabled: %v, %v", err, late.DuplicateOfID)
+	}
+}
+
+func TestItemsSignature(t *testing.T) {
+	a := []models.OrderItem{{ItemType: "book", ItemID: "B1", Quantity: 1}, {ItemType: "book", ItemID: "B2", Quantity: 2}}
+	b := []models.OrderItem{{ItemType: "book", ItemID: "B2", Quantity: 1}, {ItemType: "book", ItemID: "B1", Quantity: 1}, {ItemType: "book", ItemID: "B2", Quantity: 1}}
+	if itemsSignature(a) != itemsSignature(b) {
+		t.Errorf("%q != %q", itemsSignature(a), itemsSignature(b))
+	}
+	if itemsSignature(a) == itemsSignature(a[:1]) {
+		t.Error("different items share a signature")
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/models"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrEmptyNote = errors.New("the note is empty")
+
+type OrderEventService struct{}
+
+func NewOrderEventService() *OrderEventService {
+	return &OrderEventService{}
+}
+
+// History returns the events of an order, oldest first. Deleted orders keep
+// their history.
+func (s *OrderEventService) History(orderID string) ([]models.OrderEvent, error) {
+	var order models.Order
+	if err := database.DB.Unscoped().Select("id").First(&order, "id = ?", orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	events := make([]models.OrderEvent, 0)
+	if err := database.DB.Where("order_id = ?", order.ID).Order("created_at, id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// AddNote records a comment of an admin in the history of an order.
+func (s *OrderEventService) AddNote(orderID string, adminID string, comment string) (*models.OrderEvent, error) {
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return nil, ErrEmptyNote
+	}
+
+	var order models.Order
+	if err := database.DB.Select("id").First(&order, "id = ?", orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	event := models.OrderEvent{
+		OrderID: order.ID,
+		Type:    models.ORDER_EVENT_NOTE,
+		Actor:   models.ORDER_ACTOR_ADMIN,
+		AdminID: adminID,
+		Comment: comment,
+	}
+	if err := recordOrderEvent(database.DB, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func recordOrderEvent(tx *gorm.DB, event *models.OrderEvent) error {
+	if event.Actor == "" {
+		event.Actor = models.ORDER_ACTOR_SYSTEM
+	}
+	return tx.Create(event).Error
+}
+
+// adminEvent starts an event made by an admin.
+func adminEvent(adminID string, comment string) models.OrderEvent {
+	return models.OrderEvent{
+		Actor:   models.ORDER_ACTOR_ADMIN,
+		AdminID: adminID,
+		Comment: strings.TrimSpace(comment),
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"amazon/internal/database"
+	"amazon/models"
+)
+
+func TestOrderHistory(t *testing.T) {
+	useTestDatabase(t)
+	orders := NewOrderService()
+	events := NewOrderEventService()
+
+	order := models.Order{Name: "Amine", Email: "amine@example.com", Status: models.ORDER_STATUS_AWAITING_QUOTE}
+	if err := database.DB.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	id := fmt.Sprint(order.ID)
+
+	if _, err := orders.SetOrderStatus(id, models.ORDER_STATUS_CANCELLED, "7", " cancelled by phone "); err != nil {
+		t.Fatal(err)
+	}
+	// refused moves leave no trace
+	if _, err := orders.SetOrderStatus(id, models.ORDER_STATUS_DELIVERED, "7", ""); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatal(err)
+	}
+	if _, err := events.AddNote(id, "8", "  "); !errors.Is(err, ErrEmptyNote) {
+		t.Fatalf("empty note: %v", err)
+	}
+	added, err := events.AddNote(id, "8", "Customer called")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.ID == 0 || added.CreatedAt.IsZero() {
+		t.Fatalf("added note = %+v", added)
+	}
+	if err := orders.DeleteOrder(id, "7"); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := events.History(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("history = %+v", history)
+	}
+
+	status, note, deleted := history[0], history[1], history[2]
+	if status.Type != models.ORDER_EVENT_STATUS || status.AdminID != "7" || status.Comment != "cancelled by phone" ||
+		status.FromStatus != models.ORDER_STATUS_AWAITING_QUOTE || status.ToStatus != models.ORDER_STATUS_CANCELLED {
+		t.Errorf("status event = %+v", status)
+	}
+	if note.ID != added.ID || note.Type != models.ORDER_EVENT_NOTE || note.AdminID != "8" || note.Comment != "Customer called" {
+		t.Errorf("note event = %+v", note)
+	}
+	if deleted.Type != models.ORDER_EVENT_DELETED || deleted.Actor != models.ORDER_ACTOR_ADMIN {
+		t.Errorf("deleted event = %+v", deleted)
+	}
+
+	if _, err := events.History("9999"); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("unknown order: %v", err)
+	}
+	if _, err := events.AddNote(id, "8", "too late"); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("note on a deleted order: %v", err)
+	}
+}
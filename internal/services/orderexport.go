@@ -0,0 +1,201 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/models"
+	"amazon/sheet"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Column presets of the order exports.
+const (
+	EXPORT_ORDERS   = "orders"   // one row per order
+	EXPORT_ITEMS    = "items"    // one row per item
+	EXPORT_SUPPLIER = "supplier" // the books to buy from the provider, grouped by book
+	EXPORT_COURIER  = "courier"  // the manifest of the courier, with the amount to collect
+)
+
+var ExportPresets = []string{EXPORT_ORDERS, EXPORT_ITEMS, EXPORT_SUPPLIER, EXPORT_COURIER}
+
+var ErrUnknownExportPreset = errors.New("unknown export preset, expected orders, items, supplier or courier")
+
+// orderExportBatch is how many orders are loaded at once while exporting.
+const orderExportBatch = 200
+
+// CheckExport validates an export before anything is written, errors can't be
+// answered once the file is streaming.
+func CheckExport(query OrderQuery, preset string) error {
+	if !slices.Contains(ExportPresets, preset) {
+		return ErrUnknownExportPreset
+	}
+	_, _, err := query.sortColumn()
+	return err
+}
+
+// ExportOrders writes the orders matching query to out with the columns of a
+// preset. Pagination of query is ignored, every matching order is written.
+func (s *OrderService) ExportOrders(query OrderQuery, preset string, out sheet.Writer) error {
+	if err := CheckExport(query, preset); err != nil {
+		return err
+	}
+
+	switch preset {
+	case EXPORT_ITEMS:
+		err := out.WriteRow("Commande", "Date", "Statut", "Client", "Type", "ID", "Titre", "Auteur", "Fournisseur", "Disponibilité", "Quantité", "Prix unitaire", "Total ligne")
+		if err != nil {
+			return err
+		}
+		return eachExportedOrder(query, func(order models.Order) error {
+			for _, item := range order.OrderItems {
+				err := out.WriteRow(order.ID, order.CreatedAt, order.Status, order.Name, item.ItemType, item.ItemID, item.Title, item.Author,
+					item.Provider, item.Availability, item.Quantity, item.UnitPrice, item.LineTotal)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	case EXPORT_SUPPLIER:
+		return exportSupplierList(query, out)
+
+	case EXPORT_COURIER:
+		if err := out.WriteRow("Commande", "Nom", "Téléphone", "Wilaya", "Livraison", "Adresse", "Montant à encaisser"); err != nil {
+			return err
+		}
+		return eachExportedOrder(query, func(order models.Order) error {
+			return out.WriteRow(order.ID, order.Name, order.Phone, wilayaLabel(order.Wilaya), deliveryLabel(order.DeliveryMethod), order.Address, order.Total)
+		})
+
+	default:
+		err := out.WriteRow("Commande", "Date", "Statut", "Nom", "Email", "Téléphone", "Wilaya", "Livraison", "Adresse", "Articles",
+			"Sous-total", "Frais", "Frais de livraison", "Total")
+		if err != nil {
+			return err
+		}
+		return eachExportedOrder(query, func(order models.Order) error {
+			quantity := 0
+			for _, item := range order.OrderItems {
+				quantity += item.Quantity
+			}
+			return out.WriteRow(order.ID, order.CreatedAt, order.Status, order.Name, order.Email, order.Phone,
+				wilayaLabel(order.Wilaya), deliveryLabel(order.DeliveryMethod), order.Address, quantity,
+				order.Subtotal, order.Fees, order.ShippingCost, order.Total)
+		})
+	}
+}
+
+// supplierLine is a book of the supplier list at one provider, with every
+// order asking for it.
+type supplierLine struct {
+	item     models.OrderItem
+	provider string
+	quantity int
+	orders   []string
+}
+
+// exportSupplierList groups the books of the orders by provider, then sorted
+// by title, so the owner orders each book once from each provider with the
+// total quantity.
+func exportSupplierList(query OrderQuery, out sheet.Writer) error {
+	lines := make(map[string]*supplierLine)
+	err := eachExportedOrder(query, func(order models.Order) error {
+		for _, item := range order.OrderItems {
+			if item.ItemType != "book" {
+				continue
+			}
+
+			provider := purchaseProvider(item)
+			line, ok := lines[provider+"/"+item.ItemID]
+			if !ok {
+				line = &supplierLine{item: item, provider: provider}
+				lines[provider+"/"+item.ItemID] = line
+			}
+			if line.item.BasePrice <= 0 {
+				line.item.BasePrice = item.BasePrice // newest first by default, the latest price known
+			}
+			line.quantity += item.Quantity
+			line.orders = append(line.orders, fmt.Sprint(order.ID))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sorted := make([]*supplierLine, 0, len(lines))
+	for _, line := range lines {
+		sorted = append(sorted, line)
+	}
+	slices.SortFunc(sorted, func(a, b *supplierLine) int {
+		if order := strings.Compare(a.provider, b.provider); order != 0 {
+			return order
+		}
+		if order := strings.Compare(strings.ToLower(a.item.Title), strings.ToLower(b.item.Title)); order != 0 {
+			return order
+		}
+		return strings.Compare(a.item.ItemID, b.item.ItemID)
+	})
+
+	if err := out.WriteRow("ID", "Titre", "Auteur", "Fournisseur", "Quantité", "Prix fournisseur (EUR)", "Commandes"); err != nil {
+		return err
+	}
+	for _, line := range sorted {
+		err := out.WriteRow(line.item.ItemID, line.item.Title, line.item.Author, line.provider, line.quantity,
+			line.item.BasePrice, strings.Join(line.orders, ", "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachExportedOrder calls fn with every order matching query, in its order,
+// loading them a batch at a time.
+func eachExportedOrder(query OrderQuery, fn func(order models.Order) error) error {
+	column, descending, err := query.sortColumn()
+	if err != nil {
+		return err
+	}
+
+	for offset := 0; ; offset += orderExportBatch {
+		var orders []models.Order
+		err := sortOrders(query.apply(database.DB.Preload("OrderItems")), column, descending).
+			Offset(offset).
+			Limit(orderExportBatch).
+			Find(&orders).Error
+		if err != nil {
+			return err
+		}
+
+		for _, order := range orders {
+			if err := fn(order); err != nil {
+				return err
+			}
+		}
+		if len(orders) < orderExportBatch {
+			return nil
+		}
+	}
+}
+
+func wilayaLabel(code int) string {
+	name := WilayaName(code)
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf("%02d - %s", code, name)
+}
+
+func deliveryLabel(method string) string {
+	switch method {
+	case models.DELIVERY_HOME:
+		return "Domicile"
+	case models.DELIVERY_STOP_DESK:
+		return "Point relais"
+	}
+	return method
+}
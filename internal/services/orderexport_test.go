@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"amazon/internal/database"
+	"amazon/models"
+	"amazon/sheet"
+)
+
+func TestExportOrders(t *testing.T) {
+	useTestDatabase(t)
+	service := NewOrderService()
+
+	seed := []models.Order{
+		{Name: "Amine", Phone: "0555", Address: "Alger centre", Wilaya: 16, DeliveryMethod: models.DELIVERY_HOME, Status: models.ORDER_STATUS_CONFIRMED, Total: 7300,
+			OrderItems: []models.OrderItem{
+				{ItemType: "book", ItemID: "B2", Title: "Zadig", Quantity: 1, BasePrice: 8, UnitPrice: 2900, LineTotal: 2900},
+				{ItemType: "book", ItemID: "B1", Title: "L'Étranger", Quantity: 1, BasePrice: 10, UnitPrice: 3400, LineTotal: 3400},
+			}},
+		{Name: "Sara", Phone: "0666", Address: "Oran", Wilaya: 31, DeliveryMethod: models.DELIVERY_STOP_DESK, Status: models.ORDER_STATUS_CONFIRMED, Total: 7300,
+			OrderItems: []models.OrderItem{
+				{ItemType: "book", ItemID: "B1", Title: "L'Étranger", Quantity: 2, BasePrice: 12, UnitPrice: 3400, LineTotal: 6800, Provider: "lireka"},
+				{ItemType: "subscription", ItemID: "S1", Quantity: 1},
+			}},
+		{Name: "Karim", Status: models.ORDER_STATUS_CANCELLED, OrderItems: []models.OrderItem{{ItemType: "book", ItemID: "B3", Quantity: 1}}},
+	}
+	for i := range seed {
+		if err := database.DB.Create(&seed[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export := func(preset string) [][]string {
+		t.Helper()
+		var buffer bytes.Buffer
+		out, err := sheet.NewCSV(&buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := OrderQuery{Statuses: []string{models.ORDER_STATUS_CONFIRMED}, Sort: "created_at"}
+		if err := service.ExportOrders(query, preset, out); err != nil {
+			t.Fatal(err)
+		}
+		if err := out.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buffer.String(), "\uFEFF"))).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	if rows := export(EXPORT_ORDERS); len(rows) != 3 || rows[1][3] != "Amine" || rows[1][9] != "2" || rows[2][13] != "7300" {
+		t.Errorf("orders = %q", rows)
+	}
+	if rows := export(EXPORT_ITEMS); len(rows) != 5 || rows[2][6] != "L'Étranger" || rows[3][10] != "2" {
+		t.Errorf("items = %q", rows)
+	}
+
+	// the same book is bought separately from each provider
+	supplier := export(EXPORT_SUPPLIER)
+	want := [][]string{
+		{"ID", "Titre", "Auteur", "Fournisseur", "Quantité", "Prix fournisseur (EUR)", "Commandes"},
+		{"B1", "L'Étranger", "", "amazon", "1", "10", "1"},
+		{"B2", "Zadig", "", "amazon", "1", "8", "1"},
+		{"B1", "L'Étranger", "", "lireka", "2", "12", "2"},
+	}
+	if fmt.Sprint(supplier) != fmt.Sprint(want) {
+		t.Errorf("supplier = %q", supplier)
+	}
+
+	courier := export(EXPORT_COURIER)
+	if len(courier) != 3 || strings.Join(courier[2], "|") != "2|Sara|0666|31 - Oran|Point relais|Oran|7300" {
+		t.Errorf("courier = %q", courier)
+	}
+
+	if err := CheckExport(OrderQuery{}, "labels"); err != ErrUnknownExportPreset {
+		t.Errorf("unknown preset: %v", err)
+	}
+	if err := CheckExport(OrderQuery{Sort: "phone"}, EXPORT_ORDERS); err == nil {
+		t.Error("expected an error for an unknown sort")
+	}
+}
+
+// failingWriter refuses every row.
+type failingWriter struct{ sheet.Writer }
+
+func (failingWriter) WriteRow(cells ...any) error { return errors.New("disk full") }
+
+func TestExportWriteErrors(t *testing.T) {
+	useTestDatabase(t)
+	service := NewOrderService()
+
+	// the header is the only row of an empty export
+	for _, preset := range ExportPresets {
+		if err := service.ExportOrders(OrderQuery{Sort: "created_at"}, preset, failingWriter{}); err == nil {
+			t.Errorf("%s export ignored a failed header", preset)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"amazon/internal/scrapers/books"
+	"amazon/internal/utils"
+	"amazon/models"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// orderProviders are the providers books can be ordered from, "" is Amazon.
+var orderProviders = []string{"", books.PROVIDER_AMAZON, books.PROVIDER_GOOGLE, books.PROVIDER_LIREKA}
+
+// InvalidItemsError lists every item of a new order that can't be ordered.
+type InvalidItemsError struct {
+	Items []models.OrderItemError
+}
+
+func (e *InvalidItemsError) Error() string {
+	messages := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		messages = append(messages, fmt.Sprintf("item %d: %s", item.Index+1, item.Message))
+	}
+	return "some items can't be ordered: " + strings.Join(messages, "; ")
+}
+
+// ValidateItems checks the items of a new order and resolves the books at
+// their provider, Amazon when none is given, once per book, refusing unknown
+// providers, IDs and audiobooks. Books the provider can't be asked about right now are let
+// through, an admin checks them with the quote.
+func (s *OrderService) ValidateItems(items []models.OrderItem) error {
+	var invalid []models.OrderItemError
+	refuse := func(index int, item models.OrderItem, code string, message string) {
+		invalid = append(invalid, models.OrderItemError{Index: index, ItemID: item.ItemID, Code: code, Message: message})
+	}
+	lookups := make(bookLookups)
+
+	for i, item := range items {
+		switch {
+		case item.ItemType != "book" && item.ItemType != "subscription":
+			refuse(i, item, models.ORDER_ITEM_INVALID_TYPE, "invalid item type, must be 'book' or 'subscription'")
+			continue
+		case strings.TrimSpace(item.ItemID) == "":
+			refuse(i, item, models.ORDER_ITEM_MISSING_ID, "item ID is required")
+			continue
+		case item.Quantity <= 0:
+			refuse(i, item, models.ORDER_ITEM_INVALID_QUANTITY, "quantity must be greater than 0")
+			continue
+		case item.ItemType != "book":
+			continue
+		case !slices.Contains(orderProviders, item.Provider):
+			refuse(i, item, models.ORDER_ITEM_UNKNOWN_PROVIDER, "unknown provider, must be amazon, google or lireka")
+			continue
+		}
+
+		_, errCode, err := s.Quotes.lookupBook(lookups, item.Provider, item.ItemID)
+		switch {
+		case err == nil:
+		case errCode == "not_found":
+			refuse(i, item, models.ORDER_ITEM_NOT_FOUND, "no book with this ID")
+		case errCode == "audiobook":
+			refuse(i, item, models.ORDER_ITEM_AUDIOBOOK, "audiobooks can't be ordered")
+		default:
+			utils.Report("Can't check the ordered book " + item.ItemID + ": " + err.Error())
+		}
+	}
+
+	if len(invalid) > 0 {
+		return &InvalidItemsError{Items: invalid}
+	}
+	return nil
+}
+
+// UnavailableItems returns the items the provider doesn't have in stock when
+// the order is placed, they may take longer or never come.
+func UnavailableItems(order *models.Order) []models.OrderItem {
+	var unavailable []models.OrderItem
+	for _, item := range order.OrderItems {
+		if item.Availability == models.AVAILABILITY_UNAVAILABLE || item.Availability == models.AVAILABILITY_OUT_OF_STOCK {
+			unavailable = append(unavailable, item)
+		}
+	}
+	return unavailable
+}
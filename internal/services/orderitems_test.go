@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"amazon/internal/scrapers/books"
+	"amazon/models"
+)
+
+func TestValidateOrderItems(t *testing.T) {
+	orders := NewOrderService()
+	orders.Quotes.FetchBook = func(provider, id string) (*models.Book, string, error) {
+		switch id {
+		case "unknown":
+			return nil, "not_found", errors.New("Book not found")
+		case "audio":
+			return nil, "audiobook", errors.New("Book is an Audible/Audio book")
+		case "down":
+			return nil, "unhandled_error", errors.New("connection reset")
+		}
+		if provider != books.PROVIDER_LIREKA && id == "L1" {
+			return nil, "not_found", errors.New("Book not found")
+		}
+		return &models.Book{ID: id, Title: "Book " + id, Price: 10}, "", nil
+	}
+
+	err := orders.ValidateItems([]models.OrderItem{
+		{ItemType: "book", ItemID: "B1", Quantity: 1},
+		{ItemType: "dvd", ItemID: "D1", Quantity: 1},
+		{ItemType: "book", ItemID: " ", Quantity: 1},
+		{ItemType: "book", ItemID: "B2", Quantity: 0},
+		{ItemType: "book", ItemID: "unknown", Quantity: 1},
+		{ItemType: "book", ItemID: "audio", Quantity: 1},
+		{ItemType: "book", ItemID: "down", Quantity: 1},
+		{ItemType: "subscription", ItemID: "S1", Quantity: 1},
+		{ItemType: "book", ItemID: "L1", Quantity: 1, Provider: books.PROVIDER_LIREKA},
+		{ItemType: "book", ItemID: "B3", Quantity: 1, Provider: "fnac"},
+	})
+
+	var invalid *InvalidItemsError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("error = %v", err)
+	}
+
+	want := map[int]string{
+		1: models.ORDER_ITEM_INVALID_TYPE,
+		2: models.ORDER_ITEM_MISSING_ID,
+		3: models.ORDER_ITEM_INVALID_QUANTITY,
+		4: models.ORDER_ITEM_NOT_FOUND,
+		5: models.ORDER_ITEM_AUDIOBOOK,
+		9: models.ORDER_ITEM_UNKNOWN_PROVIDER,
+	}
+	if len(invalid.Items) != len(want) {
+		t.Fatalf("invalid items = %+v", invalid.Items)
+	}
+	for _, item := range invalid.Items {
+		if want[item.Index] != item.Code {
+			t.Errorf("item %d refused with %q, want %q", item.Index, item.Code, want[item.Index])
+		}
+	}
+
+	if err := orders.ValidateItems([]models.OrderItem{{ItemType: "book", ItemID: "B1", Quantity: 2}}); err != nil {
+		t.Fatalf("valid items: %v", err)
+	}
+
+	// a book repeated on several lines is looked up once
+	lookups := 0
+	fetch := orders.Quotes.FetchBook
+	orders.Quotes.FetchBook = func(provider, id string) (*models.Book, string, error) {
+		lookups++
+		return fetch(provider, id)
+	}
+	repeated := []models.OrderItem{
+		{ItemType: "book", ItemID: "B1", Quantity: 1},
+		{ItemType: "book", ItemID: "B1", Quantity: 1, Provider: books.PROVIDER_AMAZON},
+		{ItemType: "book", ItemID: "B1", Quantity: 1, Provider: books.PROVIDER_LIREKA},
+		{ItemType: "book", ItemID: "B1", Quantity: 3},
+	}
+	if err := orders.ValidateItems(repeated); err != nil || lookups != 2 {
+		t.Fatalf("repeated items: %d lookups, %v", lookups, err)
+	}
+}
+
+func TestUnavailableItems(t *testing.T) {
+	order := &models.Order{OrderItems: []models.OrderItem{
+		{ItemID: "B1", Availability: models.AVAILABILITY_IN_STOCK},
+		{ItemID: "B2", Availability: models.AVAILABILITY_UNAVAILABLE},
+		{ItemID: "B3", Availability: models.AVAILABILITY_OUT_OF_STOCK},
+		{ItemID: "B4"},
+	}}
+
+	unavailable := UnavailableItems(order)
+	if len(unavailable) != 2 || unavailable[0].ItemID != "B2" || unavailable[1].ItemID != "B3" {
+		t.Fatalf("unavailable = %+v", unavailable)
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/models"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidOrderQuery = errors.New("invalid order query")
+
+// orderSorts are the sort options of the order list, a leading "-" sorts in
+// descending order.
+var orderSorts = map[string]string{
+	"created_at": "orders.created_at",
+	"updated_at": "orders.updated_at",
+	"total":      "orders.total",
+	"status":     "orders.status",
+	"name":       "orders.name",
+}
+
+const defaultOrderSort = "-created_at"
+
+// OrderQuery filters and paginates the admin order list. Zero values don't
+// filter.
+type OrderQuery struct {
+	Page   int
+	Limit  int
+	Cursor string // continues after the last order of a page, sorted by creation only
+
+	Statuses []string
+	From     time.Time // created at or after
+	To       time.Time // created before
+	Email    string
+	Phone    string
+	ItemID   string
+	Search   string // in the name and the address
+	IDs      []uint
+	Purchase uint // orders with books in this purchase batch, the ones to ship once it is received
+
+	Sort string // one of orderSorts, "-created_at" when empty
+}
+
+// ListOrders returns a page of the orders matching query, with the items of
+// the page only, and the counts of the matching orders.
+func (s *OrderService) ListOrders(query OrderQuery) (*models.OrderPage, error) {
+	column, descending, err := query.sortColumn()
+	if err != nil {
+		return nil, err
+	}
+	if query.Limit < 1 {
+		return nil, fmt.Errorf("%w: limit must be greater than 0", ErrInvalidOrderQuery)
+	}
+	if query.Cursor != "" && column != "orders.created_at" {
+		return nil, fmt.Errorf("%w: cursors only paginate orders sorted by created_at", ErrInvalidOrderQuery)
+	}
+
+	page := &models.OrderPage{Limit: query.Limit, Orders: make([]models.Order, 0)}
+
+	if err := query.apply(database.DB.Model(&models.Order{})).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+	page.Pages = int(math.Ceil(float64(page.Total) / float64(query.Limit)))
+
+	counts, err := countOrdersByStatus(query)
+	if err != nil {
+		return nil, err
+	}
+	page.StatusCounts = counts
+
+	list := sortOrders(query.apply(database.DB.Preload("OrderItems")), column, descending).Limit(query.Limit)
+
+	if query.Cursor != "" {
+		createdAt, id, err := decodeOrderCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		operator := ">"
+		if descending {
+			operator = "<"
+		}
+		list = list.Where(fmt.Sprintf("(orders.created_at %s ?) OR (orders.created_at = ? AND orders.id %s ?)", operator, operator), createdAt, createdAt, id)
+	} else {
+		page.Page = max(query.Page, 1)
+		list = list.Offset((page.Page - 1) * query.Limit)
+	}
+
+	if err := list.Find(&page.Orders).Error; err != nil {
+		return nil, err
+	}
+
+	if column == "orders.created_at" && len(page.Orders) == query.Limit {
+		last := page.Orders[len(page.Orders)-1]
+		page.NextCursor = encodeOrderCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// sortColumn returns the column query sorts by and its direction.
+func (query OrderQuery) sortColumn() (string, bool, error) {
+	sort := query.Sort
+	if sort == "" {
+		sort = defaultOrderSort
+	}
+	column, ok := orderSorts[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return "", false, fmt.Errorf("%w: unknown sort %q", ErrInvalidOrderQuery, sort)
+	}
+	return column, strings.HasPrefix(sort, "-"), nil
+}
+
+// apply filters db with every filter of query.
+func (query OrderQuery) apply(db *gorm.DB) *gorm.DB {
+	db = filterOrders(db, query)
+	if len(query.Statuses) > 0 {
+		db = db.Where("orders.status IN ?", query.Statuses)
+	}
+	return db
+}
+
+// sortOrders orders db by column, then by ID for the orders that tie.
+func sortOrders(db *gorm.DB, column string, descending bool) *gorm.DB {
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	return db.Order(column + " " + direction).Order("orders.id " + direction)
+}
+
+// filterOrders applies every filter of query but the statuses.
+func filterOrders(db *gorm.DB, query OrderQuery) *gorm.DB {
+	if len(query.IDs) > 0 {
+		db = db.Where("orders.id IN ?", query.IDs)
+	}
+	if query.Purchase != 0 {
+		db = db.Where("orders.id IN (SELECT order_id FROM order_items WHERE purchase_batch_id = ?)", query.Purchase)
+	}
+	if !query.From.IsZero() {
+		db = db.Where("orders.created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("orders.created_at < ?", query.To)
+	}
+	if email := strings.TrimSpace(query.Email); email != "" {
+		db = db.Where("LOWER(orders.email) = LOWER(?)", email)
+	}
+	if phone := strings.TrimSpace(query.Phone); phone != "" {
+		db = db.Where("orders.phone = ?", phone)
+	}
+	if itemID := strings.TrimSpace(query.ItemID); itemID != "" {
+		db = db.Where("orders.id IN (SELECT order_id FROM order_items WHERE item_id = ?)", itemID)
+	}
+	if search := strings.TrimSpace(query.Search); search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		db = db.Where(`LOWER(orders.name) LIKE ? ESCAPE '\' OR LOWER(orders.address) LIKE ? ESCAPE '\'`, pattern, pattern)
+	}
+	return db
+}
+
+func countOrdersByStatus(query OrderQuery) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := filterOrders(database.DB.Model(&models.Order{}), query).
+		Select("orders.status AS status, COUNT(*) AS count").
+		Group("orders.status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
+
+// encodeOrderCursor points after an order of a list sorted by creation.
+func encodeOrderCursor(createdAt time.Time, id uint) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%d", createdAt.UnixNano(), id))
+}
+
+func decodeOrderCursor(cursor string) (time.Time, uint, error) {
+	invalid := fmt.Errorf("%w: invalid cursor", ErrInvalidOrderQuery)
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return time.Time{}, 0, invalid
+	}
+
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	orderID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	return time.Unix(0, unixNano), uint(orderID), nil
+}
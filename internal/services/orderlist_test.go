@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"amazon/internal/database"
+	"amazon/models"
+)
+
+func TestListOrders(t *testing.T) {
+	useTestDatabase(t)
+	service := NewOrderService()
+
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local)
+	seed := []models.Order{
+		{Name: "Amine Benali", Email: "amine@example.com", Phone: "0555", Address: "Alger centre", Status: models.ORDER_STATUS_AWAITING_QUOTE, Total: 3000},
+		{Name: "Sara Haddad", Email: "sara@example.com", Phone: "0666", Address: "Oran", Status: models.ORDER_STATUS_CONFIRMED, Total: 9000},
+		{Name: "Yacine", Email: "Amine@Example.com", Phone: "0777", Address: "100% Tizi", Status: models.ORDER_STATUS_SHIPPED, Total: 5000},
+		{Name: "Lina", Email: "lina@example.com", Phone: "0555", Address: "Annaba", Status: models.ORDER_STATUS_CONFIRMED, Total: 1000},
+		{Name: "Karim", Email: "karim@example.com", Phone: "0888", Address: "Blida", Status: models.ORDER_STATUS_CANCELLED, Total: 7000},
+	}
+	for i := range seed {
+		seed[i].CreatedAt = start.AddDate(0, 0, i)
+		seed[i].OrderItems = []models.OrderItem{{ItemType: "book", ItemID: fmt.Sprint("B", 1+i%2), Quantity: 1}}
+		if err := database.DB.Create(&seed[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(page *models.OrderPage) []uint {
+		var ids []uint
+		for _, order := range page.Orders {
+			ids = append(ids, order.ID)
+		}
+		return ids
+	}
+	list := func(query OrderQuery) *models.OrderPage {
+		t.Helper()
+		if query.Limit == 0 {
+			query.Limit = 10
+		}
+		page, err := service.ListOrders(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	page := list(OrderQuery{Page: 2, Limit: 2})
+	if page.Total != 5 || page.Pages != 3 || len(page.Orders) != 2 || page.Orders[0].ID != seed[2].ID {
+		t.Fatalf("second page = %v of %d", ids(page), page.Total)
+	}
+	if len(page.Orders[0].OrderItems) != 1 {
+		t.Fatalf("items not loaded: %+v", page.Orders[0])
+	}
+
+	confirmed := list(OrderQuery{Statuses: []string{models.ORDER_STATUS_CONFIRMED}})
+	if confirmed.Total != 2 || confirmed.StatusCounts[models.ORDER_STATUS_SHIPPED] != 1 || confirmed.StatusCounts[models.ORDER_STATUS_CONFIRMED] != 2 {
+		t.Fatalf("confirmed = %v, counts %v", ids(confirmed), confirmed.StatusCounts)
+	}
+
+	if byEmail := list(OrderQuery{Email: "amine@example.com"}); byEmail.Total != 2 {
+		t.Errorf("by email = %v", ids(byEmail))
+	}
+	if byPhone := list(OrderQuery{Phone: "0555"}); byPhone.Total != 2 {
+		t.Errorf("by phone = %v", ids(byPhone))
+	}
+	if byItem := list(OrderQuery{ItemID: "B2"}); byItem.Total != 2 {
+		t.Errorf("by item = %v", ids(byItem))
+	}
+	if byDate := list(OrderQuery{From: start.AddDate(0, 0, 1), To: start.AddDate(0, 0, 3)}); byDate.Total != 2 {
+		t.Errorf("by date = %v", ids(byDate))
+	}
+	if search := list(OrderQuery{Search: "alger"}); search.Total != 1 || search.Orders[0].ID != seed[0].ID {
+		t.Errorf("search = %v", ids(search))
+	}
+	if search := list(OrderQuery{Search: "100%", Statuses: []string{models.ORDER_STATUS_SHIPPED}}); search.Total != 1 {
+		t.Errorf("search with a wildcard = %v", ids(search))
+	}
+	if search := list(OrderQuery{Search: "%"}); search.Total != 1 {
+		t.Errorf("a %% is searched literally = %v", ids(search))
+	}
+
+	byTotal := list(OrderQuery{Sort: "total"})
+	if byTotal.Orders[0].ID != seed[3].ID || byTotal.Orders[4].ID != seed[1].ID {
+		t.Errorf("by total = %v", ids(byTotal))
+	}
+
+	// cursors walk the newest first without skipping or repeating
+	var walked []uint
+	cursor := ""
+	for range 5 {
+		page := list(OrderQuery{Limit: 2, Cursor: cursor})
+		walked = append(walked, ids(page)...)
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if len(walked) != 5 || walked[0] != seed[4].ID || walked[4] != seed[0].ID {
+		t.Fatalf("walked = %v", walked)
+	}
+
+	for _, query := range []OrderQuery{
+		{Limit: 10, Sort: "phone"},
+		{Limit: 10, Sort: "total", Cursor: page.NextCursor},
+		{Limit: 10, Cursor: "not a cursor"},
+		{Limit: 0},
+	} {
+		if _, err := service.ListOrders(query); !errors.Is(err, ErrInvalidOrderQuery) {
+			t.Errorf("%+v: %v", query, err)
+		}
+	}
+}
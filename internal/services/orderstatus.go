@@ -0,0 +1,104 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/models"
+	"errors"
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownOrderStatus = errors.New("unknown order status")
+	ErrIllegalTransition  = errors.New("illegal order status transition")
+)
+
+// OrderTransitions lists the statuses an order can move to from each status.
+// It follows new → quoted → confirmed, where new orders are awaiting_quote
+// since the quote workflow. The quote workflow also resends a quote
+// (quoted → quoted) and reopens one an admin revises or that failed to send
+// (quoted → awaiting_quote). Orders are cancelled before they ship and
+// refunded once paid for.
+var OrderTransitions = map[string][]string{
+	models.ORDER_STATUS_AWAITING_QUOTE: {
+		models.ORDER_STATUS_QUOTED, models.ORDER_STATUS_CANCELLED,
+	},
+	models.ORDER_STATUS_QUOTED: {
+		models.ORDER_STATUS_AWAITING_QUOTE, models.ORDER_STATUS_QUOTED, models.ORDER_STATUS_CONFIRMED, models.ORDER_STATUS_CANCELLED,
+	},
+	models.ORDER_STATUS_CONFIRMED: {
+		models.ORDER_STATUS_ORDERED_FROM_SUPPLIER, models.ORDER_STATUS_CANCELLED, models.ORDER_STATUS_REFUNDED,
+	},
+	models.ORDER_STATUS_ORDERED_FROM_SUPPLIER: {
+		models.ORDER_STATUS_RECEIVED, models.ORDER_STATUS_CANCELLED, models.ORDER_STATUS_REFUNDED,
+	},
+	models.ORDER_STATUS_RECEIVED: {
+		models.ORDER_STATUS_SHIPPED, models.ORDER_STATUS_CANCELLED, models.ORDER_STATUS_REFUNDED,
+	},
+	models.ORDER_STATUS_SHIPPED: {
+		models.ORDER_STATUS_DELIVERED, models.ORDER_STATUS_REFUNDED,
+	},
+	models.ORDER_STATUS_DELIVERED: {
+		models.ORDER_STATUS_REFUNDED,
+	},
+	models.ORDER_STATUS_CANCELLED: {
+		models.ORDER_STATUS_REFUNDED,
+	},
+	models.ORDER_STATUS_REFUNDED: {},
+}
+
+func IsOrderStatus(status string) bool {
+	_, ok := OrderTransitions[status]
+	return ok
+}
+
+// CanTransitionOrder tells if an order can move from one status to another.
+// Statuses from before the lifecycle are mapped to it when the database is
+// migrated, an unknown status can't move.
+func CanTransitionOrder(from string, to string) bool {
+	return IsOrderStatus(to) && slices.Contains(OrderTransitions[from], to)
+}
+
+// transitionOrder moves an order to status if its current status allows it,
+// recording event in its history. The update is conditioned on the status
+// read, so two admins changing the same order can't both succeed from the
+// same status.
+func transitionOrder(order *models.Order, status string, updates map[string]any, event models.OrderEvent) error {
+	if !IsOrderStatus(status) {
+		return ErrUnknownOrderStatus
+	}
+	if !CanTransitionOrder(order.Status, status) {
+		return fmt.Errorf("%w from %s to %s", ErrIllegalTransition, order.Status, status)
+	}
+
+	if updates == nil {
+		updates = make(map[string]any)
+	}
+	updates["status"] = status
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", order.ID, order.Status).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: the order changed in the meantime", ErrIllegalTransition)
+		}
+
+		event.OrderID = order.ID
+		event.Type = models.ORDER_EVENT_STATUS
+		event.FromStatus = order.Status
+		event.ToStatus = status
+		return recordOrderEvent(tx, &event)
+	})
+	if err != nil {
+		return err
+	}
+
+	order.Status = status
+	return nil
+}
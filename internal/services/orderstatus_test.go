@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"amazon/internal/database"
+	"amazon/models"
+)
+
+func TestOrderLifecycle(t *testing.T) {
+	useTestDatabase(t)
+	service := NewOrderService()
+
+	order := models.Order{Name: "Amine", Email: "amine@example.com", Status: models.ORDER_STATUS_AWAITING_QUOTE}
+	if err := database.DB.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	id := fmt.Sprint(order.ID)
+
+	if _, err := service.SetOrderStatus(id, "lost", "1", ""); !errors.Is(err, ErrUnknownOrderStatus) {
+		t.Fatalf("unknown status: %v", err)
+	}
+	if _, err := service.SetOrderStatus("9999", models.ORDER_STATUS_CONFIRMED, "1", ""); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("unknown order: %v", err)
+	}
+	if _, err := service.SetOrderStatus(id, models.ORDER_STATUS_QUOTED, "1", ""); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("quoting by hand: %v", err)
+	}
+
+	current, err := service.SetOrderStatus(id, models.ORDER_STATUS_SHIPPED, "1", "")
+	if !errors.Is(err, ErrIllegalTransition) || current.Status != models.ORDER_STATUS_AWAITING_QUOTE {
+		t.Fatalf("skipping ahead: %v, %+v", err, current)
+	}
+	if _, err := service.SetOrderStatus(id, models.ORDER_STATUS_CONFIRMED, "1", ""); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("confirming without a quote: %v", err)
+	}
+
+	// as sending the quote does
+	if err := database.DB.Model(&order).Update("status", models.ORDER_STATUS_QUOTED).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, status := range []string{
+		models.ORDER_STATUS_CONFIRMED,
+		models.ORDER_STATUS_ORDERED_FROM_SUPPLIER,
+		models.ORDER_STATUS_RECEIVED,
+		models.ORDER_STATUS_SHIPPED,
+		models.ORDER_STATUS_DELIVERED,
+	} {
+		if _, err := service.SetOrderStatus(id, status, "1", ""); err != nil {
+			t.Fatalf("moving to %s: %v", status, err)
+		}
+	}
+
+	if _, err := service.SetOrderStatus(id, models.ORDER_STATUS_CANCELLED, "1", ""); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("cancelling a delivered order: %v", err)
+	}
+	if _, err := service.SetOrderStatus(id, models.ORDER_STATUS_REFUNDED, "1", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := service.GetOrderByID(id)
+	if stored.Status != models.ORDER_STATUS_REFUNDED {
+		t.Fatalf("stored status = %q", stored.Status)
+	}
+}
+
+func TestOrderTransitionsAreKnown(t *testing.T) {
+	for from, next := range OrderTransitions {
+		for _, to := range next {
+			if !IsOrderStatus(to) {
+				t.Errorf("%s moves to unknown status %s", from, to)
+			}
+		}
+	}
+
+	// statuses from before the lifecycle are migrated, none is left to move
+	if CanTransitionOrder("pending", models.ORDER_STATUS_SHIPPED) || CanTransitionOrder("pending", models.ORDER_STATUS_CANCELLED) {
+		t.Error("an unknown status moved")
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/mail"
+	"amazon/models"
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"gorm.io/gorm"
+)
+
+var ErrTrackingNotFound = errors.New("no order with this tracking link")
+
+// TrackOrder returns the customer's view of the order of a tracking link.
+func (s *OrderService) TrackOrder(token string) (*models.OrderTracking, error) {
+	if token == "" {
+		return nil, ErrTrackingNotFound
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("OrderItems").Where("tracking_token = ?", token).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTrackingNotFound
+		}
+		return nil, err
+	}
+	return s.CustomerView(&order)
+}
+
+// CustomerView is what the customer may see of an order, at checkout and
+// from the tracking link: no prices before the quote, no contact details and
+// nothing about other orders.
+func (s *OrderService) CustomerView(order *models.Order) (*models.OrderTracking, error) {
+	var events []models.OrderEvent
+	err := database.DB.
+		Where("order_id = ? AND type IN ?", order.ID, []string{models.ORDER_EVENT_CREATED, models.ORDER_EVENT_STATUS}).
+		Order("created_at, id").
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tracking := &models.OrderTracking{
+		ID:             order.ID,
+		CreatedAt:      order.CreatedAt,
+		Status:         order.Status,
+		Name:           firstName(order.Name),
+		Email:          MaskEmail(order.Email),
+		Wilaya:         WilayaName(order.Wilaya),
+		DeliveryMethod: order.DeliveryMethod,
+		Items:          make([]models.OrderTrackingItem, 0, len(order.OrderItems)),
+		History:        make([]models.OrderTrackingStep, 0, len(events)),
+	}
+	if order.QuoteSentAt != nil {
+		tracking.QuoteTotal, tracking.QuoteSentAt = order.QuoteTotal, order.QuoteSentAt
+	}
+
+	for _, item := range order.OrderItems {
+		tracking.Items = append(tracking.Items, models.OrderTrackingItem{
+			Title:    item.Title,
+			Cover:    CoverProxyURL(item.Cover, 300),
+			Author:   item.Author,
+			Quantity: item.Quantity,
+		})
+	}
+
+	// orders placed before the history was kept start from their creation
+	if len(events) == 0 || events[0].Type != models.ORDER_EVENT_CREATED {
+		tracking.History = append(tracking.History, models.OrderTrackingStep{
+			Status: models.ORDER_STATUS_AWAITING_QUOTE,
+			At:     order.CreatedAt,
+		})
+	}
+	for _, event := range events {
+		tracking.History = append(tracking.History, models.OrderTrackingStep{
+			Status: event.ToStatus,
+			At:     event.CreatedAt,
+		})
+	}
+
+	return tracking, nil
+}
+
+// SendTrackingLink emails the customer the confirmation of their order with
+// its tracking link.
+func (s *OrderService) SendTrackingLink(order *models.Order) error {
+	data := trackingMailData{
+		Name:    order.Name,
+		OrderID: order.ID,
+		URL:     TrackingURL(order.TrackingToken),
+	}
+	for _, item := range order.OrderItems {
+		data.Lines = append(data.Lines, QuoteLine{Title: s.Quotes.itemTitle(item), Quantity: item.Quantity})
+	}
+
+	var html, text bytes.Buffer
+	if err := trackingHTMLTemplate.Execute(&html, data); err != nil {
+		return err
+	}
+	if err := trackingTextTemplate.Execute(&text, data); err != nil {
+		return err
+	}
+
+	return mail.Send(mail.Message{
+		To:      order.Email,
+		Subject: fmt.Sprintf("Votre commande n°%d", order.ID),
+		HTML:    html.String(),
+		Text:    text.String(),
+	})
+}
+
+// TrackingURL is the page where the customer follows an order.
+func TrackingURL(token string) string {
+	return publicURL + "/track/" + token
+}
+
+// MaskEmail hides an address but its first letter and domain.
+func MaskEmail(email string) string {
+	local, domain, found := strings.Cut(email, "@")
+	if !found || local == "" {
+		return "***"
+	}
+	return local[:1] + "***@" + domain
+}
+
+func firstName(name string) string {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+type trackingMailData struct {
+	Name    string
+	OrderID uint
+	Lines   []QuoteLine
+	URL     string
+}
+
+var trackingHTMLTemplate = htmltemplate.Must(htmltemplate.New("tracking").Parse(`<p>Bonjour {{.Name}},</p>
+<p>Nous avons bien reçu votre commande n°{{.OrderID}} :</p>
+<ul>
+{{- range .Lines}}
+<li>{{.Title}} × {{.Quantity}}</li>
+{{- end}}
+</ul>
+<p>Nous vous enverrons son devis par email. Vous pouvez suivre votre commande ici : <a href="{{.URL}}">{{.URL}}</a></p>
+`))
+
+var trackingTextTemplate = texttemplate.Must(texttemplate.New("tracking").Parse(`Bonjour {{.Name}},
+
+Nous avons bien reçu votre commande n°{{.OrderID}} :
+{{range .Lines}}
+- {{.Title}} × {{.Quantity}}
+{{- end}}
+
+Nous vous enverrons son devis par email. Vous pouvez suivre votre commande ici : {{.URL}}
+`))
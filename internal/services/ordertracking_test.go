@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"amazon/mail"
+	"amazon/models"
+)
+
+func TestOrderTracking(t *testing.T) {
+	useTestDatabase(t)
+	currentPricingRules.rules = nil
+	t.Cleanup(func() { currentPricingRules.rules = nil })
+
+	var sent []mail.Message
+	previous := mail.Deliver
+	mail.Deliver = func(message mail.Message) error {
+		sent = append(sent, message)
+		return nil
+	}
+	t.Cleanup(func() { mail.Deliver = previous })
+
+	orders := NewOrderService()
+	rates := []models.ShippingRate{{Method: models.DELIVERY_HOME, Price: 800, IncludedWeight: 1}}
+	if err := orders.Shipping.SaveRates(rates, "1"); err != nil {
+		t.Fatal(err)
+	}
+	orders.Quotes.FetchBook = func(provider, id string) (*models.Book, string, error) {
+		return &models.Book{ID: id, Title: "L'Étranger", Authors: []models.AuthorType{{Name: "Camus"}}}, "", nil
+	}
+	orders.Shipping.CachedBook = func(id string) (*models.Book, string, error) {
+		return orders.Quotes.FetchBook("", id)
+	}
+
+	order := &models.Order{
+		Name: "Amine Benali", Email: "amine@example.com", Phone: "0555", Address: "12 rue Didouche, Alger",
+		Wilaya: 16, DeliveryMethod: models.DELIVERY_HOME,
+		OrderItems: []models.OrderItem{{ItemType: "book", ItemID: "B1", Quantity: 2}},
+	}
+	if _, err := orders.CreateOrder(order); err != nil {
+		t.Fatal(err)
+	}
+	if len(order.TrackingToken) < 32 {
+		t.Fatalf("tracking token = %q", order.TrackingToken)
+	}
+
+	if err := orders.SendTrackingLink(order); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0].Text, "/track/"+order.TrackingToken) || !strings.Contains(sent[0].Text, "L'Étranger × 2") {
+		t.Fatalf("mail = %+v", sent)
+	}
+
+	if _, err := orders.SetOrderStatus(fmt.Sprint(order.ID), models.ORDER_STATUS_CANCELLED, "1", "internal comment"); err != nil {
+		t.Fatal(err)
+	}
+
+	tracking, err := orders.TrackOrder(order.TrackingToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracking.Status != models.ORDER_STATUS_CANCELLED || tracking.Name != "Amine" || tracking.Email != "a***@example.com" || tracking.Wilaya != "Alger" {
+		t.Fatalf("tracking = %+v", tracking)
+	}
+	if len(tracking.Items) != 1 || tracking.Items[0].Title != "L'Étranger" || tracking.Items[0].Quantity != 2 {
+		t.Fatalf("items = %+v", tracking.Items)
+	}
+	if len(tracking.History) != 2 || tracking.History[0].Status != models.ORDER_STATUS_AWAITING_QUOTE || tracking.History[1].Status != models.ORDER_STATUS_CANCELLED {
+		t.Fatalf("history = %+v", tracking.History)
+	}
+
+	// the customer sees no price before the quote, nor the orders sharing
+	// their phone or email
+	view, err := orders.CustomerView(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(view)
+	for _, field := range []string{"price", "Price", "exchange_rate", "subtotal", "total", "fees", "phone", "address", "duplicate", "linked"} {
+		if strings.Contains(string(encoded), field) {
+			t.Errorf("the customer view shows %s: %s", field, encoded)
+		}
+	}
+
+	if _, err := orders.TrackOrder("guess"); !errors.Is(err, ErrTrackingNotFound) {
+		t.Fatalf("unknown token: %v", err)
+	}
+	if _, err := orders.TrackOrder(""); !errors.Is(err, ErrTrackingNotFound) {
+		t.Fatalf("empty token: %v", err)
+	}
+}
+
+func TestMaskEmail(t *testing.T) {
+	cases := map[string]string{"amine@example.com": "a***@example.com", "a@b.dz": "a***@b.dz", "nope": "***", "@x.com": "***"}
+	for email, want := range cases {
+		if got := MaskEmail(email); got != want {
+			t.Errorf("MaskEmail(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
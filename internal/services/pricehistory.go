@@ -0,0 +1,285 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/internal/scrapers/books"
+	"amazon/internal/utils"
+	"amazon/models"
+	"amazon/notification"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrPriceAlertNotFound = errors.New("price alert not found")
+
+// quotedOrderStatuses are the orders whose customers were given a price we
+// have not paid the provider yet.
+var quotedOrderStatuses = []string{models.ORDER_STATUS_QUOTED, models.ORDER_STATUS_CONFIRMED}
+
+type PriceHistoryService struct {
+	Pricing *PricingService
+
+	// RefreshBook reads a book again at its provider, skipping the cache
+	RefreshBook func(provider string, id string) (*models.Book, string, error)
+}
+
+func NewPriceHistoryService() *PriceHistoryService {
+	return &PriceHistoryService{
+		Pricing:     NewPricingService(),
+		RefreshBook: books.RefreshProviderBook,
+	}
+}
+
+// Observe records the books read from a provider, it is the scrapers'
+// books.PriceObserver.
+func (s *PriceHistoryService) Observe(provider string, observed ...models.Book) {
+	now := time.Now()
+
+	observations := make([]models.PriceObservation, 0, len(observed))
+	for _, book := range observed {
+		if book.ID == "" {
+			continue
+		}
+
+		availability := book.Availability
+		if availability == "" {
+			availability = models.AVAILABILITY_UNKNOWN
+		}
+
+		observations = append(observations, models.PriceObservation{
+			BookID:       book.ID,
+			Provider:     provider,
+			Price:        float64(book.Price),
+			Availability: availability,
+			ObservedAt:   now,
+		})
+	}
+
+	if err := s.Record(observations); err != nil {
+		utils.Report("Can't record the prices observed at " + provider + ": " + err.Error())
+	}
+}
+
+// Record stores observations, skipping the ones repeating the latest
+// observation of the same book and provider made less than
+// PRICE_OBSERVATION_INTERVAL before. Recorded prices are checked against the
+// quoted orders.
+func (s *PriceHistoryService) Record(observations []models.PriceObservation) error {
+	var recorded []models.PriceObservation
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, observation := range observations {
+			var latest models.PriceObservation
+			err := tx.Where("book_id = ? AND provider = ?", observation.BookID, observation.Provider).
+				Order("observed_at DESC, id DESC").
+				First(&latest).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			if err == nil && latest.Price == observation.Price && latest.Availability == observation.Availability &&
+				observation.ObservedAt.Sub(latest.ObservedAt) < utils.PRICE_OBSERVATION_INTERVAL {
+				continue
+			}
+
+			if err := tx.Create(&observation).Error; err != nil {
+				return err
+			}
+			recorded = append(recorded, observation)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, observation := range recorded {
+		if err := s.checkQuotedOrders(observation); err != nil {
+			utils.Report("Can't check the quoted orders of " + observation.BookID + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// History returns the observations of a book, latest first, for one provider
+// or all of them.
+func (s *PriceHistoryService) History(bookID string, provider string, limit int) ([]models.PriceObservation, error) {
+	query := database.DB.Where("book_id = ?", bookID)
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+
+	observations := make([]models.PriceObservation, 0)
+	if err := query.Order("observed_at DESC, id DESC").Limit(limit).Find(&observations).Error; err != nil {
+		return nil, err
+	}
+	return observations, nil
+}
+
+// checkQuotedOrders raises an alert for every quoted item of the book that
+// now costs more than the customer was quoted.
+func (s *PriceHistoryService) checkQuotedOrders(observation models.PriceObservation) error {
+	if observation.Price <= 0 {
+		return nil
+	}
+
+	// items are bought where they were ordered, older ones from Amazon
+	providers := []string{observation.Provider}
+	if observation.Provider == books.PROVIDER_AMAZON {
+		providers = append(providers, "")
+	}
+
+	var items []models.OrderItem
+	err := database.DB.
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("order_items.item_type = ? AND order_items.item_id = ? AND order_items.unit_price > 0", "book", observation.BookID).
+		Where("order_items.provider IN ? AND orders.status IN ?", providers, quotedOrderStatuses).
+		Find(&items).Error
+	if err != nil || len(items) == 0 {
+		return err
+	}
+
+	breakdown, err := s.Pricing.Price(observation.Price, "book")
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if breakdown.Price <= item.UnitPrice {
+			continue
+		}
+		if err := s.raiseAlert(item, observation, breakdown.Price); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// raiseAlert opens an alert for an item, or raises the price of its open one.
+func (s *PriceHistoryService) raiseAlert(item models.OrderItem, observation models.PriceObservation, price float64) error {
+	var alert models.PriceAlert
+	err := database.DB.Where("order_item_id = ? AND acknowledged_at IS NULL", item.ID).First(&alert).Error
+	if err == nil {
+		if price <= alert.CurrentPrice {
+			return nil
+		}
+		return database.DB.Model(&alert).Updates(map[string]any{
+			"current_price": price,
+			"base_price":    observation.Price,
+			"provider":      observation.Provider,
+		}).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	alert = models.PriceAlert{
+		OrderID:      item.OrderID,
+		OrderItemID:  item.ID,
+		BookID:       item.ItemID,
+		Provider:     observation.Provider,
+		QuotedPrice:  item.UnitPrice,
+		CurrentPrice: price,
+		BasePrice:    observation.Price,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&alert).Error; err != nil {
+			return err
+		}
+		return recordOrderEvent(tx, &models.OrderEvent{
+			OrderID: item.OrderID,
+			Type:    models.ORDER_EVENT_PRICE_ALERT,
+			Actor:   models.ORDER_ACTOR_SYSTEM,
+			Details: map[string]any{
+				"book_id":       item.ItemID,
+				"provider":      observation.Provider,
+				"quoted_price":  item.UnitPrice,
+				"current_price": price,
+			},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	notification.Send(
+		fmt.Sprintf("Price alert - Order %d", item.OrderID),
+		fmt.Sprintf("%s: %s quoted, %s now", item.ItemID, FormatDZD(item.UnitPrice), FormatDZD(price)),
+	)
+	return nil
+}
+
+// Alerts returns the open alerts, or every alert, latest first.
+func (s *PriceHistoryService) Alerts(includeAcknowledged bool) ([]models.PriceAlert, error) {
+	query := database.DB.Order("updated_at DESC, id DESC")
+	if !includeAcknowledged {
+		query = query.Where("acknowledged_at IS NULL")
+	}
+
+	alerts := make([]models.PriceAlert, 0)
+	if err := query.Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// AcknowledgeAlert closes an alert, a later rise opens a new one.
+func (s *PriceHistoryService) AcknowledgeAlert(id string, adminID string) (*models.PriceAlert, error) {
+	var alert models.PriceAlert
+	if err := database.DB.First(&alert, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPriceAlertNotFound
+		}
+		return nil, err
+	}
+	if alert.AcknowledgedAt != nil {
+		return &alert, nil
+	}
+
+	now := time.Now()
+	alert.AcknowledgedAt = &now
+	alert.AcknowledgedBy = adminID
+	if err := database.DB.Save(&alert).Error; err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// RefreshQuotedBooks reads the books of the quoted orders again at the
+// provider they were ordered from, their cached prices could be years old.
+// Returns the number of books refreshed.
+func (s *PriceHistoryService) RefreshQuotedBooks() (int, error) {
+	var quoted []models.OrderItem
+	err := database.DB.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("order_items.item_type = ? AND orders.status IN ?", "book", quotedOrderStatuses).
+		Distinct("order_items.provider", "order_items.item_id").
+		Find(&quoted).Error
+	if err != nil {
+		return 0, err
+	}
+
+	// items without a provider are Amazon's
+	seen := make(map[string]bool)
+	refreshed := 0
+	for _, item := range quoted {
+		provider := purchaseProvider(item)
+		if seen[provider+"/"+item.ItemID] {
+			continue
+		}
+		seen[provider+"/"+item.ItemID] = true
+
+		if len(seen) > 1 {
+			time.Sleep(utils.PRICE_REFRESH_DELAY)
+		}
+		if _, _, err := s.RefreshBook(provider, item.ItemID); err != nil {
+			utils.Report("Can't refresh the price of " + provider + " book " + item.ItemID + ": " + err.Error())
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
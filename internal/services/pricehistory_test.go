@@ -0,0 +1,142 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"amazon/internal/database"
+	"amazon/internal/scrapers/books"
+	"amazon/models"
+)
+
+func TestPriceHistory(t *testing.T) {
+	useTestDatabase(t)
+	service := NewPriceHistoryService()
+
+	start := time.Now().Add(-3 * time.Hour)
+	observe := func(offset time.Duration, price float64, availability string) {
+		t.Helper()
+		err := service.Record([]models.PriceObservation{{
+			BookID: "B1", Provider: "amazon", Price: price, Availability: availability, ObservedAt: start.Add(offset),
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	observe(0, 10, models.AVAILABILITY_IN_STOCK)
+	observe(10*time.Minute, 10, models.AVAILABILITY_IN_STOCK) // unchanged, skipped
+	observe(20*time.Minute, 10, models.AVAILABILITY_OUT_OF_STOCK)
+	observe(2*time.Hour, 10, models.AVAILABILITY_OUT_OF_STOCK) // unchanged but an hour later
+	observe(150*time.Minute, 12, models.AVAILABILITY_IN_STOCK)
+
+	history, err := service.History("B1", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 4 || history[0].Price != 12 || history[3].Availability != models.AVAILABILITY_IN_STOCK {
+		t.Fatalf("history = %+v", history)
+	}
+
+	if history, _ := service.History("B1", "lireka", 10); len(history) != 0 {
+		t.Fatalf("lireka history = %+v", history)
+	}
+}
+
+func TestPriceAlerts(t *testing.T) {
+	useTestDatabase(t)
+	currentPricingRules.rules = nil
+	t.Cleanup(func() { currentPricingRules.rules = nil })
+
+	service := NewPriceHistoryService()
+	rate := models.ExchangeRate{Rate: 260, EffectiveAt: time.Now().Add(-time.Hour)}
+	if err := service.Pricing.Rates.SetRate(&rate, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// quoted 3400 DZD: 10 EUR at 260 plus the 800 DZD fee
+	order := models.Order{
+		Name: "Amine", Email: "amine@example.com", Status: models.ORDER_STATUS_QUOTED,
+		OrderItems: []models.OrderItem{{ItemType: "book", ItemID: "B1", Quantity: 1, UnitPrice: 3400}},
+	}
+	pending := models.Order{
+		Name: "Sara", Email: "sara@example.com", Status: models.ORDER_STATUS_AWAITING_QUOTE,
+		OrderItems: []models.OrderItem{{ItemType: "book", ItemID: "B1", Quantity: 1, UnitPrice: 3400}},
+	}
+	for _, o := range []*models.Order{&order, &pending} {
+		if err := database.DB.Create(o).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	observe := func(price float64) {
+		t.Helper()
+		service.Observe("amazon", models.Book{ID: "B1", Price: float32(price)})
+	}
+
+	observe(10)
+	if alerts, _ := service.Alerts(false); len(alerts) != 0 {
+		t.Fatalf("alerts for an unchanged price = %+v", alerts)
+	}
+
+	// the book is bought from Amazon, what Lireka asks doesn't matter
+	service.Observe(books.PROVIDER_LIREKA, models.Book{ID: "B1", Price: 30})
+	if alerts, _ := service.Alerts(false); len(alerts) != 0 {
+		t.Fatalf("alerts for another provider = %+v", alerts)
+	}
+
+	// the open alert follows the price up
+	observe(11)
+	observe(12)
+	alerts, err := service.Alerts(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].OrderID != order.ID || alerts[0].QuotedPrice != 3400 || alerts[0].CurrentPrice != 3920 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+
+	if _, err := service.AcknowledgeAlert("9999", "1"); err != ErrPriceAlertNotFound {
+		t.Fatalf("acknowledging a missing alert: %v", err)
+	}
+	if _, err := service.AcknowledgeAlert(fmt.Sprint(alerts[0].ID), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if alerts, _ := service.Alerts(false); len(alerts) != 0 {
+		t.Fatalf("open alerts after acknowledging = %+v", alerts)
+	}
+	if alerts, _ := service.Alerts(true); len(alerts) != 1 || alerts[0].AcknowledgedBy != "1" {
+		t.Fatalf("all alerts = %+v", alerts)
+	}
+}
+
+func TestRefreshQuotedBooks(t *testing.T) {
+	useTestDatabase(t)
+	service := NewPriceHistoryService()
+
+	var refreshed []string
+	service.RefreshBook = func(provider, id string) (*models.Book, string, error) {
+		refreshed = append(refreshed, provider+"/"+id)
+		return &models.Book{ID: id}, "", nil
+	}
+
+	order := models.Order{
+		Name: "Amine", Email: "amine@example.com", Status: models.ORDER_STATUS_CONFIRMED,
+		OrderItems: []models.OrderItem{
+			{ItemType: "book", ItemID: "B1", Quantity: 1},
+			{ItemType: "book", ItemID: "B1", Quantity: 1, Provider: books.PROVIDER_AMAZON},
+			{ItemType: "book", ItemID: "L1", Quantity: 1, Provider: books.PROVIDER_LIREKA},
+		},
+	}
+	if err := database.DB.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := service.RefreshQuotedBooks()
+	slices.Sort(refreshed)
+	if err != nil || count != 2 || fmt.Sprint(refreshed) != "[amazon/B1 lireka/L1]" {
+		t.Fatalf("refreshed %d books %v: %v", count, refreshed, err)
+	}
+}
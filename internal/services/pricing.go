@@ -0,0 +1,372 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/internal/utils"
+	"amazon/models"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ROUNDING_UP      = "up"
+	ROUNDING_DOWN    = "down"
+	ROUNDING_NEAREST = "nearest"
+
+	EXCHANGE_RATE_FROM_RULES = "rules" // the rules override the rate table
+	EXCHANGE_RATE_FROM_TABLE = "table"
+)
+
+var (
+	ErrPriceUnavailable     = errors.New("the provider has no price for this book")
+	ErrPricingRulesNotFound = errors.New("pricing rules version not found")
+)
+
+// DefaultPricingRules reproduce the historical formula: the euro converted at
+// the exchange rate (260 DZD until changed) rounded up to 5 DZD, plus an
+// 800 DZD service fee.
+func DefaultPricingRules() models.PricingRuleSet {
+	return models.PricingRuleSet{
+		Version:      1,
+		Note:         "Default rules",
+		ExchangeRate: 0,
+		Margins:      []models.PricingMargin{},
+		Fees:         []models.PricingFee{{Name: "Service fee", Amount: 800}},
+		RoundingStep: 5,
+		RoundingMode: ROUNDING_UP,
+	}
+}
+
+// currentPricingRules caches the latest version, prices are computed for
+// every listed book.
+var currentPricingRules struct {
+	sync.Mutex
+	rules *models.PricingRuleSet
+}
+
+type PricingService struct {
+	Rates *ExchangeRateService
+}
+
+func NewPricingService() *PricingService {
+	return &PricingService{
+		Rates: NewExchangeRateService(),
+	}
+}
+
+// CurrentRules returns the latest rules, creating the defaults on first use.
+func (s *PricingService) CurrentRules() (*models.PricingRuleSet, error) {
+	currentPricingRules.Lock()
+	defer currentPricingRules.Unlock()
+
+	if currentPricingRules.rules != nil {
+		return currentPricingRules.rules, nil
+	}
+
+	var rules models.PricingRuleSet
+	err := database.DB.Order("version DESC").First(&rules).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		rules = DefaultPricingRules()
+		err = database.DB.Create(&rules).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	currentPricingRules.rules = &rules
+	return &rules, nil
+}
+
+func (s *PricingService) GetRules(version int) (*models.PricingRuleSet, error) {
+	var rules models.PricingRuleSet
+	if err := database.DB.First(&rules, "version = ?", version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPricingRulesNotFound
+		}
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// RulesHistory returns every version, newest first.
+func (s *PricingService) RulesHistory() ([]models.PricingRuleSet, error) {
+	var history []models.PricingRuleSet
+	if err := database.DB.Order("version DESC").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// SaveRules validates rules and stores them as the new current version.
+func (s *PricingService) SaveRules(rules *models.PricingRuleSet, adminID string) error {
+	if err := ValidatePricingRules(rules); err != nil {
+		return err
+	}
+
+	// make sure the defaults exist, so the first edit becomes version 2
+	if _, err := s.CurrentRules(); err != nil {
+		return err
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.PricingRuleSet{}).Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+
+		rules.ID = 0
+		rules.Version = latest + 1
+		rules.CreatedBy = adminID
+		return tx.Create(rules).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	currentPricingRules.Lock()
+	currentPricingRules.rules = rules
+	currentPricingRules.Unlock()
+
+	return nil
+}
+
+// RestoreRules makes an old version current again, as a new version.
+func (s *PricingService) RestoreRules(version int, adminID string) (*models.PricingRuleSet, error) {
+	old, err := s.GetRules(version)
+	if err != nil {
+		return nil, err
+	}
+
+	// a new version, created now
+	restored := *old
+	restored.ID = 0
+	restored.CreatedAt = time.Time{}
+	restored.Note = fmt.Sprintf("Restored from version %d", version)
+	if err := s.SaveRules(&restored, adminID); err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// Price computes the price of a book with the current rules.
+func (s *PricingService) Price(basePrice float64, category string) (*models.PriceBreakdown, error) {
+	rules, err := s.CurrentRules()
+	if err != nil {
+		return nil, err
+	}
+	return s.PriceWithRules(*rules, basePrice, category)
+}
+
+// PriceWithRules computes a price with the given rules, at the current
+// exchange rate unless the rules override it.
+func (s *PricingService) PriceWithRules(rules models.PricingRuleSet, basePrice float64, category string) (*models.PriceBreakdown, error) {
+	source := EXCHANGE_RATE_FROM_RULES
+	if rules.ExchangeRate <= 0 {
+		rate, err := s.Rates.CurrentRate(utils.DEFAULT_CURRENCY)
+		if err != nil {
+			return nil, err
+		}
+		rules.ExchangeRate = rate.Rate
+		source = EXCHANGE_RATE_FROM_TABLE
+	}
+
+	breakdown, err := ApplyPricingRules(rules, basePrice, category)
+	if err != nil {
+		return nil, err
+	}
+	breakdown.ExchangeRateSource = source
+	return breakdown, nil
+}
+
+// PriceSearchHits sets the price customers pay for each hit with the current
+// rules, hits without a provider price are left at 0.
+func (s *PricingService) PriceSearchHits(hits []models.SearchHit) error {
+	current, err := s.CurrentRules()
+	if err != nil {
+		return err
+	}
+
+	// the rate is read once for every hit
+	rules := *current
+	if rules.ExchangeRate, err = s.ExchangeRate(); err != nil {
+		return err
+	}
+
+	for i := range hits {
+		hit := &hits[i]
+		hit.LocalPrice = 0
+		if hit.Price <= 0 {
+			continue
+		}
+		if breakdown, err := ApplyPricingRules(rules, float64(hit.Price), "book"); err == nil {
+			hit.LocalPrice = breakdown.Price
+		}
+	}
+	return nil
+}
+
+// OrderFees returns the per order fees of items with the current rules.
+func (s *PricingService) OrderFees(items []models.OrderItem) (float64, error) {
+	rules, err := s.CurrentRules()
+	if err != nil {
+		return 0, err
+	}
+	return ApplyOrderFees(*rules, items), nil
+}
+
+// ExchangeRate returns the rate prices are computed with right now.
+func (s *PricingService) ExchangeRate() (float64, error) {
+	rules, err := s.CurrentRules()
+	if err != nil {
+		return 0, err
+	}
+	if rules.ExchangeRate > 0 {
+		return rules.ExchangeRate, nil
+	}
+
+	rate, err := s.Rates.CurrentRate(utils.DEFAULT_CURRENCY)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Rate, nil
+}
+
+// ApplyPricingRules converts a provider price in euros into the price the
+// customer pays: converted, plus the margin of its band, plus the fees, then
+// rounded and raised to the minimum price.
+func ApplyPricingRules(rules models.PricingRuleSet, basePrice float64, category string) (*models.PriceBreakdown, error) {
+	if basePrice <= 0 {
+		return nil, ErrPriceUnavailable
+	}
+	if rules.ExchangeRate <= 0 {
+		return nil, ErrNoExchangeRate
+	}
+
+	breakdown := &models.PriceBreakdown{
+		BasePrice:    basePrice,
+		Category:     category,
+		RulesVersion: rules.Version,
+		ExchangeRate: rules.ExchangeRate,
+		Converted:    basePrice * rules.ExchangeRate,
+		Fees:         []models.PricingFee{},
+	}
+
+	if margin := matchingMargin(rules.Margins, basePrice, category); margin != nil {
+		breakdown.MarginRule = margin
+		breakdown.Margin = breakdown.Converted*margin.Percent/100 + margin.Fixed
+	}
+
+	breakdown.Subtotal = breakdown.Converted + breakdown.Margin
+	for _, fee := range rules.Fees {
+		if !fee.PerOrder && (fee.Category == "" || fee.Category == category) {
+			breakdown.Fees = append(breakdown.Fees, fee)
+			breakdown.Subtotal += fee.Amount
+		}
+	}
+
+	breakdown.Rounded = roundPrice(breakdown.Subtotal, rules.RoundingStep, rules.RoundingMode)
+	breakdown.Price = math.Max(breakdown.Rounded, rules.MinimumPrice)
+
+	return breakdown, nil
+}
+
+// ApplyOrderFees sums the per order fees applying to items: generic ones once,
+// and the ones of a category once when an item is of that category.
+func ApplyOrderFees(rules models.PricingRuleSet, items []models.OrderItem) float64 {
+	categories := make(map[string]bool, len(items))
+	for _, item := range items {
+		categories[item.ItemType] = true
+	}
+
+	total := 0.0
+	for _, fee := range rules.Fees {
+		if fee.PerOrder && len(items) > 0 && (fee.Category == "" || categories[fee.Category]) {
+			total += fee.Amount
+		}
+	}
+	return total
+}
+
+// matchingMargin returns the margin of the band basePrice falls in, preferring
+// one specific to the category.
+func matchingMargin(margins []models.PricingMargin, basePrice float64, category string) *models.PricingMargin {
+	var generic *models.PricingMargin
+	for i := range margins {
+		margin := &margins[i]
+		if basePrice < margin.MinPrice || (margin.MaxPrice > 0 && basePrice >= margin.MaxPrice) {
+			continue
+		}
+
+		if category != "" && margin.Category == category {
+			return margin
+		}
+		if margin.Category == "" && generic == nil {
+			generic = margin
+		}
+	}
+	return generic
+}
+
+func roundPrice(price float64, step float64, mode string) float64 {
+	if step <= 0 {
+		return price
+	}
+
+	// tolerate float noise so 1300.0000001 does not round up to the next step
+	steps := price / step
+	switch mode {
+	case ROUNDING_DOWN:
+		return math.Floor(steps+1e-9) * step
+	case ROUNDING_NEAREST:
+		return math.Round(steps) * step
+	default:
+		return math.Ceil(steps-1e-9) * step
+	}
+}
+
+// ValidatePricingRules rejects rules that would produce nonsensical prices.
+func ValidatePricingRules(rules *models.PricingRuleSet) error {
+	if rules.ExchangeRate < 0 {
+		return errors.New("exchange_rate can't be negative, use 0 to follow the exchange rate table")
+	}
+	if rules.RoundingStep < 0 || rules.MinimumPrice < 0 {
+		return errors.New("rounding_step and minimum_price can't be negative")
+	}
+
+	switch rules.RoundingMode {
+	case "":
+		rules.RoundingMode = ROUNDING_UP
+	case ROUNDING_UP, ROUNDING_DOWN, ROUNDING_NEAREST:
+	default:
+		return errors.New("rounding_mode must be up, down or nearest")
+	}
+
+	for i, margin := range rules.Margins {
+		if margin.MinPrice < 0 || margin.MaxPrice < 0 || (margin.MaxPrice > 0 && margin.MaxPrice <= margin.MinPrice) {
+			return fmt.Errorf("margin %d: invalid price band [%g, %g)", i+1, margin.MinPrice, margin.MaxPrice)
+		}
+		if margin.Percent < -100 {
+			return fmt.Errorf("margin %d: percent can't be below -100", i+1)
+		}
+	}
+
+	for i, fee := range rules.Fees {
+		if fee.Name == "" {
+			return fmt.Errorf("fee %d: name is required", i+1)
+		}
+	}
+
+	if rules.Margins == nil {
+		rules.Margins = []models.PricingMargin{}
+	}
+	if rules.Fees == nil {
+		rules.Fees = []models.PricingFee{}
+	}
+
+	return nil
+}
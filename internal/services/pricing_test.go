@@ -0,0 +1,130 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"amazon/internal/database"
+	"amazon/models"
+)
+
+func TestApplyPricingRules(t *testing.T) {
+	// the defaults keep the historical FormatPrice results
+	defaults := DefaultPricingRules()
+	defaults.ExchangeRate = 260
+	for base, want := range map[float64]float64{10: 3400, 12.99: 4180, 0.5: 930} {
+		breakdown, err := ApplyPricingRules(defaults, base, "book")
+		if err != nil || breakdown.Price != want {
+			t.Errorf("default price of %g = %+v, %v, want %g", base, breakdown, err, want)
+		}
+	}
+
+	rules := models.PricingRuleSet{
+		Version:      3,
+		ExchangeRate: 250,
+		Margins: []models.PricingMargin{
+			{MinPrice: 0, MaxPrice: 20, Percent: 20},
+			{MinPrice: 20, Percent: 10},
+			{Category: "subscription", MinPrice: 0, Percent: 0, Fixed: 100},
+		},
+		Fees: []models.PricingFee{
+			{Name: "Service", Amount: 500},
+			{Name: "Gift wrap", Category: "gift", Amount: 300},
+			{Name: "Handling", Amount: 400, PerOrder: true},
+			{Name: "Activation", Category: "subscription", Amount: 200, PerOrder: true},
+		},
+		RoundingStep: 100,
+		RoundingMode: ROUNDING_NEAREST,
+		MinimumPrice: 2000,
+	}
+
+	tests := []struct {
+		base     float64
+		category string
+		want     float64
+	}{
+		{10, "book", 3500},         // 2500 + 20% + 500 = 3500
+		{30, "book", 8800},         // 7500 + 10% + 500 = 8750, nearest 100
+		{10, "subscription", 3100}, // 2500 + 100 + 500
+		{2, "book", 2000},          // 600 + 120 + 500 raised to the minimum
+		{10, "gift", 3800},         // generic band and both fees
+	}
+
+	for _, test := range tests {
+		breakdown, err := ApplyPricingRules(rules, test.base, test.category)
+		if err != nil || breakdown.Price != test.want || breakdown.RulesVersion != 3 {
+			t.Errorf("price of %g (%s) = %+v, %v, want %g", test.base, test.category, breakdown, err, test.want)
+		}
+	}
+
+	if _, err := ApplyPricingRules(rules, -1, "book"); err != ErrPriceUnavailable {
+		t.Errorf("negative price error = %v", err)
+	}
+
+	// per order fees are charged once, not with every book
+	books := []models.OrderItem{{ItemType: "book", Quantity: 2}, {ItemType: "book", Quantity: 1}}
+	if fees := ApplyOrderFees(rules, books); fees != 400 {
+		t.Errorf("order fees of books = %g, want 400", fees)
+	}
+	if fees := ApplyOrderFees(rules, append(books, models.OrderItem{ItemType: "subscription", Quantity: 1})); fees != 600 {
+		t.Errorf("order fees with a subscription = %g, want 600", fees)
+	}
+	if fees := ApplyOrderFees(rules, nil); fees != 0 {
+		t.Errorf("order fees of an empty order = %g", fees)
+	}
+}
+
+func TestSavePricingRules(t *testing.T) {
+	useTestDatabase(t)
+	currentPricingRules.rules = nil
+	t.Cleanup(func() { currentPricingRules.rules = nil })
+
+	service := NewPricingService()
+
+	current, err := service.CurrentRules()
+	if err != nil || current.Version != 1 {
+		t.Fatalf("current rules = %+v, %v, want the defaults", current, err)
+	}
+
+	// the defaults follow the exchange rate table
+	if _, err := service.Price(10, "book"); err != ErrNoExchangeRate {
+		t.Errorf("price without any rate: %v", err)
+	}
+	rate := models.ExchangeRate{Rate: 250, EffectiveAt: time.Now().Add(-time.Hour)}
+	if err := service.Rates.SetRate(&rate, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if breakdown, _ := service.Price(10, "book"); breakdown.Price != 3300 || breakdown.ExchangeRateSource != EXCHANGE_RATE_FROM_TABLE {
+		t.Errorf("price with the table rate = %+v", breakdown)
+	}
+
+	edited := DefaultPricingRules()
+	edited.ExchangeRate = 280
+	if err := service.SaveRules(&edited, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if breakdown, _ := service.Price(10, "book"); breakdown.Price != 3600 || breakdown.RulesVersion != 2 {
+		t.Errorf("price with version 2 = %+v", breakdown)
+	}
+
+	created := time.Now().Add(-48 * time.Hour)
+	if err := database.DB.Model(&models.PricingRuleSet{}).Where("version = 1").Update("created_at", created).Error; err != nil {
+		t.Fatal(err)
+	}
+	restored, err := service.RestoreRules(1, "1")
+	if err != nil || restored.Version != 3 || restored.ExchangeRate != 0 || !restored.CreatedAt.After(created.Add(time.Hour)) {
+		t.Errorf("restored = %+v, %v", restored, err)
+	}
+
+	// search hits are priced like quotes, without a provider price they stay unpriced
+	hits := []models.SearchHit{{ID: "1", Price: 10}, {ID: "2"}}
+	if err := service.PriceSearchHits(hits); err != nil || hits[0].LocalPrice != 3300 || hits[1].LocalPrice != 0 {
+		t.Errorf("priced hits = %+v, %v", hits, err)
+	}
+
+	invalid := models.PricingRuleSet{ExchangeRate: 260, RoundingMode: "sideways"}
+	if err := service.SaveRules(&invalid, "1"); err == nil {
+		t.Error("invalid rounding mode saved")
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// arabicForms are the presentation forms of the Arabic letters: isolated,
+// final, initial and medial. Letters with two forms don't join the next one.
+var arabicForms = map[rune][]rune{
+	0x0621: {0xFE80},
+	0x0622: {0xFE81, 0xFE82},
+	0x0623: {0xFE83, 0xFE84},
+	0x0624: {0xFE85, 0xFE86},
+	0x0625: {0xFE87, 0xFE88},
+	0x0626: {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C},
+	0x0627: {0xFE8D, 0xFE8E},
+	0x0628: {0xFE8F, 0xFE90, 0xFE91, 0xFE92},
+	0x0629: {0xFE93, 0xFE94},
+	0x062A: {0xFE95, 0xFE96, 0xFE97, 0xFE98},
+	0x062B: {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C},
+	0x062C: {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0},
+	0x062D: {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4},
+	0x062E: {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8},
+	0x062F: {0xFEA9, 0xFEAA},
+	0x0630: {0xFEAB, 0xFEAC},
+	0x0631: {0xFEAD, 0xFEAE},
+	0x0632: {0xFEAF, 0xFEB0},
+	0x0633: {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4},
+	0x0634: {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8},
+	0x0635: {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC},
+	0x0636: {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0},
+	0x0637: {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4},
+	0x0638: {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8},
+	0x0639: {0xFEC9, 0xFECA, 0xFECB, 0xFECC},
+	0x063A: {0xFECD, 0xFECE, 0xFECF, 0xFED0},
+	0x0640: {0x0640, 0x0640, 0x0640, 0x0640}, // tatweel
+	0x0641: {0xFED1, 0xFED2, 0xFED3, 0xFED4},
+	0x0642: {0xFED5, 0xFED6, 0xFED7, 0xFED8},
+	0x0643: {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC},
+	0x0644: {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0},
+	0x0645: {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4},
+	0x0646: {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8},
+	0x0647: {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC},
+	0x0648: {0xFEED, 0xFEEE},
+	0x0649: {0xFEEF, 0xFEF0},
+	0x064A: {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4},
+}
+
+// lamAlefForms are the ligatures of lam followed by an alef, isolated and
+// final.
+var lamAlefForms = map[rune][]rune{
+	0x0622: {0xFEF5, 0xFEF6},
+	0x0623: {0xFEF7, 0xFEF8},
+	0x0625: {0xFEF9, 0xFEFA},
+	0x0627: {0xFEFB, 0xFEFC},
+}
+
+const (
+	arabicIsolated = iota
+	arabicFinal
+	arabicInitial
+	arabicMedial
+)
+
+// isArabicMark tells the vowel signs, they sit on a letter and don't break
+// the joining.
+func isArabicMark(r rune) bool {
+	return r >= 0x064B && r <= 0x0652 || r == 0x0670
+}
+
+// isArabic tells the characters written right to left, the digits are
+// written left to right in Arabic too.
+func isArabic(r rune) bool {
+	if r >= 0x0660 && r <= 0x0669 || r >= 0x06F0 && r <= 0x06F9 {
+		return false
+	}
+	return r >= 0x0600 && r <= 0x06FF || r >= 0xFB50 && r <= 0xFDFF || r >= 0xFE70 && r <= 0xFEFF
+}
+
+// printable prepares a line for the PDF: Arabic letters take their joined
+// forms and Arabic runs are reversed, the PDF lays text out left to right.
+// Mixed lines keep the other text in order.
+func printable(line string) string {
+	runes := []rune(encodable(line))
+	runes = shapeArabic(runes)
+
+	// reverse the Arabic runs, spaces between two Arabic words included
+	for start := 0; start < len(runes); {
+		if !isArabic(runes[start]) {
+			start++
+			continue
+		}
+		end, last := start, start
+		for end < len(runes) && (isArabic(runes[end]) || runes[end] == ' ') {
+			if isArabic(runes[end]) {
+				last = end
+			}
+			end++
+		}
+		reverseClusters(runes[start : last+1])
+		start = last + 1
+	}
+	return string(runes)
+}
+
+// encodable replaces the characters the embedded fonts can't encode, those
+// beyond 16 bits, by "?".
+func encodable(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || r > 0xFFFF {
+			return '?'
+		}
+		return r
+	}, text)
+}
+
+// reverseClusters reverses runes, the vowel signs staying after their letter
+// where the font draws them.
+func reverseClusters(runes []rune) {
+	slices.Reverse(runes)
+	for i := 0; i < len(runes); i++ {
+		start := i
+		for i < len(runes) && isArabicMark(runes[i]) {
+			i++
+		}
+		if i > start && i < len(runes) {
+			// the marks came before their letter, put it back first
+			slices.Reverse(runes[start:i])
+			letter := runes[i]
+			copy(runes[start+1:i+1], runes[start:i])
+			runes[start] = letter
+		}
+	}
+}
+
+// shapeArabic replaces the Arabic letters by the form they take next to
+// their neighbours.
+func shapeArabic(runes []rune) []rune {
+	// previous tells whether the letter before i reaches out to it, next
+	// whether the letter after i is one, skipping the vowel signs
+	previous := func(i int) bool {
+		for i--; i >= 0; i-- {
+			if !isArabicMark(runes[i]) {
+				forms, ok := arabicForms[runes[i]]
+				return ok && len(forms) == 4
+			}
+		}
+		return false
+	}
+	next := func(i int) bool {
+		for i++; i < len(runes); i++ {
+			if !isArabicMark(runes[i]) {
+				forms, ok := arabicForms[runes[i]]
+				return ok && len(forms) > 1
+			}
+		}
+		return false
+	}
+
+	shaped := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		forms, ok := arabicForms[r]
+		if !ok {
+			shaped = append(shaped, r)
+			continue
+		}
+		afterJoining := len(forms) > 1 && previous(i)
+
+		// lam and alef make a ligature
+		if r == 0x0644 && i+1 < len(runes) {
+			if ligature, ok := lamAlefForms[runes[i+1]]; ok {
+				if afterJoining {
+					shaped = append(shaped, ligature[arabicFinal])
+				} else {
+					shaped = append(shaped, ligature[arabicIsolated])
+				}
+				i++
+				continue
+			}
+		}
+
+		beforeJoining := len(forms) == 4 && next(i)
+		switch {
+		case afterJoining && beforeJoining:
+			shaped = append(shaped, forms[arabicMedial])
+		case afterJoining:
+			shaped = append(shaped, forms[arabicFinal])
+		case beforeJoining:
+			shaped = append(shaped, forms[arabicInitial])
+		default:
+			shaped = append(shaped, forms[arabicIsolated])
+		}
+	}
+	return shaped
+}
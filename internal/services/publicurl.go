@@ -0,0 +1,30 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var ErrInvalidPublicURL = errors.New("PUBLIC_URL must be the absolute http or https URL of the server")
+
+// publicURL is where customers reach the server, set once at startup. The
+// links of the emails, the QR codes of the printed documents and the cover
+// URLs start with it, they are relative without it.
+var publicURL string
+
+// SetPublicURL checks and sets the URL customers reach the server at,
+// "https://example.com" or "https://example.com/api".
+func SetPublicURL(raw string) error {
+	raw = strings.TrimSuffix(strings.TrimSpace(raw), "/")
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidPublicURL
+	}
+	publicURL = raw
+	return nil
+}
+
+func PublicURL() string {
+	return publicURL
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+// usePublicURL sets the public URL for the duration of a test.
+func usePublicURL(t *testing.T, raw string) {
+	t.Helper()
+	previous := publicURL
+	if err := SetPublicURL(raw); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { publicURL = previous })
+}
+
+func TestSetPublicURL(t *testing.T) {
+	usePublicURL(t, "https://example.com/api/ ")
+	if got := TrackingURL("abc"); got != "https://example.com/api/track/abc" {
+		t.Errorf("tracking URL = %q", got)
+	}
+	if got := QuoteURL("abc"); got != "https://example.com/api/orders/quote/abc" {
+		t.Errorf("quote URL = %q", got)
+	}
+
+	for _, raw := range []string{"", "example.com", "/api", "ftp://example.com", "https://"} {
+		if err := SetPublicURL(raw); !errors.Is(err, ErrInvalidPublicURL) {
+			t.Errorf("SetPublicURL(%q) = %v", raw, err)
+		}
+	}
+	if PublicURL() != "https://example.com/api" {
+		t.Errorf("an invalid URL replaced the public URL: %q", PublicURL())
+	}
+}
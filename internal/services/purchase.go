@@ -0,0 +1,403 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/internal/scrapers/books"
+	"amazon/internal/utils"
+	"amazon/models"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPurchaseNotFound        = errors.New("purchase batch not found")
+	ErrUnknownPurchaseStatus   = errors.New("unknown purchase batch status, expected draft, placed or received")
+	ErrIllegalPurchaseChange   = errors.New("illegal purchase batch status change")
+	ErrEmptyPurchase           = errors.New("the purchase batch has no books left to buy")
+	ErrInvalidPurchaseCost     = errors.New("the cost can't be negative")
+	ErrPurchaseNotDraft        = errors.New("only draft purchase batches can be deleted")
+	ErrNothingToPurchase       = errors.New("no confirmed order has books left to buy")
+	ErrUnknownPurchaseProvider = errors.New("unknown provider, expected amazon or lireka")
+)
+
+// PurchaseTransitions lists the statuses a batch can move to from each status.
+var PurchaseTransitions = map[string][]string{
+	models.PURCHASE_DRAFT:    {models.PURCHASE_PLACED},
+	models.PURCHASE_PLACED:   {models.PURCHASE_RECEIVED},
+	models.PURCHASE_RECEIVED: {},
+}
+
+// the order each batch status moves its orders to, once all their books got there
+var purchaseOrderStatus = map[string]string{
+	models.PURCHASE_PLACED:   models.ORDER_STATUS_ORDERED_FROM_SUPPLIER,
+	models.PURCHASE_RECEIVED: models.ORDER_STATUS_RECEIVED,
+}
+
+type PurchaseService struct{}
+
+func NewPurchaseService() *PurchaseService {
+	return &PurchaseService{}
+}
+
+// PurchaseChanges are the details of a batch an admin fills in, nil fields
+// are left as they are.
+type PurchaseChanges struct {
+	Cost      *float64 `json:"cost"`
+	Reference *string  `json:"reference"`
+	Note      *string  `json:"note"`
+}
+
+// BatchConfirmedOrders gathers the books of the confirmed orders that are in
+// no batch yet into a draft batch per provider, only provider's when given.
+// Books join the provider's draft batch when there is one.
+func (s *PurchaseService) BatchConfirmedOrders(provider string, adminID string) ([]models.PurchaseBatch, error) {
+	if provider != "" && provider != books.PROVIDER_AMAZON && provider != books.PROVIDER_LIREKA {
+		return nil, ErrUnknownPurchaseProvider
+	}
+
+	var items []models.OrderItem
+	err := database.DB.
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("orders.status = ? AND order_items.item_type = ? AND order_items.purchase_batch_id IS NULL", models.ORDER_STATUS_CONFIRMED, "book").
+		Order("order_items.id").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byProvider := make(map[string][]uint)
+	var providers []string
+	for _, item := range items {
+		itemProvider := purchaseProvider(item)
+		if provider != "" && itemProvider != provider {
+			continue
+		}
+		if _, ok := byProvider[itemProvider]; !ok {
+			providers = append(providers, itemProvider)
+		}
+		byProvider[itemProvider] = append(byProvider[itemProvider], item.ID)
+	}
+	if len(providers) == 0 {
+		return nil, ErrNothingToPurchase
+	}
+	slices.Sort(providers)
+
+	batches := make([]models.PurchaseBatch, 0, len(providers))
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, itemProvider := range providers {
+			var batch models.PurchaseBatch
+			err := tx.Where("provider = ? AND status = ?", itemProvider, models.PURCHASE_DRAFT).Order("id").First(&batch).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				batch = models.PurchaseBatch{Provider: itemProvider, Status: models.PURCHASE_DRAFT, CreatedBy: adminID}
+				err = tx.Create(&batch).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			// conditioned on the item being free, a concurrent batching doesn't take it twice
+			err = tx.Model(&models.OrderItem{}).
+				Where("id IN ? AND purchase_batch_id IS NULL", byProvider[itemProvider]).
+				Update("purchase_batch_id", batch.ID).Error
+			if err != nil {
+				return err
+			}
+			if err := refreshEstimatedCost(tx, &batch); err != nil {
+				return err
+			}
+			batches = append(batches, batch)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range batches {
+		if err := loadPurchaseLines(database.DB, &batches[i]); err != nil {
+			return nil, err
+		}
+	}
+	return batches, nil
+}
+
+// purchaseProvider is where the book of item is bought, the orders from
+// before the snapshots were all priced at Amazon.
+func purchaseProvider(item models.OrderItem) string {
+	if item.Provider == "" {
+		return books.PROVIDER_AMAZON
+	}
+	return item.Provider
+}
+
+// ListPurchases returns the batches, newest first, only those in status when
+// given.
+func (s *PurchaseService) ListPurchases(status string) ([]models.PurchaseBatch, error) {
+	query := database.DB.Order("created_at DESC, id DESC")
+	if status != "" {
+		if _, ok := PurchaseTransitions[status]; !ok {
+			return nil, ErrUnknownPurchaseStatus
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	batches := make([]models.PurchaseBatch, 0)
+	if err := query.Find(&batches).Error; err != nil {
+		return nil, err
+	}
+	for i := range batches {
+		if err := loadPurchaseLines(database.DB, &batches[i]); err != nil {
+			return nil, err
+		}
+	}
+	return batches, nil
+}
+
+func (s *PurchaseService) GetPurchase(id string) (*models.PurchaseBatch, error) {
+	var batch models.PurchaseBatch
+	if err := database.DB.First(&batch, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPurchaseNotFound
+		}
+		return nil, err
+	}
+	if err := loadPurchaseLines(database.DB, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// UpdatePurchase saves the cost, the provider's reference or the note of a
+// batch.
+func (s *PurchaseService) UpdatePurchase(id string, changes PurchaseChanges) (*models.PurchaseBatch, error) {
+	batch, err := s.GetPurchase(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]any)
+	if changes.Cost != nil {
+		if *changes.Cost < 0 || math.IsNaN(*changes.Cost) || math.IsInf(*changes.Cost, 0) {
+			return nil, ErrInvalidPurchaseCost
+		}
+		updates["cost"] = *changes.Cost
+	}
+	if changes.Reference != nil {
+		updates["reference"] = strings.TrimSpace(*changes.Reference)
+	}
+	if changes.Note != nil {
+		updates["note"] = strings.TrimSpace(*changes.Note)
+	}
+	if len(updates) == 0 {
+		return batch, nil
+	}
+
+	if err := database.DB.Model(batch).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return s.GetPurchase(id)
+}
+
+// SetPurchaseStatus moves a batch forward. Placing a draft first releases the
+// books of the orders that aren't confirmed anymore. The orders then follow
+// their books: once every book of an order is placed it is ordered from the
+// supplier, once every book is received the order is received.
+func (s *PurchaseService) SetPurchaseStatus(id string, status string, adminID string) (*models.PurchaseBatch, error) {
+	if _, ok := PurchaseTransitions[status]; !ok {
+		return nil, ErrUnknownPurchaseStatus
+	}
+
+	batch, err := s.GetPurchase(id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(PurchaseTransitions[batch.Status], status) {
+		return batch, fmt.Errorf("%w from %s to %s", ErrIllegalPurchaseChange, batch.Status, status)
+	}
+
+	now := time.Now()
+	updates := map[string]any{"status": status}
+	switch status {
+	case models.PURCHASE_PLACED:
+		updates["placed_at"] = now
+	case models.PURCHASE_RECEIVED:
+		updates["received_at"] = now
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if status == models.PURCHASE_PLACED {
+			if err := releaseUnconfirmedItems(tx, batch); err != nil {
+				return err
+			}
+			if len(batch.Lines) == 0 {
+				return ErrEmptyPurchase
+			}
+			updates["estimated_cost"] = batch.EstimatedCost
+		}
+
+		result := tx.Model(&models.PurchaseBatch{}).
+			Where("id = ? AND status = ?", batch.ID, batch.Status).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: the batch changed in the meantime", ErrIllegalPurchaseChange)
+		}
+		return nil
+	})
+	if err != nil {
+		return batch, err
+	}
+
+	advancePurchasedOrders(batch, status, adminID)
+	return s.GetPurchase(id)
+}
+
+// DeletePurchase deletes a draft batch, its books can be batched again.
+func (s *PurchaseService) DeletePurchase(id string) error {
+	batch, err := s.GetPurchase(id)
+	if err != nil {
+		return err
+	}
+	if batch.Status != models.PURCHASE_DRAFT {
+		return ErrPurchaseNotDraft
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.OrderItem{}).Where("purchase_batch_id = ?", batch.ID).Update("purchase_batch_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("status = ?", models.PURCHASE_DRAFT).Delete(&models.PurchaseBatch{}, batch.ID).Error
+	})
+}
+
+// releaseUnconfirmedItems takes out of a draft batch the books of the orders
+// cancelled or deleted since it was drafted.
+func releaseUnconfirmedItems(tx *gorm.DB, batch *models.PurchaseBatch) error {
+	confirmed := tx.Model(&models.Order{}).Select("id").Where("status = ?", models.ORDER_STATUS_CONFIRMED)
+	err := tx.Model(&models.OrderItem{}).
+		Where("purchase_batch_id = ? AND order_id NOT IN (?)", batch.ID, confirmed).
+		Update("purchase_batch_id", nil).Error
+	if err != nil {
+		return err
+	}
+
+	if err := refreshEstimatedCost(tx, batch); err != nil {
+		return err
+	}
+	return loadPurchaseLines(tx, batch)
+}
+
+func refreshEstimatedCost(tx *gorm.DB, batch *models.PurchaseBatch) error {
+	var cost float64
+	err := tx.Model(&models.OrderItem{}).
+		Select("COALESCE(SUM(base_price * quantity), 0)").
+		Where("purchase_batch_id = ?", batch.ID).
+		Scan(&cost).Error
+	if err != nil {
+		return err
+	}
+
+	batch.EstimatedCost = math.Round(cost*100) / 100
+	return tx.Model(batch).Update("estimated_cost", batch.EstimatedCost).Error
+}
+
+// advancePurchasedOrders moves the orders of batch whose books all reached
+// status, skipping those that can't move from where they are.
+func advancePurchasedOrders(batch *models.PurchaseBatch, status string, adminID string) {
+	orderStatus := purchaseOrderStatus[status]
+	reached := []string{models.PURCHASE_RECEIVED}
+	if status == models.PURCHASE_PLACED {
+		reached = append(reached, models.PURCHASE_PLACED)
+	}
+
+	var orderIDs []uint
+	for _, line := range batch.Lines {
+		for _, id := range line.OrderIDs {
+			if !slices.Contains(orderIDs, id) {
+				orderIDs = append(orderIDs, id)
+			}
+		}
+	}
+
+	for _, id := range orderIDs {
+		// books of the order that are elsewhere than in a batch that reached status
+		var waiting int64
+		err := database.DB.Model(&models.OrderItem{}).
+			Where("order_id = ? AND item_type = ?", id, "book").
+			Where("purchase_batch_id IS NULL OR purchase_batch_id NOT IN (?)",
+				database.DB.Model(&models.PurchaseBatch{}).Select("id").Where("status IN ?", reached)).
+			Count(&waiting).Error
+		if err != nil {
+			utils.Report(fmt.Sprintf("Can't check the books of order %d: %s", id, err))
+			continue
+		}
+		if waiting > 0 {
+			continue
+		}
+
+		var order models.Order
+		if err := database.DB.First(&order, id).Error; err != nil {
+			continue // deleted since
+		}
+		if order.Status == orderStatus || !CanTransitionOrder(order.Status, orderStatus) {
+			continue
+		}
+
+		event := adminEvent(adminID, purchaseComment(batch, status))
+		if err := transitionOrder(&order, orderStatus, nil, event); err != nil {
+			utils.Report(fmt.Sprintf("Can't move order %d to %s: %s", id, orderStatus, err))
+		}
+	}
+}
+
+func purchaseComment(batch *models.PurchaseBatch, status string) string {
+	if status == models.PURCHASE_RECEIVED {
+		return fmt.Sprintf("Lot d'achat #%d reçu", batch.ID)
+	}
+	return fmt.Sprintf("Lot d'achat #%d commandé chez %s", batch.ID, batch.Provider)
+}
+
+// loadPurchaseLines groups the books of batch by ID, sorted by title.
+func loadPurchaseLines(tx *gorm.DB, batch *models.PurchaseBatch) error {
+	var items []models.OrderItem
+	if err := tx.Where("purchase_batch_id = ?", batch.ID).Order("order_id, id").Find(&items).Error; err != nil {
+		return err
+	}
+
+	lines := make([]models.PurchaseLine, 0)
+	index := make(map[string]int)
+	for _, item := range items {
+		i, ok := index[item.ItemID]
+		if !ok {
+			i = len(lines)
+			index[item.ItemID] = i
+			lines = append(lines, models.PurchaseLine{ItemID: item.ItemID, Title: item.Title, Author: item.Author, OrderIDs: []uint{}})
+		}
+		line := &lines[i]
+		line.Quantity += item.Quantity
+		if item.BasePrice > 0 {
+			line.UnitCost = item.BasePrice // the latest order's price
+		}
+		if !slices.Contains(line.OrderIDs, item.OrderID) {
+			line.OrderIDs = append(line.OrderIDs, item.OrderID)
+		}
+	}
+
+	slices.SortStableFunc(lines, func(a, b models.PurchaseLine) int {
+		if order := strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)); order != 0 {
+			return order
+		}
+		return strings.Compare(a.ItemID, b.ItemID)
+	})
+	batch.Lines = lines
+	return nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"amazon/internal/database"
+	"amazon/internal/scrapers/books"
+	"amazon/models"
+)
+
+func TestPurchaseBatches(t *testing.T) {
+	useTestDatabase(t)
+	service := NewPurchaseService()
+	orders := NewOrderService()
+
+	seed := []models.Order{
+		{Name: "Amine", Status: models.ORDER_STATUS_CONFIRMED, OrderItems: []models.OrderItem{
+			{ItemType: "book", ItemID: "B1", Title: "L'Étranger", Quantity: 1, BasePrice: 10, Provider: books.PROVIDER_AMAZON},
+			{ItemType: "book", ItemID: "L1", Title: "Nedjma", Quantity: 1, BasePrice: 12, Provider: books.PROVIDER_LIREKA},
+		}},
+		{Name: "Sara", Status: models.ORDER_STATUS_CONFIRMED, OrderItems: []models.OrderItem{
+			{ItemType: "book", ItemID: "B1", Title: "L'Étranger", Quantity: 2, BasePrice: 10},
+			{ItemType: "subscription", ItemID: "S1", Quantity: 1},
+		}},
+		{Name: "Karim", Status: models.ORDER_STATUS_CONFIRMED, OrderItems: []models.OrderItem{
+			{ItemType: "book", ItemID: "B2", Title: "Zadig", Quantity: 1, BasePrice: 8, Provider: books.PROVIDER_AMAZON},
+		}},
+		{Name: "Nadia", Status: models.ORDER_STATUS_QUOTED, OrderItems: []models.OrderItem{
+			{ItemType: "book", ItemID: "B3", Quantity: 1, BasePrice: 5},
+		}},
+	}
+	for i := range seed {
+		if err := database.DB.Create(&seed[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	amine, sara, karim := fmt.Sprint(seed[0].ID), fmt.Sprint(seed[1].ID), fmt.Sprint(seed[2].ID)
+
+	if _, err := service.BatchConfirmedOrders("fnac", "1"); !errors.Is(err, ErrUnknownPurchaseProvider) {
+		t.Fatalf("unknown provider: %v", err)
+	}
+
+	batches, err := service.BatchConfirmedOrders("", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 || batches[0].Provider != books.PROVIDER_AMAZON || batches[1].Provider != books.PROVIDER_LIREKA {
+		t.Fatalf("batches = %+v", batches)
+	}
+
+	amazon := batches[0]
+	if amazon.Status != models.PURCHASE_DRAFT || amazon.EstimatedCost != 38 || len(amazon.Lines) != 2 {
+		t.Fatalf("amazon batch = %+v", amazon)
+	}
+	if line := amazon.Lines[0]; line.ItemID != "B1" || line.Quantity != 3 || line.UnitCost != 10 || fmt.Sprint(line.OrderIDs) != fmt.Sprint([]uint{seed[0].ID, seed[1].ID}) {
+		t.Fatalf("first line = %+v", line)
+	}
+	if _, err := service.BatchConfirmedOrders("", "1"); !errors.Is(err, ErrNothingToPurchase) {
+		t.Fatalf("batching twice: %v", err)
+	}
+
+	// cancelled before the batch is placed, Karim's book is released
+	if _, err := orders.SetOrderStatus(karim, models.ORDER_STATUS_CANCELLED, "1", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	id := fmt.Sprint(amazon.ID)
+	if _, err := service.SetPurchaseStatus(id, models.PURCHASE_RECEIVED, "1"); !errors.Is(err, ErrIllegalPurchaseChange) {
+		t.Fatalf("receiving a draft: %v", err)
+	}
+	cost := 31.5
+	if _, err := service.UpdatePurchase(id, PurchaseChanges{Cost: &cost}); err != nil {
+		t.Fatal(err)
+	}
+
+	placed, err := service.SetPurchaseStatus(id, models.PURCHASE_PLACED, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placed.PlacedAt == nil || placed.EstimatedCost != 30 || placed.Cost != 31.5 || len(placed.Lines) != 1 {
+		t.Fatalf("placed = %+v", placed)
+	}
+
+	// Sara's only book is placed, Amine still waits for the Lireka batch
+	status := func(id string) string {
+		order, err := orders.GetOrderByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return order.Status
+	}
+	if status(sara) != models.ORDER_STATUS_ORDERED_FROM_SUPPLIER || status(amine) != models.ORDER_STATUS_CONFIRMED {
+		t.Fatalf("after placing: sara %s, amine %s", status(sara), status(amine))
+	}
+
+	lireka := fmt.Sprint(batches[1].ID)
+	if _, err := service.SetPurchaseStatus(lireka, models.PURCHASE_PLACED, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if status(amine) != models.ORDER_STATUS_ORDERED_FROM_SUPPLIER {
+		t.Fatalf("amine after placing both = %s", status(amine))
+	}
+
+	if _, err := service.SetPurchaseStatus(id, models.PURCHASE_RECEIVED, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if status(sara) != models.ORDER_STATUS_RECEIVED || status(amine) != models.ORDER_STATUS_ORDERED_FROM_SUPPLIER {
+		t.Fatalf("after receiving: sara %s, amine %s", status(sara), status(amine))
+	}
+	if _, err := service.SetPurchaseStatus(lireka, models.PURCHASE_RECEIVED, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if status(amine) != models.ORDER_STATUS_RECEIVED {
+		t.Fatalf("amine after receiving both = %s", status(amine))
+	}
+
+	history, _ := NewOrderEventService().History(sara)
+	last := history[len(history)-1]
+	if last.ToStatus != models.ORDER_STATUS_RECEIVED || last.Comment != fmt.Sprintf("Lot d'achat #%d reçu", amazon.ID) {
+		t.Fatalf("last event = %+v", last)
+	}
+
+	if err := service.DeletePurchase(id); !errors.Is(err, ErrPurchaseNotDraft) {
+		t.Fatalf("deleting a received batch: %v", err)
+	}
+	received, err := service.ListPurchases(models.PURCHASE_RECEIVED)
+	if err != nil || len(received) != 2 {
+		t.Fatalf("received batches = %d, %v", len(received), err)
+	}
+}
+
+func TestDeleteDraftPurchase(t *testing.T) {
+	useTestDatabase(t)
+	service := NewPurchaseService()
+
+	order := models.Order{Status: models.ORDER_STATUS_CONFIRMED, OrderItems: []models.OrderItem{
+		{ItemType: "book", ItemID: "B1", Quantity: 1, BasePrice: 10},
+	}}
+	if err := database.DB.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	batches, err := service.BatchConfirmedOrders(books.PROVIDER_AMAZON, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DeletePurchase(fmt.Sprint(batches[0].ID)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.GetPurchase(fmt.Sprint(batches[0].ID)); !errors.Is(err, ErrPurchaseNotFound) {
+		t.Fatalf("deleted batch: %v", err)
+	}
+
+	// the book can be batched again
+	again, err := service.BatchConfirmedOrders("", "1")
+	if err != nil || len(again) != 1 || again[0].Lines[0].Quantity != 1 {
+		t.Fatalf("batching again = %+v, %v", again, err)
+	}
+}
+
+func TestPostedPurchaseBatch(t *testing.T) {
+	useTestDatabase(t)
+	currentPricingRules.rules = nil
+	t.Cleanup(func() { currentPricingRules.rules = nil })
+
+	orders := NewOrderService()
+	orders.Quotes.FetchBook = func(provider, id string) (*models.Book, string, error) {
+		return &models.Book{ID: id, Title: "Book " + id, Price: 10}, "", nil
+	}
+
+	// a customer can't place their book in a batch that is never bought
+	var order models.Order
+	body := `{"name": "Amine", "email": "amine@example.com", "phone": "0555", "address": "Alger",
+		"order_items": [{"id": 99, "itemType": "book", "itemId": "B1", "quantity": 1, "purchaseBatchId": 7}]}`
+	if err := json.Unmarshal([]byte(body), &order); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.CreateOrder(&order); err != nil {
+		t.Fatal(err)
+	}
+	if item := order.OrderItems[0]; item.ID == 99 || item.PurchaseBatchID != nil {
+		t.Fatalf("posted item = %+v", item)
+	}
+
+	if err := database.DB.Model(&order).Update("status", models.ORDER_STATUS_CONFIRMED).Error; err != nil {
+		t.Fatal(err)
+	}
+	batches, err := NewPurchaseService().BatchConfirmedOrders("", "1")
+	if err != nil || len(batches) != 1 || len(batches[0].Lines) != 1 || batches[0].Lines[0].ItemID != "B1" {
+		t.Fatalf("batches = %+v, %v", batches, err)
+	}
+}
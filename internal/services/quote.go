@@ -0,0 +1,515 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/internal/scrapers/books"
+	"amazon/internal/utils"
+	"amazon/mail"
+	"amazon/models"
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrderNotFound   = errors.New("order not found")
+	ErrQuoteState      = errors.New("the quote of this order can't be changed anymore")
+	ErrQuoteIncomplete = errors.New("every item needs a price before the quote is sent")
+	ErrQuoteNotFound   = errors.New("quote not found")
+	ErrQuoteExpired    = errors.New("this quote has expired")
+)
+
+// QuoteLine is an order item as the customer sees it in the quote.
+type QuoteLine struct {
+	Title     string
+	Quantity  int
+	UnitPrice string
+	Total     string
+}
+
+type QuoteService struct {
+	Pricing *PricingService
+
+	// FetchBook reads the books of an order at their provider, Amazon when
+	// provider is empty, from the cache most of the time
+	FetchBook func(provider string, id string) (*models.Book, string, error)
+}
+
+func NewQuoteService() *QuoteService {
+	return &QuoteService{
+		Pricing:   NewPricingService(),
+		FetchBook: books.FetchProviderBook,
+	}
+}
+
+// SnapshotItems describes and prices the items of a new order: the title,
+// cover, authors, provider and stock status of the books are kept so the order reads the
+// same once the cache changes, and prices are computed with the current
+// rules. Items the rules can't price, subscriptions included, are left at 0
+// for an admin to fill in.
+func (s *QuoteService) SnapshotItems(order *models.Order) {
+	lookups := make(bookLookups)
+	for i := range order.OrderItems {
+		item := &order.OrderItems[i]
+		provider := purchaseProvider(*item)
+		item.BasePrice, item.ComputedPrice, item.UnitPrice = 0, 0, 0
+		item.Title, item.Cover, item.Author, item.Provider, item.Availability = "", "", "", "", ""
+
+		if item.ItemType != "book" {
+			item.Title = "Abonnement " + item.ItemID
+			continue
+		}
+
+		book, _, err := s.lookupBook(lookups, provider, item.ItemID)
+		if err != nil {
+			utils.Report("Can't price the book " + item.ItemID + ": " + err.Error())
+			continue
+		}
+		item.Title = book.Title
+		item.Cover = book.Cover
+		item.Author = authorNames(book.Authors)
+		item.Provider = provider
+		item.Availability = book.Availability
+		item.BasePrice = float64(book.Price)
+
+		breakdown, err := s.Pricing.Price(item.BasePrice, item.ItemType)
+		if err != nil {
+			if !errors.Is(err, ErrPriceUnavailable) {
+				utils.Report("Can't price the book " + item.ItemID + ": " + err.Error())
+			}
+			continue
+		}
+		item.ComputedPrice = breakdown.Price
+		item.UnitPrice = breakdown.Price
+	}
+}
+
+// bookLookup is what a provider answered about one book.
+type bookLookup struct {
+	book    *models.Book
+	errCode string
+	err     error
+}
+
+// bookLookups are the answers of one order, by provider and ID.
+type bookLookups map[string]bookLookup
+
+// lookupBook fetches a book at its provider, Amazon when none is given, once
+// per order however many lines repeat it.
+func (s *QuoteService) lookupBook(lookups bookLookups, provider string, id string) (*models.Book, string, error) {
+	if provider == "" {
+		provider = books.PROVIDER_AMAZON
+	}
+
+	key := provider + "/" + id
+	lookup, ok := lookups[key]
+	if !ok {
+		lookup.book, lookup.errCode, lookup.err = s.FetchBook(provider, id)
+		lookups[key] = lookup
+	}
+	return lookup.book, lookup.errCode, lookup.err
+}
+
+func authorNames(authors []models.AuthorType) string {
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// QuoteChanges are the adjustments of an admin, nil fields are left as is.
+type QuoteChanges struct {
+	Prices       map[uint]float64 // unit price by item ID
+	ShippingCost *float64
+	Fees         *float64
+	Note         *string
+}
+
+// UpdateQuote adjusts the item prices, the shipping cost, the fees and the
+// note shown to the customer, and computes the totals again. Changing a quote already sent withdraws it until it is
+// sent again.
+func (s *QuoteService) UpdateQuote(orderID string, changes QuoteChanges, adminID string) (*models.Order, error) {
+	order, err := s.getOrder(database.DB.Where("id = ?", orderID))
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.ORDER_STATUS_AWAITING_QUOTE && order.Status != models.ORDER_STATUS_QUOTED {
+		return nil, ErrQuoteState
+	}
+
+	items := make(map[uint]bool, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		items[item.ID] = true
+	}
+	for id, price := range changes.Prices {
+		if !items[id] {
+			return nil, fmt.Errorf("item %d is not part of order %d", id, order.ID)
+		}
+		if price < 0 {
+			return nil, fmt.Errorf("item %d: price can't be negative", id)
+		}
+	}
+	if changes.ShippingCost != nil && *changes.ShippingCost < 0 {
+		return nil, errors.New("shipping cost can't be negative")
+	}
+	if changes.Fees != nil && *changes.Fees < 0 {
+		return nil, errors.New("fees can't be negative")
+	}
+
+	previousStatus := order.Status
+	for i := range order.OrderItems {
+		if price, ok := changes.Prices[order.OrderItems[i].ID]; ok {
+			order.OrderItems[i].UnitPrice = price
+		}
+	}
+	if changes.ShippingCost != nil {
+		order.ShippingCost = *changes.ShippingCost
+	}
+	if changes.Fees != nil {
+		order.Fees = *changes.Fees
+	}
+	computeOrderTotals(order)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range order.OrderItems {
+			err := tx.Model(&models.OrderItem{}).Where("id = ?", item.ID).Updates(map[string]any{
+				"unit_price": item.UnitPrice,
+				"line_total": item.LineTotal,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]any{
+			"status":        models.ORDER_STATUS_AWAITING_QUOTE,
+			"quote_token":   "",
+			"quote_sent_at": nil,
+			"shipping_cost": order.ShippingCost,
+			"fees":          order.Fees,
+			"subtotal":      order.Subtotal,
+			"total":         order.Total,
+		}
+		if changes.Note != nil {
+			updates["quote_note"] = strings.TrimSpace(*changes.Note)
+		}
+		// the customer may have accepted the quote in the meantime
+		result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, previousStatus).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrQuoteState
+		}
+
+		event := adminEvent(adminID, "")
+		event.OrderID = order.ID
+		event.Type = models.ORDER_EVENT_QUOTE_UPDATED
+		event.FromStatus = previousStatus
+		event.ToStatus = models.ORDER_STATUS_AWAITING_QUOTE
+		event.Details = quoteChangeDetails(changes)
+		return recordOrderEvent(tx, &event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getOrder(database.DB.Where("id = ?", order.ID))
+}
+
+// quoteChangeDetails keeps what an admin changed in a quote for the history.
+func quoteChangeDetails(changes QuoteChanges) map[string]any {
+	details := make(map[string]any)
+	if len(changes.Prices) > 0 {
+		prices := make(map[string]float64, len(changes.Prices))
+		for id, price := range changes.Prices {
+			prices[fmt.Sprint(id)] = price
+		}
+		details["prices"] = prices
+	}
+	if changes.ShippingCost != nil {
+		details["shipping_cost"] = *changes.ShippingCost
+	}
+	if changes.Fees != nil {
+		details["fees"] = *changes.Fees
+	}
+	if changes.Note != nil {
+		details["note"] = strings.TrimSpace(*changes.Note)
+	}
+	return details
+}
+
+// SendQuote emails the quote to the customer with a link to accept it. A
+// quote can be sent again, the previous link then stops working.
+func (s *QuoteService) SendQuote(orderID string, adminID string) (*models.Order, error) {
+	order, err := s.getOrder(database.DB.Where("id = ?", orderID))
+	if err != nil {
+		return nil, err
+	}
+	if !CanTransitionOrder(order.Status, models.ORDER_STATUS_QUOTED) {
+		return nil, ErrQuoteState
+	}
+
+	for _, item := range order.OrderItems {
+		if item.UnitPrice <= 0 {
+			return nil, ErrQuoteIncomplete
+		}
+	}
+	computeOrderTotals(order)
+
+	previous := *order
+	now := time.Now()
+	order.QuoteTotal = order.Total
+	order.QuoteToken = utils.RandomToken(24)
+	order.QuoteSentAt = &now
+
+	message, err := s.quoteMessage(order)
+	if err != nil {
+		return nil, err
+	}
+
+	// the token is stored first so the emailed link always works, a quote the
+	// customer never received is then put back as it was
+	err = transitionOrder(order, models.ORDER_STATUS_QUOTED, map[string]any{
+		"subtotal":      order.Subtotal,
+		"total":         order.Total,
+		"quote_total":   order.QuoteTotal,
+		"quote_token":   order.QuoteToken,
+		"quote_sent_at": order.QuoteSentAt,
+	}, adminEvent(adminID, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mail.Send(message); err != nil {
+		revert := map[string]any{
+			"quote_total":   previous.QuoteTotal,
+			"quote_token":   previous.QuoteToken,
+			"quote_sent_at": previous.QuoteSentAt,
+		}
+		event := models.OrderEvent{Comment: "Le devis n'a pas pu être envoyé : " + err.Error()}
+		if revertErr := transitionOrder(order, previous.Status, revert, event); revertErr != nil {
+			utils.Report(fmt.Sprintf("Can't put back order %d after its quote failed to send: %s", order.ID, revertErr))
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetQuote returns the order of a quote link.
+func (s *QuoteService) GetQuote(token string) (*models.Order, error) {
+	if token == "" {
+		return nil, ErrQuoteNotFound
+	}
+
+	order, err := s.getOrder(database.DB.Where("quote_token = ?", token))
+	if errors.Is(err, ErrOrderNotFound) {
+		return nil, ErrQuoteNotFound
+	}
+	return order, err
+}
+
+// AcceptQuote confirms the order of a quote link. Accepting twice is fine,
+// the customer may click the link again.
+func (s *QuoteService) AcceptQuote(token string) (*models.Order, error) {
+	order, err := s.GetQuote(token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case order.QuoteAcceptedAt != nil:
+		return order, nil
+	case order.Status != models.ORDER_STATUS_QUOTED:
+		return nil, ErrQuoteState
+	case QuoteExpired(order, time.Now()):
+		return nil, ErrQuoteExpired
+	}
+
+	now := time.Now()
+	if err := transitionOrder(order, models.ORDER_STATUS_CONFIRMED, map[string]any{"quote_accepted_at": now}, models.OrderEvent{
+		Actor: models.ORDER_ACTOR_CUSTOMER,
+	}); err != nil {
+		return nil, err
+	}
+	order.QuoteAcceptedAt = &now
+	return order, nil
+}
+
+// QuoteExpired tells if a sent quote is too old to be accepted, the exchange
+// rate it was computed at may have moved since.
+func QuoteExpired(order *models.Order, now time.Time) bool {
+	return order.QuoteSentAt == nil || now.After(order.QuoteSentAt.Add(utils.QUOTE_VALIDITY))
+}
+
+// QuoteLines describes the items, fees and delivery of an order for the
+// customer.
+func (s *QuoteService) QuoteLines(order *models.Order) []QuoteLine {
+	lines := make([]QuoteLine, 0, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		lines = append(lines, QuoteLine{
+			Title:     s.itemTitle(item),
+			Quantity:  item.Quantity,
+			UnitPrice: FormatDZD(item.UnitPrice),
+			Total:     FormatDZD(item.LineTotal),
+		})
+	}
+
+	if order.Fees > 0 {
+		lines = append(lines, QuoteLine{
+			Title:     "Frais de service",
+			Quantity:  1,
+			UnitPrice: FormatDZD(order.Fees),
+			Total:     FormatDZD(order.Fees),
+		})
+	}
+
+	if order.ShippingCost > 0 || order.DeliveryMethod != "" {
+		lines = append(lines, QuoteLine{
+			Title:     deliveryTitle(order),
+			Quantity:  1,
+			UnitPrice: FormatDZD(order.ShippingCost),
+			Total:     FormatDZD(order.ShippingCost),
+		})
+	}
+	return lines
+}
+
+func deliveryTitle(order *models.Order) string {
+	title := "Livraison"
+	switch order.DeliveryMethod {
+	case models.DELIVERY_HOME:
+		title = "Livraison à domicile"
+	case models.DELIVERY_STOP_DESK:
+		title = "Livraison en point relais"
+	}
+
+	if name := WilayaName(order.Wilaya); name != "" {
+		title += " (" + name + ")"
+	}
+	return title
+}
+
+// itemTitle reads the title kept with the item, orders placed before titles
+// were kept fall back to the cache.
+func (s *QuoteService) itemTitle(item models.OrderItem) string {
+	if item.Title != "" {
+		return item.Title
+	}
+	if item.ItemType == "subscription" {
+		return "Abonnement " + item.ItemID
+	}
+
+	book, _, err := s.FetchBook(item.Provider, item.ItemID)
+	if err != nil || book.Title == "" {
+		return "Livre " + item.ItemID
+	}
+	return book.Title
+}
+
+func (s *QuoteService) getOrder(query *gorm.DB) (*models.Order, error) {
+	var order models.Order
+	if err := query.Preload("OrderItems").First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// QuoteURL is the page where the customer reviews and accepts a quote.
+func QuoteURL(token string) string {
+	return publicURL + "/orders/quote/" + token
+}
+
+// FormatDZD formats a price the way customers read it: "1 300 DA".
+func FormatDZD(price float64) string {
+	digits := strconv.FormatFloat(price, 'f', 0, 64)
+
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 && digits[i-1] != '-' {
+			grouped.WriteByte(' ')
+		}
+		grouped.WriteRune(digit)
+	}
+	return grouped.String() + " DA"
+}
+
+type quoteMailData struct {
+	Name       string
+	OrderID    uint
+	Lines      []QuoteLine
+	Total      string
+	Note       string
+	URL        string
+	ValidUntil string
+}
+
+func (s *QuoteService) quoteMessage(order *models.Order) (mail.Message, error) {
+	data := quoteMailData{
+		Name:       order.Name,
+		OrderID:    order.ID,
+		Lines:      s.QuoteLines(order),
+		Total:      FormatDZD(order.QuoteTotal),
+		Note:       order.QuoteNote,
+		URL:        QuoteURL(order.QuoteToken),
+		ValidUntil: order.QuoteSentAt.Add(utils.QUOTE_VALIDITY).Format("02/01/2006"),
+	}
+
+	var html, text bytes.Buffer
+	if err := quoteHTMLTemplate.Execute(&html, data); err != nil {
+		return mail.Message{}, err
+	}
+	if err := quoteTextTemplate.Execute(&text, data); err != nil {
+		return mail.Message{}, err
+	}
+
+	return mail.Message{
+		To:      order.Email,
+		Subject: fmt.Sprintf("Votre devis pour la commande n°%d", order.ID),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+var quoteHTMLTemplate = htmltemplate.Must(htmltemplate.New("quote").Parse(`<p>Bonjour {{.Name}},</p>
+<p>Voici le devis de votre commande n°{{.OrderID}} :</p>
+<table cellpadding="6" style="border-collapse: collapse">
+{{- range .Lines}}
+<tr><td>{{.Title}}</td><td>{{.Quantity}} × {{.UnitPrice}}</td><td align="right">{{.Total}}</td></tr>
+{{- end}}
+<tr><td colspan="2"><strong>Total</strong></td><td align="right"><strong>{{.Total}}</strong></td></tr>
+</table>
+{{- if .Note}}
+<p>{{.Note}}</p>
+{{- end}}
+<p><a href="{{.URL}}">Accepter le devis</a></p>
+<p>Ce devis est valable jusqu'au {{.ValidUntil}}.</p>
+`))
+
+var quoteTextTemplate = texttemplate.Must(texttemplate.New("quote").Parse(`Bonjour {{.Name}},
+
+Voici le devis de votre commande n°{{.OrderID}} :
+{{range .Lines}}
+- {{.Title}} : {{.Quantity}} × {{.UnitPrice}} = {{.Total}}
+{{- end}}
+
+Total : {{.Total}}
+{{if .Note}}
+{{.Note}}
+{{end}}
+Pour accepter le devis : {{.URL}}
+Ce devis est valable jusqu'au {{.ValidUntil}}.
+`))
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"amazon/internal/database"
+	"amazon/internal/scrapers/books"
+	"amazon/mail"
+	"amazon/models"
+
+	"gorm.io/gorm"
+)
+
+func TestQuoteWorkflow(t *testing.T) {
+	useTestDatabase(t)
+
+	var sent []mail.Message
+	previous := mail.Deliver
+	mail.Deliver = func(message mail.Message) error {
+		sent = append(sent, message)
+		return nil
+	}
+	t.Cleanup(func() { mail.Deliver = previous })
+
+	currentPricingRules.rules = nil
+	t.Cleanup(func() { currentPricingRules.rules = nil })
+
+	orders := NewOrderService()
+	rate := models.ExchangeRate{Rate: 260, EffectiveAt: time.Now().Add(-time.Hour)}
+	if err := orders.Pricing.Rates.SetRate(&rate, "1"); err != nil {
+		t.Fatal(err)
+	}
+	orders.Quotes.FetchBook = func(provider, id string) (*models.Book, string, error) {
+		if id == "missing" && provider == books.PROVIDER_LIREKA {
+			return &models.Book{ID: id, Title: "No price"}, "", nil // the provider lists no price
+		}
+		return &models.Book{ID: id, Title: "Book " + id, Price: 10, Authors: []models.AuthorType{{Name: "Camus"}, {Name: "Sartre"}}}, "", nil
+	}
+
+	order := &models.Order{
+		Name: "Amine", Email: "amine@example.com", Phone: "0555", Address: "Alger",
+		OrderItems: []models.OrderItem{
+			{ItemType: "book", ItemID: "B1", Quantity: 2, UnitPrice: 1},
+			{ItemType: "book", ItemID: "missing", Quantity: 1, Provider: books.PROVIDER_LIREKA},
+		},
+	}
+	if _, err := orders.CreateOrder(order); err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != models.ORDER_STATUS_AWAITING_QUOTE {
+		t.Fatalf("status = %q", order.Status)
+	}
+
+	// 10 EUR at 260 plus the 800 DZD fee
+	priced, missing := order.OrderItems[0], order.OrderItems[1]
+	if priced.ComputedPrice != 3400 || priced.UnitPrice != 3400 || missing.UnitPrice != 0 {
+		t.Fatalf("prices = %+v, %+v", priced, missing)
+	}
+	if priced.Title != "Book B1" || priced.Author != "Camus, Sartre" || priced.Provider != "amazon" || priced.LineTotal != 6800 {
+		t.Fatalf("snapshot = %+v", priced)
+	}
+	if missing.Title != "No price" || missing.Provider != books.PROVIDER_LIREKA {
+		t.Fatalf("lireka snapshot = %+v", missing)
+	}
+	if order.Subtotal != 6800 || order.Total != 6800 {
+		t.Fatalf("totals = %g, %g", order.Subtotal, order.Total)
+	}
+
+	// the quote shows what the customer ordered, not what the cache says now
+	orders.Quotes.FetchBook = func(provider, id string) (*models.Book, string, error) {
+		return &models.Book{ID: id, Title: "Renamed", Price: 99}, "", nil
+	}
+
+	id := fmt.Sprint(order.ID)
+	quotes := orders.Quotes
+	if _, err := quotes.SendQuote(id, "1"); !errors.Is(err, ErrQuoteIncomplete) {
+		t.Fatalf("sending an unpriced quote: %v", err)
+	}
+
+	note := "Delivery in 3 weeks"
+	if _, err := quotes.UpdateQuote(id, QuoteChanges{Prices: map[uint]float64{missing.ID: 2500}, Note: &note}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := quotes.UpdateQuote(id, QuoteChanges{Prices: map[uint]float64{9999: 10}}, "1"); err == nil {
+		t.Fatal("expected an error for an item of another order")
+	}
+
+	// a quote that can't be emailed stays unsent
+	mail.Deliver = func(message mail.Message) error { return mail.ErrDisabled }
+	if _, err := quotes.SendQuote(id, "1"); !errors.Is(err, mail.ErrDisabled) {
+		t.Fatalf("sending without mail: %v", err)
+	}
+	mail.Deliver = func(message mail.Message) error {
+		sent = append(sent, message)
+		return nil
+	}
+	unsent, err := orders.GetOrderByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unsent.Status != models.ORDER_STATUS_AWAITING_QUOTE || unsent.QuoteToken != "" || unsent.QuoteSentAt != nil {
+		t.Fatalf("unsent order = %+v", unsent)
+	}
+
+	sentOrder, err := quotes.SendQuote(id, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sentOrder.QuoteTotal != 2*3400+2500 || sentOrder.Total != sentOrder.QuoteTotal || sentOrder.Status != models.ORDER_STATUS_QUOTED {
+		t.Fatalf("sent order = %+v", sentOrder)
+	}
+	if len(sent) != 1 || sent[0].To != order.Email || !strings.Contains(sent[0].Text, sentOrder.QuoteToken) {
+		t.Fatalf("mail = %+v", sent)
+	}
+	if !strings.Contains(sent[0].Text, "9 300 DA") || !strings.Contains(sent[0].HTML, "Book B1") {
+		t.Fatalf("mail content = %s", sent[0].Text)
+	}
+
+	// sending again replaces the link
+	firstToken := sentOrder.QuoteToken
+	resent, err := quotes.SendQuote(id, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := quotes.AcceptQuote(firstToken); !errors.Is(err, ErrQuoteNotFound) {
+		t.Fatalf("accepting a replaced quote: %v", err)
+	}
+
+	accepted, err := quotes.AcceptQuote(resent.QuoteToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted.Status != models.ORDER_STATUS_CONFIRMED || accepted.QuoteAcceptedAt == nil {
+		t.Fatalf("accepted order = %+v", accepted)
+	}
+	if _, err := quotes.AcceptQuote(resent.QuoteToken); err != nil {
+		t.Fatalf("accepting twice: %v", err)
+	}
+	if _, err := quotes.UpdateQuote(id, QuoteChanges{Note: &note}, "1"); !errors.Is(err, ErrQuoteState) {
+		t.Fatalf("updating a confirmed quote: %v", err)
+	}
+}
+
+func TestUpdateAcceptedQuote(t *testing.T) {
+	useTestDatabase(t)
+
+	sentAt := time.Now()
+	order := models.Order{
+		Name: "Amine", Email: "amine@example.com",
+		Status: models.ORDER_STATUS_QUOTED, QuoteToken: "token", QuoteSentAt: &sentAt,
+		OrderItems: []models.OrderItem{{ItemType: "book", ItemID: "B1", Quantity: 1, UnitPrice: 3400}},
+	}
+	if err := database.DB.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// the customer accepts once the admin's page has read the order
+	accepted := false
+	err := database.DB.Callback().Query().After("gorm:query").Register("test:accept", func(db *gorm.DB) {
+		if db.Statement.Table == "orders" && !accepted {
+			accepted = true
+			db.Session(&gorm.Session{NewDB: true}).Exec("UPDATE orders SET status = ? WHERE id = ?", models.ORDER_STATUS_CONFIRMED, order.ID)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := map[uint]float64{order.OrderItems[0].ID: 3000}
+	if _, err := NewQuoteService().UpdateQuote(fmt.Sprint(order.ID), QuoteChanges{Prices: price}, "1"); !errors.Is(err, ErrQuoteState) {
+		t.Fatalf("updating an accepted quote: %v", err)
+	}
+
+	var stored models.Order
+	database.DB.Preload("OrderItems").First(&stored, order.ID)
+	if stored.Status != models.ORDER_STATUS_CONFIRMED || stored.QuoteToken != "token" || stored.OrderItems[0].UnitPrice != 3400 {
+		t.Errorf("accepted order = %+v", stored)
+	}
+}
+
+func TestQuoteExpiry(t *testing.T) {
+	useTestDatabase(t)
+
+	sentAt := time.Now().Add(-8 * 24 * time.Hour)
+	order := models.Order{
+		Name: "Amine", Email: "amine@example.com",
+		Status: models.ORDER_STATUS_QUOTED, QuoteToken: "old", QuoteSentAt: &sentAt,
+	}
+	if err := database.DB.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewQuoteService().AcceptQuote("old"); !errors.Is(err, ErrQuoteExpired) {
+		t.Fatalf("accepting an expired quote: %v", err)
+	}
+}
+
+func TestFormatDZD(t *testing.T) {
+	cases := map[float64]string{0: "0 DA", 950: "950 DA", 1300: "1 300 DA", 1234567: "1 234 567 DA"}
+	for price, want := range cases {
+		if got := FormatDZD(price); got != want {
+			t.Errorf("FormatDZD(%g) = %q, want %q", price, got, want)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"amazon/internal/scrapers/books"
+	"amazon/internal/search"
+	"amazon/internal/utils"
+	"amazon/models"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SEARCH_PROVIDER_LOCAL  = "local"
+	SEARCH_PROVIDER_AMAZON = "amazon"
+	SEARCH_PROVIDER_LIREKA = "lireka"
+	SEARCH_PROVIDER_GOOGLE = "google"
+
+	SEARCH_STATUS_OK      = "ok"
+	SEARCH_STATUS_FAILED  = "failed"
+	SEARCH_STATUS_TIMEOUT = "timeout"
+	SEARCH_STATUS_SKIPPED = "skipped"
+
+	rankFusionK     = 60  // dampens the gap between the first ranks of a provider
+	titleMatchBoost = 1.5 // every query term is in the title
+)
+
+type searchProvider struct {
+	name      string
+	weight    float64
+	paginated bool // the others only answer the first page
+	search    func(query string, page int) ([]models.SearchHit, int, error)
+}
+
+// searchProviders are listed by preference: when several return the same book,
+// its ID and fields come from the first one, the others fill the gaps.
+var searchProviders = []searchProvider{
+	{SEARCH_PROVIDER_LOCAL, 1.2, false, searchLocal},
+	{SEARCH_PROVIDER_AMAZON, 1.0, true, searchAmazon},
+	{SEARCH_PROVIDER_LIREKA, 1.0, false, searchLireka},
+	{SEARCH_PROVIDER_GOOGLE, 0.6, false, searchGoogle},
+}
+
+type providerAnswer struct {
+	name      string
+	hits      []models.SearchHit
+	pageCount int
+	err       error
+	duration  time.Duration
+}
+
+type SearchService struct {
+	Deadline time.Duration
+}
+
+func NewSearchService() *SearchService {
+	return &SearchService{
+		Deadline: utils.SEARCH_DEADLINE,
+	}
+}
+
+// Search queries every provider in parallel and merges the answers received
+// before the deadline. The scrapers can't be cancelled, so late answers are
+// simply dropped.
+func (s *SearchService) Search(query string, page int) models.SearchResponse {
+	answers := make(chan providerAnswer, len(searchProviders))
+
+	pending := 0
+	for _, provider := range searchProviders {
+		if page > 1 && !provider.paginated {
+			continue
+		}
+
+		pending++
+		go func(provider searchProvider) {
+			startedAt := time.Now()
+			hits, pageCount, err := provider.search(query, page)
+			answers <- providerAnswer{provider.name, hits, pageCount, err, time.Since(startedAt)}
+		}(provider)
+	}
+
+	received := make(map[string]providerAnswer)
+	deadline := time.NewTimer(s.Deadline)
+	defer deadline.Stop()
+
+wait:
+	for pending > 0 {
+		select {
+		case answer := <-answers:
+			received[answer.name] = answer
+			pending--
+		case <-deadline.C:
+			break wait
+		}
+	}
+
+	response := models.SearchResponse{
+		PageCount: 1,
+		Providers: make([]models.SearchProviderStatus, 0, len(searchProviders)),
+		TimedOut:  make([]string, 0),
+	}
+
+	for _, provider := range searchProviders {
+		status := models.SearchProviderStatus{Name: provider.name}
+		answer, ok := received[provider.name]
+
+		switch {
+		case page > 1 && !provider.paginated:
+			status.Status = SEARCH_STATUS_SKIPPED
+		case !ok:
+			status.Status = SEARCH_STATUS_TIMEOUT
+			status.Duration = s.Deadline.Milliseconds()
+			response.TimedOut = append(response.TimedOut, provider.name)
+		case answer.err != nil:
+			utils.Report("Search provider " + provider.name + " failed: " + answer.err.Error())
+			status.Status = SEARCH_STATUS_FAILED
+			status.Duration = answer.duration.Milliseconds()
+		default:
+			status.Status = SEARCH_STATUS_OK
+			status.Hits = len(answer.hits)
+			status.Duration = answer.duration.Milliseconds()
+			response.PageCount = max(response.PageCount, answer.pageCount)
+		}
+
+		response.Providers = append(response.Providers, status)
+	}
+
+	response.Books = mergeSearchHits(query, received)
+	return response
+}
+
+// mergeSearchHits dedupes the hits of every provider and ranks them with
+// reciprocal rank fusion, so a book returned high by several providers comes
+// first.
+func mergeSearchHits(query string, received map[string]providerAnswer) []models.SearchHit {
+	merged := make([]*models.SearchHit, 0)
+	byKey := make(map[string]*models.SearchHit)
+
+	for _, provider := range searchProviders {
+		answer, ok := received[provider.name]
+		if !ok || answer.err != nil {
+			continue
+		}
+
+		for rank, hit := range answer.hits {
+			score := provider.weight / float64(rankFusionK+rank+1)
+			keys := searchHitKeys(hit)
+
+			var existing *models.SearchHit
+			for _, key := range keys {
+				candidate, ok := byKey[key]
+				if !ok {
+					continue
+				}
+				// the title key only dedupes across providers, a provider
+				// listing several editions of a book keeps them all
+				if strings.HasPrefix(key, "title:") && slices.Contains(candidate.Providers, provider.name) {
+					continue
+				}
+				existing = candidate
+				break
+			}
+
+			if existing == nil {
+				hit.Provider = provider.name
+				hit.Providers = []string{provider.name}
+				hit.Score = score
+				existing = &hit
+				merged = append(merged, existing)
+			} else if !slices.Contains(existing.Providers, provider.name) {
+				fillSearchHit(existing, hit)
+				existing.Providers = append(existing.Providers, provider.name)
+				existing.Score += score
+			}
+
+			for _, key := range keys {
+				if _, ok := byKey[key]; !ok {
+					byKey[key] = existing
+				}
+			}
+		}
+	}
+
+	terms := search.Analyze(query)
+	hits := make([]models.SearchHit, 0, len(merged))
+	for _, hit := range merged {
+		if len(terms) > 0 && containsAllTerms(search.Analyze(hit.Title), terms) {
+			hit.Score *= titleMatchBoost
+		}
+		hits = append(hits, *hit)
+	}
+
+	sort.SliceStable(hits, func(a, b int) bool {
+		return hits[a].Score > hits[b].Score
+	})
+
+	return hits
+}
+
+// searchHitKeys returns the identities of a hit: its ID, its ISBN-13 when the
+// ID is an ISBN, and last its normalised title and first author.
+func searchHitKeys(hit models.SearchHit) []string {
+	keys := []string{"id:" + hit.ID}
+
+	if isbn := utils.ISBN13(hit.ID); isbn != "" {
+		keys = append(keys, "isbn:"+isbn)
+	}
+
+	title := strings.Join(search.Analyze(hit.Title), " ")
+	if title != "" {
+		author := ""
+		if len(hit.Authors) > 0 {
+			author = strings.Join(search.Analyze(hit.Authors[0].Name), " ")
+		}
+		keys = append(keys, "title:"+title+"|"+author)
+	}
+
+	return keys
+}
+
+// fillSearchHit completes the empty fields of hit with those of other.
+func fillSearchHit(hit *models.SearchHit, other models.SearchHit) {
+	if hit.Cover == "" {
+		hit.Cover = other.Cover
+	}
+	if len(hit.Authors) == 0 {
+		hit.Authors = other.Authors
+	}
+	if hit.Publisher == "" {
+		hit.Publisher = other.Publisher
+	}
+	if hit.PubDate == "" {
+		hit.PubDate = other.PubDate
+	}
+	if hit.Language == "" {
+		hit.Language = other.Language
+	}
+	if hit.Pages == 0 {
+		hit.Pages = other.Pages
+	}
+	if hit.Price == 0 {
+		hit.Price = other.Price
+	}
+	if hit.Rating <= 0 {
+		hit.Rating = other.Rating
+	}
+	if hit.Description == "" {
+		hit.Description = other.Description
+	}
+}
+
+func containsAllTerms(haystack []string, terms []string) bool {
+	for _, term := range terms {
+		if !slices.Contains(haystack, term) {
+			return false
+		}
+	}
+	return true
+}
+
+func searchLocal(query string, page int) ([]models.SearchHit, int, error) {
+	hits := make([]models.SearchHit, 0)
+	for _, hit := range search.Books.Search(query, search.MAX_RESULTS) {
+		hits = append(hits, searchHitFromBook(hit.Book))
+	}
+	return hits, 1, nil
+}
+
+func searchAmazon(query string, page int) ([]models.SearchHit, int, error) {
+	thumbnails, pageCount, err := books.SearchBooks(query, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	books.FillThumbnailLanguages(*thumbnails)
+	return searchHitsFromThumbnails(*thumbnails), pageCount, nil
+}
+
+func searchLireka(query string, page int) ([]models.SearchHit, int, error) {
+	lirekaBooks, err := books.LirekaSearchBooks(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]models.SearchHit, 0, len(lirekaBooks))
+	for _, book := range lirekaBooks {
+		hits = append(hits, searchHitFromBook(book))
+	}
+	return hits, 1, nil
+}
+
+func searchGoogle(query string, page int) ([]models.SearchHit, int, error) {
+	thumbnails, _, err := books.FetchGBooks(query, search.MAX_RESULTS)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return searchHitsFromThumbnails(*thumbnails), 1, nil
+}
+
+func searchHitFromBook(book models.Book) models.SearchHit {
+	return models.SearchHit{
+		ID:          book.ID,
+		Title:       book.Title,
+		Cover:       book.Cover,
+		Authors:     book.Authors,
+		Publisher:   book.Publisher,
+		PubDate:     book.PubDate,
+		Language:    book.Language,
+		Pages:       book.Pages,
+		Price:       book.Price,
+		Rating:      book.Rating,
+		Description: book.Description,
+		IsGBook:     book.IsGBook,
+	}
+}
+
+// searchHitsFromThumbnails converts listing thumbnails, which carry no
+// price: it is read from the cached book when we have it.
+func searchHitsFromThumbnails(thumbnails []models.BookThumbnail) []models.SearchHit {
+	hits := make([]models.SearchHit, 0, len(thumbnails))
+	for _, thumbnail := range thumbnails {
+		hit := models.SearchHit{
+			ID:       thumbnail.ID,
+			Title:    thumbnail.Title,
+			Cover:    thumbnail.Cover,
+			Authors:  thumbnail.Authors,
+			Language: thumbnail.Language,
+			Rating:   thumbnail.Rating,
+			IsGBook:  thumbnail.IsGBook,
+		}
+		if !thumbnail.IsGBook {
+			if book, _, err := books.CachedBook(thumbnail.ID); err == nil {
+				hit.Price = book.Price
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits
+}
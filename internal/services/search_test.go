@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"amazon/models"
+)
+
+func TestSearchMergesProviders(t *testing.T) {
+	camus := []models.AuthorType{{Name: "Albert Camus"}}
+
+	defer func(providers []searchProvider) { searchProviders = providers }(searchProviders)
+	searchProviders = []searchProvider{
+		{"local", 1.2, false, func(string, int) ([]models.SearchHit, int, error) {
+			return []models.SearchHit{{ID: "2070360024", Title: "L'Étranger", Authors: camus}}, 1, nil
+		}},
+		{"amazon", 1.0, true, func(string, int) ([]models.SearchHit, int, error) {
+			return []models.SearchHit{
+				{ID: "2070360024", Title: "L'Étranger", Authors: camus},
+				{ID: "2070360423", Title: "La Peste", Authors: camus},
+			}, 3, nil
+		}},
+		{"lireka", 1.0, false, func(string, int) ([]models.SearchHit, int, error) {
+			// same book by ISBN-13, with the publisher the others lack
+			return []models.SearchHit{{ID: "9782070360024", Title: "L'étranger", Authors: camus, Publisher: "Gallimard"}}, 1, nil
+		}},
+		{"google", 0.6, false, func(string, int) ([]models.SearchHit, int, error) {
+			return nil, 0, errors.New("quota exceeded")
+		}},
+		{"slow", 1.0, false, func(string, int) ([]models.SearchHit, int, error) {
+			time.Sleep(time.Second)
+			return []models.SearchHit{{ID: "late"}}, 1, nil
+		}},
+	}
+
+	service := &SearchService{Deadline: 100 * time.Millisecond}
+	result := service.Search("etranger", 1)
+
+	if len(result.Books) != 2 {
+		t.Fatalf("got %d books, want 2: %+v", len(result.Books), result.Books)
+	}
+
+	first := result.Books[0]
+	if first.ID != "2070360024" || first.Provider != "local" || first.Publisher != "Gallimard" {
+		t.Errorf("first book = %+v, want the merged local L'Étranger", first)
+	}
+	if !slices.Equal(first.Providers, []string{"local", "amazon", "lireka"}) {
+		t.Errorf("providers = %v", first.Providers)
+	}
+
+	if !slices.Equal(result.TimedOut, []string{"slow"}) {
+		t.Errorf("timed out = %v, want [slow]", result.TimedOut)
+	}
+	if result.Providers[3].Status != SEARCH_STATUS_FAILED || result.PageCount != 3 {
+		t.Errorf("statuses = %+v, page count = %d", result.Providers, result.PageCount)
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/internal/search"
+	"amazon/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	SEARCH_TREND_DAY  = "day"
+	SEARCH_TREND_WEEK = "week"
+)
+
+var ErrInvalidTrendInterval = errors.New("invalid trend interval, expected day or week")
+
+// sqlite formats of the trend periods
+var trendPeriods = map[string]string{
+	SEARCH_TREND_DAY:  "%Y-%m-%d",
+	SEARCH_TREND_WEEK: "%Y-W%W",
+}
+
+type SearchLogService struct{}
+
+func NewSearchLogService() *SearchLogService {
+	return &SearchLogService{}
+}
+
+// Record stores a search of the storefront by the customer at ip with how each
+// provider answered.
+func (s *SearchLogService) Record(query string, page int, ip string, response models.SearchResponse, latency time.Duration) error {
+	log := models.SearchLog{
+		Query:           strings.TrimSpace(query),
+		NormalizedQuery: search.QueryKey(query),
+		Page:            page,
+		ResultCount:     len(response.Books),
+		LatencyMs:       latency.Milliseconds(),
+		Client:          searchClient(ip),
+	}
+
+	for _, provider := range response.Providers {
+		log.Providers = append(log.Providers, models.SearchLogProvider{
+			Provider:   provider.Name,
+			Status:     provider.Status,
+			Hits:       provider.Hits,
+			DurationMs: provider.Duration,
+		})
+	}
+
+	return database.DB.Create(&log).Error
+}
+
+// PopularQueries returns the queries that found something and were searched
+// by at least minClients customers since the given time, with how many
+// searched them, most searched first. Searching again from the same address
+// doesn't count.
+func (s *SearchLogService) PopularQueries(since time.Time, minClients int, limit int) ([]models.QueryCount, error) {
+	var queries []models.QueryCount
+	err := database.DB.Model(&models.SearchLog{}).
+		Select("normalized_query AS query, COUNT(DISTINCT client) AS count").
+		Where("created_at >= ? AND result_count > 0 AND normalized_query <> '' AND client <> ''", since).
+		Group("normalized_query").
+		Having("COUNT(DISTINCT client) >= ?", minClients).
+		Order("count DESC").
+		Limit(limit).
+		Scan(&queries).Error
+	if err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// searchClient identifies the customer searching from ip without storing the
+// address, it is keyed with APP_SECRET so the hashes can't be reversed by
+// hashing every address.
+func searchClient(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(os.Getenv("APP_SECRET")))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// TopQueries returns the most searched queries since the given time.
+func (s *SearchLogService) TopQueries(since time.Time, limit int) ([]models.QueryStats, error) {
+	return s.queryStats(since, limit, false)
+}
+
+// ZeroResultQueries returns the queries that found nothing since the given
+// time, most searched first: the books customers want and we can't find.
+func (s *SearchLogService) ZeroResultQueries(since time.Time, limit int) ([]models.QueryStats, error) {
+	return s.queryStats(since, limit, true)
+}
+
+func (s *SearchLogService) queryStats(since time.Time, limit int, zeroResults bool) ([]models.QueryStats, error) {
+	var rows []struct {
+		Query          string
+		Example        string
+		Count          int
+		AverageResults float64
+		LastSearchedAt string
+	}
+
+	// the example is the latest of the searches counted
+	latest := "SELECT latest.query FROM search_logs AS latest " +
+		"WHERE latest.normalized_query = search_logs.normalized_query AND latest.created_at >= ?"
+	if zeroResults {
+		latest += " AND latest.result_count = 0"
+	}
+	latest += " ORDER BY latest.created_at DESC, latest.id DESC LIMIT 1"
+
+	statement := database.DB.Model(&models.SearchLog{}).
+		Select("normalized_query AS query, ("+latest+") AS example, COUNT(*) AS count, "+
+			"AVG(result_count) AS average_results, MAX(created_at) AS last_searched_at", since).
+		Where("created_at >= ? AND normalized_query <> ''", since)
+	if zeroResults {
+		statement = statement.Where("result_count = 0")
+	}
+
+	err := statement.
+		Group("normalized_query").
+		Order("count DESC, last_searched_at DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]models.QueryStats, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, models.QueryStats{
+			Query:          row.Query,
+			Example:        row.Example,
+			Count:          row.Count,
+			AverageResults: row.AverageResults,
+			LastSearchedAt: parseSqliteTime(row.LastSearchedAt),
+		})
+	}
+	return stats, nil
+}
+
+// Trends counts the searches per day or week since the given time, of every
+// query or of a single one.
+func (s *SearchLogService) Trends(since time.Time, interval string, query string) ([]models.SearchTrend, error) {
+	format, ok := trendPeriods[interval]
+	if !ok {
+		return nil, ErrInvalidTrendInterval
+	}
+
+	statement := database.DB.Model(&models.SearchLog{}).
+		Select("strftime(?, created_at, 'localtime') AS period, COUNT(*) AS searches, "+
+			"COUNT(DISTINCT normalized_query) AS unique_queries, "+
+			"SUM(CASE WHEN result_count = 0 THEN 1 ELSE 0 END) AS zero_results, "+
+			"AVG(latency_ms) AS average_latency", format).
+		Where("created_at >= ?", since)
+	if query != "" {
+		statement = statement.Where("normalized_query = ?", search.QueryKey(query))
+	}
+
+	trends := make([]models.SearchTrend, 0)
+	err := statement.Group("period").Order("period").Scan(&trends).Error
+	if err != nil {
+		return nil, err
+	}
+	return trends, nil
+}
+
+// ProviderStats summarises how each provider answered since the given time.
+func (s *SearchLogService) ProviderStats(since time.Time) ([]models.ProviderStats, error) {
+	stats := make([]models.ProviderStats, 0)
+	err := database.DB.Model(&models.SearchLogProvider{}).
+		Select("search_log_providers.provider AS provider, COUNT(*) AS searches, "+
+			"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS ok, "+
+			"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS failed, "+
+			"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS timed_out, "+
+			"AVG(hits) AS average_hits, AVG(duration_ms) AS average_duration",
+			SEARCH_STATUS_OK, SEARCH_STATUS_FAILED, SEARCH_STATUS_TIMEOUT).
+		Joins("JOIN search_logs ON search_logs.id = search_log_providers.search_log_id").
+		Where("search_logs.created_at >= ? AND status <> ?", since, SEARCH_STATUS_SKIPPED).
+		Group("search_log_providers.provider").
+		Order("provider").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// parseSqliteTime reads the text sqlite returns for aggregated timestamps.
+func parseSqliteTime(value string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
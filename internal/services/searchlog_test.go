@@ -0,0 +1,126 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"amazon/internal/database"
+	"amazon/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useTestDatabase(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(database.Models...); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+}
+
+func TestSearchLogStats(t *testing.T) {
+	useTestDatabase(t)
+	service := NewSearchLogService()
+
+	response := func(books int) models.SearchResponse {
+		return models.SearchResponse{
+			Books: make([]models.SearchHit, books),
+			Providers: []models.SearchProviderStatus{
+				{Name: SEARCH_PROVIDER_LOCAL, Status: SEARCH_STATUS_OK, Hits: books, Duration: 2},
+				{Name: SEARCH_PROVIDER_AMAZON, Status: SEARCH_STATUS_TIMEOUT, Duration: 4000},
+			},
+		}
+	}
+
+	for _, search := range []struct {
+		query string
+		books int
+	}{
+		{"L'Étranger", 12}, {"l'etranger", 10}, {"camus", 8}, {"tintin au tibet en arabe", 0}, {"Tintin au Tibet en arabe", 0},
+	} {
+		if err := service.Record(search.query, 1, "203.0.113.1", response(search.books), 1500*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	since := time.Now().Add(-time.Hour)
+
+	top, err := service.TopQueries(since, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 3 || top[0].Count != 2 || top[2].Query != "camus" || top[2].LastSearchedAt.IsZero() {
+		t.Errorf("top queries = %+v", top)
+	}
+
+	zero, err := service.ZeroResultQueries(since, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zero) != 1 || zero[0].Query != "tintin au tibet en arabe" || zero[0].Example != "Tintin au Tibet en arabe" || zero[0].Count != 2 {
+		t.Errorf("zero result queries = %+v", zero)
+	}
+
+	trends, err := service.Trends(since, SEARCH_TREND_DAY, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trends) != 1 || trends[0].Searches != 5 || trends[0].UniqueQueries != 3 || trends[0].ZeroResults != 2 || trends[0].AverageLatency != 1500 {
+		t.Errorf("trends = %+v", trends)
+	}
+
+	trends, err = service.Trends(since, SEARCH_TREND_WEEK, "L’etranger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trends) != 1 || trends[0].Searches != 2 {
+		t.Errorf("trends of one query = %+v", trends)
+	}
+
+	providers, err := service.ProviderStats(since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(providers) != 2 || providers[0].Provider != SEARCH_PROVIDER_AMAZON || providers[0].TimedOut != 5 || providers[1].Ok != 5 {
+		t.Errorf("provider stats = %+v", providers)
+	}
+}
+
+func TestPopularQueries(t *testing.T) {
+	useTestDatabase(t)
+	service := NewSearchLogService()
+
+	found := models.SearchResponse{Books: make([]models.SearchHit, 3)}
+	for _, search := range []struct{ query, ip string }{
+		{"camus", "203.0.113.1"}, {"Camus", "203.0.113.2"}, {"camus", "203.0.113.3"},
+		// one customer searching again and again doesn't make a suggestion
+		{"spam", "203.0.113.9"}, {"spam", "203.0.113.9"}, {"spam", "203.0.113.9"}, {"spam", ""},
+	} {
+		if err := service.Record(search.query, 1, search.ip, found, time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	popular, err := service.PopularQueries(time.Now().Add(-time.Hour), 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(popular) != 1 || popular[0].Query != "camus" || popular[0].Count != 3 {
+		t.Errorf("popular queries = %+v", popular)
+	}
+
+	var logged models.SearchLog
+	database.DB.First(&logged)
+	if logged.Client == "" || logged.Client == "203.0.113.1" || logged.Client != searchClient("203.0.113.1") {
+		t.Errorf("client = %q", logged.Client)
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"amazon/internal/database"
+	"amazon/internal/scrapers/books"
+	"amazon/internal/utils"
+	"amazon/models"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownWilaya         = errors.New("unknown wilaya, expected a code from 1 to 58")
+	ErrUnknownDeliveryMethod = errors.New("unknown delivery method, expected home or stop_desk")
+	ErrNoShippingRate        = errors.New("no delivery to this wilaya with this method")
+	ErrShippingRateNotFound  = errors.New("shipping rate not found")
+)
+
+var DeliveryMethods = []string{models.DELIVERY_HOME, models.DELIVERY_STOP_DESK}
+
+// wilayaNames are the 58 provinces, in the order of their codes.
+var wilayaNames = [...]string{
+	"Adrar", "Chlef", "Laghouat", "Oum El Bouaghi", "Batna",
+	"Béjaïa", "Biskra", "Béchar", "Blida", "Bouira",
+	"Tamanrasset", "Tébessa", "Tlemcen", "Tiaret", "Tizi Ouzou",
+	"Alger", "Djelfa", "Jijel", "Sétif", "Saïda",
+	"Skikda", "Sidi Bel Abbès", "Annaba", "Guelma", "Constantine",
+	"Médéa", "Mostaganem", "M'Sila", "Mascara", "Ouargla",
+	"Oran", "El Bayadh", "Illizi", "Bordj Bou Arréridj", "Boumerdès",
+	"El Tarf", "Tindouf", "Tissemsilt", "El Oued", "Khenchela",
+	"Souk Ahras", "Tipaza", "Mila", "Aïn Defla", "Naâma",
+	"Aïn Témouchent", "Ghardaïa", "Relizane", "Timimoun", "Bordj Badji Mokhtar",
+	"Ouled Djellal", "Béni Abbès", "In Salah", "In Guezzam", "Touggourt",
+	"Djanet", "El M'Ghair", "El Meniaa",
+}
+
+// Wilayas lists the provinces with their codes.
+func Wilayas() []models.Wilaya {
+	wilayas := make([]models.Wilaya, 0, len(wilayaNames))
+	for i, name := range wilayaNames {
+		wilayas = append(wilayas, models.Wilaya{Code: i + 1, Name: name})
+	}
+	return wilayas
+}
+
+func WilayaName(code int) string {
+	if code < 1 || code > len(wilayaNames) {
+		return ""
+	}
+	return wilayaNames[code-1]
+}
+
+// ValidateDelivery checks the destination of an order.
+func ValidateDelivery(wilaya int, method string) error {
+	if WilayaName(wilaya) == "" {
+		return ErrUnknownWilaya
+	}
+	if !slices.Contains(DeliveryMethods, method) {
+		return ErrUnknownDeliveryMethod
+	}
+	return nil
+}
+
+type ShippingService struct {
+	// CachedBook reads the books to weigh from the cache, quotes are public
+	// and must not scrape
+	CachedBook func(id string) (*models.Book, string, error)
+}
+
+func NewShippingService() *ShippingService {
+	return &ShippingService{
+		CachedBook: books.CachedBook,
+	}
+}
+
+// Rates returns every rate, the defaults of wilaya 0 first.
+func (s *ShippingService) Rates() ([]models.ShippingRate, error) {
+	rates := make([]models.ShippingRate, 0)
+	if err := database.DB.Order("wilaya, method").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// SaveRates creates or replaces the rates of the given wilayas and methods.
+func (s *ShippingService) SaveRates(rates []models.ShippingRate, adminID string) error {
+	for i := range rates {
+		if err := validateShippingRate(&rates[i]); err != nil {
+			return fmt.Errorf("rate %d: %w", i+1, err)
+		}
+		rates[i].ID = 0
+		rates[i].UpdatedBy = adminID
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range rates {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "wilaya"}, {Name: "method"}},
+				DoUpdates: clause.AssignmentColumns([]string{"updated_at", "updated_by", "price", "included_weight", "extra_kg_price", "delivery_days", "disabled"}),
+			}).Create(&rates[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *ShippingService) DeleteRate(id string) error {
+	result := database.DB.Delete(&models.ShippingRate{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrShippingRateNotFound
+	}
+	return nil
+}
+
+// RateFor returns the rate of a wilaya, or the default rate of the method.
+func (s *ShippingService) RateFor(wilaya int, method string) (*models.ShippingRate, error) {
+	var rate models.ShippingRate
+	err := database.DB.
+		Where("wilaya IN ? AND method = ?", []int{0, wilaya}, method).
+		Order("wilaya DESC").
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoShippingRate
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rate.Disabled {
+		return nil, ErrNoShippingRate
+	}
+	return &rate, nil
+}
+
+// Quote computes the delivery of items to a wilaya with a method.
+func (s *ShippingService) Quote(wilaya int, method string, items []models.OrderItem) (*models.ShippingQuote, error) {
+	if err := ValidateDelivery(wilaya, method); err != nil {
+		return nil, err
+	}
+
+	rate, err := s.RateFor(wilaya, method)
+	if err != nil {
+		return nil, err
+	}
+
+	weight := s.ItemsWeight(items)
+	quote := &models.ShippingQuote{
+		Wilaya:       wilaya,
+		WilayaName:   WilayaName(wilaya),
+		Method:       method,
+		Weight:       math.Round(weight*1000) / 1000,
+		BasePrice:    rate.Price,
+		DeliveryDays: rate.DeliveryDays,
+	}
+
+	if extra := weight - rate.IncludedWeight; extra > 0 {
+		quote.Surcharge = math.Ceil(extra-1e-9) * rate.ExtraKgPrice
+	}
+	quote.Price = quote.BasePrice + quote.Surcharge
+
+	return quote, nil
+}
+
+// QuoteAll computes the delivery of items with every method serving the
+// wilaya.
+func (s *ShippingService) QuoteAll(wilaya int, items []models.OrderItem) ([]models.ShippingQuote, error) {
+	quotes := make([]models.ShippingQuote, 0, len(DeliveryMethods))
+	for _, method := range DeliveryMethods {
+		quote, err := s.Quote(wilaya, method, items)
+		if errors.Is(err, ErrNoShippingRate) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, *quote)
+	}
+	return quotes, nil
+}
+
+// ItemsWeight returns the weight of the books among items in kg. Books that
+// aren't cached weigh DEFAULT_BOOK_WEIGHT, subscriptions nothing.
+func (s *ShippingService) ItemsWeight(items []models.OrderItem) float64 {
+	total := 0.0
+	for _, item := range items {
+		if item.ItemType != "book" {
+			continue
+		}
+
+		weight := utils.DEFAULT_BOOK_WEIGHT
+		if book, _, err := s.CachedBook(item.ItemID); err == nil {
+			weight = BookWeight(*book)
+		}
+		total += weight * float64(item.Quantity)
+	}
+	return total
+}
+
+// BookWeight returns the weight a carrier charges for a book in kg: its
+// weight, estimated from its size or pages when unknown, or its volumetric
+// weight when larger.
+func BookWeight(book models.Book) float64 {
+	size := book.Dimension
+	volume := size.Width * size.Depth * size.Height // cm³
+
+	weight := size.Weight / 1000
+	if weight <= 0 && volume > 0 {
+		weight = volume * utils.BOOK_DENSITY / 1000
+	}
+	if weight <= 0 && book.Pages > 0 {
+		weight = float64(book.Pages) * utils.BOOK_WEIGHT_PER_PAGE / 1000
+	}
+	if weight <= 0 {
+		weight = utils.DEFAULT_BOOK_WEIGHT
+	}
+
+	return math.Max(weight, volume/utils.VOLUMETRIC_DIVISOR)
+}
+
+func validateShippingRate(rate *models.ShippingRate) error {
+	if rate.Wilaya != 0 && WilayaName(rate.Wilaya) == "" {
+		return errors.New("unknown wilaya, expected a code from 1 to 58 or 0 for the default rates")
+	}
+	if !slices.Contains(DeliveryMethods, rate.Method) {
+		return ErrUnknownDeliveryMethod
+	}
+	if rate.Price < 0 || rate.IncludedWeight < 0 || rate.ExtraKgPrice < 0 || rate.DeliveryDays < 0 {
+		return errors.New("price, included_weight, extra_kg_price and delivery_days can't be negative")
+	}
+	return nil
+}
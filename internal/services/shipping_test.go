@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"amazon/models"
+)
+
+func TestBookWeight(t *testing.T) {
+	cases := []struct {
+		name string
+		book models.Book
+		want float64
+	}{
+		{"weighed", models.Book{Dimension: models.Dimension{Weight: 340, Width: 14, Depth: 2, Height: 21}}, 0.34},
+		{"from dimensions", models.Book{Dimension: models.Dimension{Width: 14, Depth: 2, Height: 21}}, 0.3528},
+		{"from pages", models.Book{Pages: 300}, 0.45},
+		{"unknown", models.Book{}, 0.4},
+		// a light but bulky box is charged by volume
+		{"volumetric", models.Book{Dimension: models.Dimension{Weight: 500, Width: 30, Depth: 10, Height: 40}}, 2.4},
+	}
+
+	for _, tc := range cases {
+		if got := BookWeight(tc.book); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: BookWeight = %g, want %g", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestShippingQuote(t *testing.T) {
+	useTestDatabase(t)
+
+	service := NewShippingService()
+	service.CachedBook = func(id string) (*models.Book, string, error) {
+		if id == "heavy" {
+			return &models.Book{ID: id, Dimension: models.Dimension{Weight: 1300}}, "", nil
+		}
+		return nil, "not_found", errors.New("book not found")
+	}
+
+	err := service.SaveRates([]models.ShippingRate{
+		{Method: models.DELIVERY_HOME, Price: 800, IncludedWeight: 1, ExtraKgPrice: 100},
+		{Method: models.DELIVERY_STOP_DESK, Price: 500, IncludedWeight: 1, ExtraKgPrice: 100},
+		{Wilaya: 16, Method: models.DELIVERY_HOME, Price: 400, IncludedWeight: 2, ExtraKgPrice: 50},
+		{Wilaya: 33, Method: models.DELIVERY_STOP_DESK, Disabled: true},
+	}, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := []models.OrderItem{
+		{ItemType: "book", ItemID: "heavy", Quantity: 2},      // 2.6 kg
+		{ItemType: "book", ItemID: "unknown", Quantity: 1},    // 0.4 kg by default
+		{ItemType: "subscription", ItemID: "S1", Quantity: 1}, // not shipped
+	}
+
+	quote, err := service.Quote(16, models.DELIVERY_HOME, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Weight != 3 || quote.BasePrice != 400 || quote.Surcharge != 50 || quote.Price != 450 || quote.WilayaName != "Alger" {
+		t.Fatalf("Alger quote = %+v", quote)
+	}
+
+	// Oran has no rate of its own, 2 started kg above the included one
+	quote, err = service.Quote(31, models.DELIVERY_HOME, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Price != 1000 {
+		t.Fatalf("Oran quote = %+v", quote)
+	}
+
+	quotes, err := service.QuoteAll(33, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 || quotes[0].Method != models.DELIVERY_HOME {
+		t.Fatalf("Illizi quotes = %+v", quotes)
+	}
+	if _, err := service.Quote(33, models.DELIVERY_STOP_DESK, items); !errors.Is(err, ErrNoShippingRate) {
+		t.Fatalf("disabled method: %v", err)
+	}
+
+	if _, err := service.Quote(59, models.DELIVERY_HOME, items); !errors.Is(err, ErrUnknownWilaya) {
+		t.Fatalf("unknown wilaya: %v", err)
+	}
+	if _, err := service.Quote(16, "drone", items); !errors.Is(err, ErrUnknownDeliveryMethod) {
+		t.Fatalf("unknown method: %v", err)
+	}
+
+	// saving a rate again replaces it
+	err = service.SaveRates([]models.ShippingRate{{Wilaya: 16, Method: models.DELIVERY_HOME, Price: 300, IncludedWeight: 5}}, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates, _ := service.Rates()
+	if len(rates) != 4 {
+		t.Fatalf("rates = %+v", rates)
+	}
+	if quote, _ := service.Quote(16, models.DELIVERY_HOME, items); quote.Price != 300 {
+		t.Fatalf("Alger quote after update = %+v", quote)
+	}
+}
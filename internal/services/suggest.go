@@ -0,0 +1,102 @@
+package services
+
+import (
+	"amazon/internal/search"
+	"amazon/internal/utils"
+	"amazon/models"
+	"sync"
+	"time"
+)
+
+const (
+	popularSearchMinClients = 3 // customers, one alone can't publish a suggestion
+	popularSearchLimit      = 2000
+)
+
+// popularSearches completes prefixes with what customers searched before. It
+// is rebuilt in the background so a keystroke never waits for the database.
+var popularSearches struct {
+	sync.Mutex
+	suggester  *search.Suggester
+	builtAt    time.Time
+	refreshing bool
+}
+
+type SuggestService struct {
+	Logs *SearchLogService
+}
+
+func NewSuggestService() *SuggestService {
+	return &SuggestService{
+		Logs: NewSearchLogService(),
+	}
+}
+
+// Suggest completes prefix from popular searches and from the titles,
+// authors and series of the local index, without calling any provider. Up to
+// half of the suggestions are popular searches.
+func (s *SuggestService) Suggest(prefix string, limit int) []models.SearchSuggestion {
+	popular := s.popularSuggester().Suggest(prefix, limit)
+	local := search.Books.Suggest(prefix, limit)
+
+	suggestions := make([]models.SearchSuggestion, 0, limit)
+	seen := make(map[string]bool)
+	add := func(candidates []models.SearchSuggestion, max int) {
+		for _, suggestion := range candidates {
+			if len(suggestions) >= max {
+				return
+			}
+
+			key := search.QueryKey(suggestion.Text)
+			if !seen[key] {
+				seen[key] = true
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+	}
+
+	add(popular, (limit+1)/2)
+	add(local, limit)
+	add(popular, limit)
+
+	return suggestions
+}
+
+// popularSuggester returns the current popular searches, refreshing them in
+// the background when they are stale.
+func (s *SuggestService) popularSuggester() *search.Suggester {
+	popularSearches.Lock()
+	defer popularSearches.Unlock()
+
+	if time.Since(popularSearches.builtAt) > utils.POPULAR_SEARCH_REFRESH && !popularSearches.refreshing {
+		popularSearches.refreshing = true
+		go s.refreshPopularSearches()
+	}
+
+	return popularSearches.suggester
+}
+
+func (s *SuggestService) refreshPopularSearches() {
+	queries, err := s.Logs.PopularQueries(time.Now().Add(-utils.POPULAR_SEARCH_WINDOW), popularSearchMinClients, popularSearchLimit)
+
+	popularSearches.Lock()
+	defer popularSearches.Unlock()
+	popularSearches.refreshing = false
+	popularSearches.builtAt = time.Now() // failures wait for the next refresh too
+
+	if err != nil {
+		utils.Report("Can't load the popular searches: " + err.Error())
+		return
+	}
+
+	entries := make([]models.SearchSuggestion, 0, len(queries))
+	for _, query := range queries {
+		entries = append(entries, models.SearchSuggestion{
+			Text:   query.Query,
+			Kind:   search.SUGGESTION_QUERY,
+			Weight: float64(query.Count),
+		})
+	}
+
+	popularSearches.suggester = search.NewSuggester(entries)
+}
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ARCHIVE_DEFAULT_RETENTION   = 7 * 24 * time.Hour
+	ARCHIVE_DEFAULT_MAX_ENTRIES = 2000
+	ARCHIVE_PRUNE_INTERVAL      = time.Hour
+
+	archiveTimeLayout = "20060102T150405.000000000"
+)
+
+var ErrArchiveDisabled = errors.New("response archive is disabled")
+
+// ArchiveEntry describes one archived upstream response. The body is stored
+// next to it as <id>.html.
+type ArchiveEntry struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Status    int       `json:"status"`
+	Size      int       `json:"size"`
+	Error     string    `json:"error,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// archiveSettings is where and how long responses are archived. Callers copy
+// it under the lock and touch the files without holding it.
+type archiveSettings struct {
+	dir        string
+	retention  time.Duration
+	maxEntries int
+}
+
+type responseArchive struct {
+	mu          sync.Mutex
+	settings    archiveSettings
+	stopPruning chan struct{} // stops the pruner of the current directory
+}
+
+var archive = &responseArchive{}
+
+// ConfigureArchive enables the raw-response archive under dir, pruned now and
+// then every ARCHIVE_PRUNE_INTERVAL. An empty dir keeps it disabled, which is
+// the default.
+func ConfigureArchive(dir string, retention time.Duration, maxEntries int) error {
+	if retention <= 0 {
+		retention = ARCHIVE_DEFAULT_RETENTION
+	}
+	if maxEntries <= 0 {
+		maxEntries = ARCHIVE_DEFAULT_MAX_ENTRIES
+	}
+
+	if dir == "" {
+		archive.configure(archiveSettings{})
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		archive.configure(archiveSettings{})
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	settings := archiveSettings{dir: dir, retention: retention, maxEntries: maxEntries}
+	archive.configure(settings)
+	return settings.prune()
+}
+
+// configure replaces the settings and the pruner of the previous directory.
+func (a *responseArchive) configure(settings archiveSettings) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.stopPruning != nil {
+		close(a.stopPruning)
+		a.stopPruning = nil
+	}
+
+	a.settings = settings
+	if settings.dir != "" {
+		a.stopPruning = make(chan struct{})
+		go settings.pruneEvery(ARCHIVE_PRUNE_INTERVAL, a.stopPruning)
+	}
+}
+
+func ArchiveEnabled() bool {
+	return archive.current().dir != ""
+}
+
+// current returns a copy of the settings, empty while the archive is disabled.
+func (a *responseArchive) current() archiveSettings {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.settings
+}
+
+// Archived wraps a fetcher so every response it returns is archived.
+func Archived(fetch FetchFunc) FetchFunc {
+	return func(targetURL string) (string, int, error, bool) {
+		content, status, err, isDirect := fetch(targetURL)
+
+		if archiveErr := ArchiveResponse(targetURL, status, content, err); archiveErr != nil {
+			Report("Failed to archive response: " + archiveErr.Error())
+		}
+
+		return content, status, err, isDirect
+	}
+}
+
+// ArchiveResponse stores a raw response keyed by URL and timestamp. It is a
+// no-op while the archive is disabled.
+func ArchiveResponse(targetURL string, status int, content string, fetchErr error) error {
+	settings := archive.current()
+	if settings.dir == "" {
+		return nil
+	}
+
+	now := time.Now()
+	entry := ArchiveEntry{
+		ID:        now.UTC().Format(archiveTimeLayout) + "_" + URLFileName(targetURL),
+		URL:       targetURL,
+		Status:    status,
+		Size:      len(content),
+		FetchedAt: now,
+	}
+	if fetchErr != nil {
+		entry.Error = fetchErr.Error()
+	}
+
+	// the body goes first, entries are listed from their metadata
+	if err := WriteFile(settings.path(entry.ID, ".html"), content); err != nil {
+		return err
+	}
+
+	meta, err := ToJson(entry)
+	if err != nil {
+		return err
+	}
+	return WriteFile(settings.path(entry.ID, ".json"), meta)
+}
+
+// ListArchive returns archived entries, newest first, optionally keeping only
+// those whose URL contains urlFilter.
+func ListArchive(urlFilter string, limit int) ([]ArchiveEntry, error) {
+	settings := archive.current()
+	if settings.dir == "" {
+		return nil, ErrArchiveDisabled
+	}
+
+	entries, err := settings.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ArchiveEntry, 0)
+	for _, entry := range entries {
+		if urlFilter != "" && !strings.Contains(entry.URL, urlFilter) {
+			continue
+		}
+		result = append(result, entry)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// ReadArchive returns an archived entry and its raw body.
+func ReadArchive(id string) (*ArchiveEntry, string, error) {
+	settings := archive.current()
+	if settings.dir == "" {
+		return nil, "", ErrArchiveDisabled
+	}
+
+	// ids are file names produced by ArchiveResponse, never paths
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, "", os.ErrNotExist
+	}
+
+	meta, err := os.ReadFile(settings.path(id, ".json"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var entry ArchiveEntry
+	if err := ParseJson(string(meta), &entry); err != nil {
+		return nil, "", err
+	}
+
+	content, err := os.ReadFile(settings.path(id, ".html"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &entry, string(content), nil
+}
+
+func (s archiveSettings) path(id string, extension string) string {
+	return filepath.Join(s.dir, id+extension)
+}
+
+// entries reads every entry's metadata, newest first.
+func (s archiveSettings) entries() ([]ArchiveEntry, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ArchiveEntry, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		var entry ArchiveEntry
+		if err := ParseJson(string(content), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID > entries[j].ID
+	})
+
+	return entries, nil
+}
+
+// prune applies the retention policy: entries older than the retention period
+// are removed, then the oldest ones beyond maxEntries.
+func (s archiveSettings) prune() error {
+	entries, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		if i < s.maxEntries && time.Since(entry.FetchedAt) < s.retention {
+			continue
+		}
+
+		os.Remove(s.path(entry.ID, ".html"))
+		os.Remove(s.path(entry.ID, ".json"))
+	}
+
+	return nil
+}
+
+// pruneEvery prunes the archive on every tick until stop is closed.
+func (s archiveSettings) pruneEvery(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.prune(); err != nil {
+				Report("Failed to prune the response archive: " + err.Error())
+			}
+		}
+	}
+}
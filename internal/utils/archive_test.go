@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func useArchive(t *testing.T, retention time.Duration, maxEntries int) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "archive")
+	if err := ConfigureArchive(dir, retention, maxEntries); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ConfigureArchive("", 0, 0) })
+	return dir
+}
+
+func TestArchiveResponse(t *testing.T) {
+	useArchive(t, 0, 0)
+
+	fetch := Archived(func(targetURL string) (string, int, error, bool) {
+		if targetURL == "https://www.amazon.fr/dp/missing" {
+			return "", 404, errors.New("not found"), false
+		}
+		return "<html>" + targetURL + "</html>", 200, nil, false
+	})
+
+	// responses are archived concurrently
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetch(fmt.Sprintf("https://www.amazon.fr/dp/B%02d", i))
+		}()
+	}
+	wg.Wait()
+	fetch("https://www.amazon.fr/dp/missing")
+
+	entries, err := ListArchive("", 0)
+	if err != nil || len(entries) != 11 {
+		t.Fatalf("%d entries: %v", len(entries), err)
+	}
+	if entries[0].URL != "https://www.amazon.fr/dp/missing" || entries[0].Status != 404 || entries[0].Error != "not found" {
+		t.Errorf("newest entry = %+v", entries[0])
+	}
+
+	filtered, _ := ListArchive("/dp/B0", 3)
+	if len(filtered) != 3 {
+		t.Errorf("%d filtered entries", len(filtered))
+	}
+
+	entry, content, err := ReadArchive(filtered[0].ID)
+	if err != nil || entry.URL != filtered[0].URL || content != "<html>"+entry.URL+"</html>" || entry.Size != len(content) {
+		t.Errorf("read %+v, %q, %v", entry, content, err)
+	}
+
+	for _, id := range []string{"", "../archive", ".hidden"} {
+		if _, _, err := ReadArchive(id); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("ReadArchive(%q): %v", id, err)
+		}
+	}
+}
+
+func TestPruneArchive(t *testing.T) {
+	dir := useArchive(t, time.Hour, 2)
+
+	for _, url := range []string{"https://a.example", "https://b.example", "https://c.example"} {
+		if err := ArchiveResponse(url, 200, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an entry past the retention period
+	old := ArchiveEntry{ID: "20000101T000000.000000000_old", URL: "https://old.example", FetchedAt: time.Now().Add(-2 * time.Hour)}
+	meta, _ := ToJson(old)
+	WriteFile(filepath.Join(dir, old.ID+".json"), meta)
+	WriteFile(filepath.Join(dir, old.ID+".html"), "")
+
+	if err := archive.current().prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := ListArchive("", 0)
+	if len(entries) != 2 || entries[0].URL != "https://c.example" || entries[1].URL != "https://b.example" {
+		t.Errorf("entries after pruning = %+v", entries)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 4 {
+		t.Errorf("%d files left", len(files))
+	}
+}
+
+func TestDisabledArchive(t *testing.T) {
+	useArchive(t, 0, 0)
+	if err := ConfigureArchive("", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if ArchiveEnabled() || archive.stopPruning != nil {
+		t.Error("archive still enabled")
+	}
+	if err := ArchiveResponse("https://a.example", 200, "", nil); err != nil {
+		t.Errorf("archiving while disabled: %v", err)
+	}
+	if _, err := ListArchive("", 0); !errors.Is(err, ErrArchiveDisabled) {
+		t.Errorf("listing a disabled archive: %v", err)
+	}
+	if _, _, err := ReadArchive("20250101T000000.000000000_a"); !errors.Is(err, ErrArchiveDisabled) {
+		t.Errorf("reading a disabled archive: %v", err)
+	}
+}
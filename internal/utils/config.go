@@ -8,11 +8,34 @@ const (
 	AMAZON_URL       = "https://www.amazon.fr"
 	AMAZON_MEDIA_URL = "https://m.media-amazon.com"
 
-	CACHE_DIRECTORY       = "books_cache"
-	BOOKS_CACHE_DIRECTORY = "books_cache/books"
-	CACHE_DURATION        = 5 * 12 * 30 * 24 * time.Hour // 5 Years
-	MAX_FILE_SIZE         = 25 * 1024 * 1024   // 25 MB
-	TOKEN_EXPIRY          = 3 * 24 * time.Hour // Duration for which the token is valid
+	CACHE_DIRECTORY        = "books_cache"
+	BOOKS_CACHE_DIRECTORY  = "books_cache/books"
+	COVERS_CACHE_DIRECTORY = "covers_cache"
+	COVER_CACHE_MAX_AGE    = 30 * 24 * time.Hour
+	SEARCH_INDEX_REFRESH   = 10 * time.Minute    // picks up books cached by other processes
+	SEARCH_DEADLINE        = 4 * time.Second     // providers answering later are reported as timed out
+	POPULAR_SEARCH_WINDOW  = 90 * 24 * time.Hour // searches older than this no longer make suggestions
+	POPULAR_SEARCH_REFRESH = 10 * time.Minute
+	LANGUAGE_LISTING_PAGES = 10                           // bestseller pages read to page the books of one language
+	CACHE_DURATION         = 5 * 12 * 30 * 24 * time.Hour // 5 Years
+	MAX_FILE_SIZE          = 25 * 1024 * 1024             // 25 MB
+	TOKEN_EXPIRY           = 3 * 24 * time.Hour           // Duration for which the token is valid
+	QUOTE_VALIDITY         = 7 * 24 * time.Hour           // quotes follow the exchange rate of the day they are sent
+	DUPLICATE_ORDER_WINDOW = 24 * time.Hour               // same customer and items within this is a probable duplicate
+
+	PRICE_OBSERVATION_INTERVAL = time.Hour      // an unchanged price is recorded again after this
+	PRICE_REFRESH_INTERVAL     = 12 * time.Hour // books of quoted orders are scraped again this often
+	PRICE_REFRESH_DELAY        = time.Second    // between two books, to go easy on the provider
+
+	DEFAULT_BOOK_WEIGHT  = 0.4  // kg, for books nothing is known about
+	BOOK_DENSITY         = 0.6  // g/cm³, estimates a weight from the dimensions
+	BOOK_WEIGHT_PER_PAGE = 1.5  // g, estimates a weight from the page count
+	VOLUMETRIC_DIVISOR   = 5000 // cm³ per kg, carriers charge bulky parcels by volume
+	MAX_QUOTED_ITEMS     = 100  // lines of a cart a shipping quote weighs
+	MAX_ORDER_ITEMS      = 50   // lines of an order, each book is looked up at its provider
+
+	DEFAULT_CURRENCY      = "EUR" // providers list their prices in euros
+	DEFAULT_EXCHANGE_RATE = 260   // DZD for 1 EUR, seeds the exchange rate table
 
 	IS_DEVELOPMENT = true
 )
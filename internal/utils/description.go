@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Descriptions are stored as sanitised Markdown and rendered on the way out.
+const (
+	DESCRIPTION_FORMAT_MARKDOWN = "markdown"
+	DESCRIPTION_FORMAT_HTML     = "html"
+	DESCRIPTION_FORMAT_TEXT     = "text"
+
+	SHORT_DESCRIPTION_LENGTH = 200
+)
+
+var (
+	// any tag or comment that isn't escaped, "\<" shows a "<" in Markdown
+	htmlTagPattern    = regexp.MustCompile(`(?i)(^|[^\\])<(/?[a-z][a-z0-9-]*(?:[\s/>]|$)|!--)`)
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+
+	// markdown renders without raw HTML and drops unsafe link schemes
+	markdown = goldmark.New()
+)
+
+func IsDescriptionFormat(format string) bool {
+	switch format {
+	case DESCRIPTION_FORMAT_MARKDOWN, DESCRIPTION_FORMAT_HTML, DESCRIPTION_FORMAT_TEXT:
+		return true
+	}
+	return false
+}
+
+// SanitizeDescription turns a provider description, raw HTML or plain text,
+// into the Markdown form we store. Any tag sends the description through the
+// HTML sanitizer, and the tags it leaves are escaped, so the Markdown served
+// never carries raw HTML. Markdown input is returned normalised, so the
+// function is safe to apply twice.
+func SanitizeDescription(description string) string {
+	description = strings.TrimSpace(description)
+
+	if htmlTagPattern.MatchString(description) {
+		if converted, err := htmlToMarkdown(description); err == nil {
+			description = converted
+		} else {
+			Report("Can't convert the description into markdown: " + err.Error())
+		}
+		// matches can't overlap, adjacent tags take more than one pass
+		for htmlTagPattern.MatchString(description) {
+			description = htmlTagPattern.ReplaceAllString(description, `$1\<$2`)
+		}
+	}
+
+	description = strings.ReplaceAll(description, "\r\n", "\n")
+
+	lines := strings.Split(description, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	description = strings.Join(lines, "\n")
+
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(description, "\n\n"))
+}
+
+func htmlToMarkdown(html string) (string, error) {
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return "", err
+	}
+
+	document.Find("script, style, link, iframe, object, embed, form, noscript").Remove()
+
+	// keep the text of javascript links, drop the link itself
+	document.Find("a[href^='javascript:'], a[href^='JavaScript:']").Each(func(i int, s *goquery.Selection) {
+		s.ReplaceWithHtml(s.Text())
+	})
+
+	body, err := document.Find("body").Html()
+	if err != nil {
+		return "", err
+	}
+
+	return htmltomarkdown.ConvertString(body)
+}
+
+// RenderDescription renders a stored Markdown description in the given format.
+func RenderDescription(description string, format string) string {
+	switch format {
+	case DESCRIPTION_FORMAT_HTML:
+		var buffer bytes.Buffer
+		if err := markdown.Convert([]byte(description), &buffer); err != nil {
+			Report("Can't render the description as html: " + err.Error())
+			return ""
+		}
+		return strings.TrimSpace(buffer.String())
+
+	case DESCRIPTION_FORMAT_TEXT:
+		return markdownToText(description)
+	}
+
+	return description
+}
+
+// ShortDescription returns the plain text description cut at a word boundary
+// to at most maxLength characters, for listing cards.
+func ShortDescription(description string, maxLength int) string {
+	plain := strings.Join(strings.Fields(markdownToText(description)), " ")
+
+	if utf8.RuneCountInString(plain) <= maxLength {
+		return plain
+	}
+
+	runes := []rune(plain)[:maxLength]
+	short := string(runes)
+	if cut := strings.LastIndex(short, " "); cut > maxLength/2 {
+		short = short[:cut]
+	}
+
+	return strings.TrimRight(short, " ,;:.-") + "…"
+}
+
+// markdownToText keeps the text of a Markdown document, one paragraph per line.
+func markdownToText(description string) string {
+	source := []byte(description)
+	document := markdown.Parser().Parse(text.NewReader(source))
+
+	var builder strings.Builder
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := node.(type) {
+		case *ast.Text:
+			if entering {
+				builder.Write(n.Segment.Value(source))
+				if n.SoftLineBreak() || n.HardLineBreak() {
+					builder.WriteString("\n")
+				}
+			}
+		case *ast.String:
+			if entering {
+				builder.Write(n.Value)
+			}
+		case *ast.CodeSpan:
+			if entering {
+				for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+					if t, ok := child.(*ast.Text); ok {
+						builder.Write(t.Segment.Value(source))
+					}
+				}
+				return ast.WalkSkipChildren, nil
+			}
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			if entering {
+				lines := n.Lines()
+				for i := 0; i < lines.Len(); i++ {
+					segment := lines.At(i)
+					builder.Write(segment.Value(source))
+				}
+				return ast.WalkSkipChildren, nil
+			}
+		case *ast.Paragraph, *ast.Heading, *ast.ListItem, *ast.ThematicBreak:
+			if !entering {
+				builder.WriteString("\n")
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(builder.String(), "\n\n"))
+}
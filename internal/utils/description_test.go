@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeDescription(t *testing.T) {
+	cases := []struct {
+		name        string
+		description string
+		want        string
+	}{
+		{"plain text", "  Un roman.\r\n\r\n\r\n\r\nDeux paragraphes.  ", "Un roman.\n\nDeux paragraphes."},
+		{"markdown", "Un **roman** culte.\n\n- premier\n- second", "Un **roman** culte.\n\n- premier\n- second"},
+		{"html", "<p>Un <b>roman</b> culte.</p><p>Deux paragraphes.</p>", "Un **roman** culte.\n\nDeux paragraphes."},
+		{"script", "<script>alert(1)</script>Un roman.", "Un roman."},
+		{"only a script", "<script>alert(1)</script>", ""},
+		{"iframe", `<iframe src="https://evil.example"></iframe>`, ""},
+		{"javascript link", `<a href="javascript:alert(1)">Lire</a>`, "Lire"},
+		{"comparison", "Prix < 10 €", "Prix < 10 €"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := SanitizeDescription(c.description)
+			if got != c.want {
+				t.Errorf("SanitizeDescription(%q) = %q, want %q", c.description, got, c.want)
+			}
+			if again := SanitizeDescription(got); again != got {
+				t.Errorf("sanitizing twice gives %q", again)
+			}
+		})
+	}
+}
+
+func TestSanitizeDescriptionLeavesNoRawHTML(t *testing.T) {
+	for _, description := range []string{
+		`<img src=x onerror=alert(1)>`,
+		`Un roman <img src=x onerror=alert(1)> culte`,
+		`<svg onload=alert(1)><b>x</b></svg>`,
+		"`<script>alert(1)</script>`",
+		"<!-- commentaire --><p>Texte</p>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+	} {
+		sanitized := SanitizeDescription(description)
+		if htmlTagPattern.MatchString(sanitized) {
+			t.Errorf("SanitizeDescription(%q) = %q keeps raw HTML", description, sanitized)
+		}
+		for _, format := range []string{DESCRIPTION_FORMAT_HTML, DESCRIPTION_FORMAT_TEXT} {
+			rendered := strings.ToLower(RenderDescription(sanitized, format))
+			if strings.Contains(rendered, "onerror") || strings.Contains(rendered, "onload") || strings.Contains(rendered, "<script") || strings.Contains(rendered, "<svg") {
+				t.Errorf("%s of %q = %q", format, description, rendered)
+			}
+		}
+	}
+}
+
+func TestRenderDescription(t *testing.T) {
+	description := "Un **roman** culte.\n\nDeux [liens](https://example.com)."
+
+	if got := RenderDescription(description, DESCRIPTION_FORMAT_MARKDOWN); got != description {
+		t.Errorf("markdown = %q", got)
+	}
+	if got, want := RenderDescription(description, DESCRIPTION_FORMAT_HTML), "<p>Un <strong>roman</strong> culte.</p>\n<p>Deux <a href=\"https://example.com\">liens</a>.</p>"; got != want {
+		t.Errorf("html = %q, want %q", got, want)
+	}
+	if got, want := RenderDescription(description, DESCRIPTION_FORMAT_TEXT), "Un roman culte.\nDeux liens."; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+}
+
+func TestShortDescription(t *testing.T) {
+	if got := ShortDescription("Un **roman** court.", 50); got != "Un roman court." {
+		t.Errorf("short = %q", got)
+	}
+	if got, want := ShortDescription("Un roman bien trop long pour tenir sur une carte.", 20), "Un roman bien trop…"; got != want {
+		t.Errorf("cut = %q, want %q", got, want)
+	}
+}
@@ -12,7 +12,16 @@ import (
 
 const scrapingBotAPI = "http://api.scraping-bot.io/scrape/raw-html"
 
-func Fetch(targetURL string) (string, int, error, bool) {
+// FetchFunc is the signature shared by every fetcher: body, status code, error
+// and whether the body is a direct API payload rather than raw HTML.
+type FetchFunc func(targetURL string) (string, int, error, bool)
+
+// Fetch is the fetcher used by the scrapers. It defaults to scraping-bot.io,
+// archiving responses when the archive is enabled, and can be swapped, e.g. for
+// a ReplayFetcher in tests.
+var Fetch FetchFunc = Archived(ScrapingBotFetch)
+
+func ScrapingBotFetch(targetURL string) (string, int, error, bool) {
 	username := os.Getenv("SCRAPING_BOT_USER")
 	apiKey := os.Getenv("SCRAPING_BOT_KEY")
 	if username == "" || apiKey == "" {
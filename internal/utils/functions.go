@@ -1,6 +1,8 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"net/url"
@@ -8,8 +10,6 @@ import (
 	"strings"
 )
 
-const DZD_TO_EUR = 260
-const SERVICE_FEE = 800
 const COVER_IMG_SIZE = 500
 
 func ExtractID(url string) (string, error) {
@@ -35,6 +35,17 @@ func IsValidEmail(email string) bool {
 	re := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	return re.MatchString(email)
 }
+
+// RandomToken returns a hex token of n random bytes, for links sent to
+// customers.
+func RandomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		Report("Failed to generate a token: "+err.Error(), true)
+	}
+	return hex.EncodeToString(b)
+}
+
 func RandomInt(min, max int) int {
 	return min + rand.Intn(max-min)
 }
@@ -47,16 +58,6 @@ func Shuffle[T any](s []T) []T {
 	return s
 }
 
-func FormatPrice(priceEur float32) float32 {
-	if priceEur < 0 {
-		return -1
-	}
-
-	priceDzd := float32(priceEur * DZD_TO_EUR)
-	rounded := float32(int((priceDzd+4.99)/5) * 5) // round up to nearest 5
-	return rounded + SERVICE_FEE
-}
-
 func ResizeBookImage(url string, size ...int) string {
 	imageSize := COVER_IMG_SIZE
 	if len(size) > 0 && size[0] > 0 {
@@ -97,3 +98,38 @@ func NormalizeQuery(query string) string {
 func EncodeSearchQuery(query string) string {
 	return url.QueryEscape(query)
 }
+
+// ISBN13 returns the ISBN-13 form of an ISBN-10 or ISBN-13, "" when id is
+// not an ISBN (an ASIN or a Google volume ID).
+func ISBN13(id string) string {
+	id = strings.ToUpper(strings.ReplaceAll(id, "-", ""))
+
+	isDigits := func(s string) bool {
+		for _, r := range s {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case len(id) == 13 && isDigits(id) && (strings.HasPrefix(id, "978") || strings.HasPrefix(id, "979")):
+		return id
+	case len(id) == 10 && isDigits(id[:9]) && (isDigits(id[9:]) || id[9] == 'X'):
+		isbn := "978" + id[:9]
+
+		sum := 0
+		for i, r := range isbn {
+			digit := int(r - '0')
+			if i%2 == 1 {
+				digit *= 3
+			}
+			sum += digit
+		}
+
+		return isbn + fmt.Sprint((10-sum%10)%10)
+	}
+
+	return ""
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// languageNames maps the names and ISO 639-2 codes providers use to ISO 639-1.
+// Amazon writes the language in the page locale ("Français", "Anglais",
+// "English"), Lireka and Google Books use codes.
+var languageNames = map[string]string{
+	"fr": "fr", "fre": "fr", "fra": "fr", "french": "fr", "francais": "fr", "français": "fr",
+	"en": "en", "eng": "en", "english": "en", "anglais": "en",
+	"ar": "ar", "ara": "ar", "arabic": "ar", "arabe": "ar",
+	"es": "es", "spa": "es", "spanish": "es", "espagnol": "es", "español": "es",
+	"de": "de", "ger": "de", "deu": "de", "german": "de", "allemand": "de", "deutsch": "de",
+	"it": "it", "ita": "it", "italian": "it", "italien": "it", "italiano": "it",
+	"pt": "pt", "por": "pt", "portuguese": "pt", "portugais": "pt",
+	"nl": "nl", "dut": "nl", "nld": "nl", "dutch": "nl", "néerlandais": "nl",
+	"tr": "tr", "tur": "tr", "turkish": "tr", "turc": "tr",
+}
+
+// languageProfiles hold the most frequent character trigrams of each language,
+// most frequent first. Arabic is detected from its script instead.
+var languageProfiles = map[string][]string{
+	"fr": {
+		" de", "es ", "de ", " le", "ent", "le ", "nt ", "la ", " la", "s d", "ion", "on ", "re ", " pa", "e d",
+		"e l", " co", "les", " et", "et ", "que", "ue ", "tio", " qu", "ne ", "men", " l'", " d'", "des", " re",
+		"ant", "our", "ur ", " un", "ait", "une", "est", "eme", "ans", " en", "ont", "it ", " so", "par", "ous",
+		"s l", "ns ", "ir ", "e p", "lle", "au ", " du", "du ", "e s", "ce ", "pou", " po", "ité", "é", "è",
+	},
+	"en": {
+		" th", "the", "he ", "ed ", " an", "nd ", "and", "ing", "ng ", " to", "to ", " of", "of ", "er ", "ion",
+		" in", "in ", "is ", "tio", "at ", "re ", "on ", "ent", "as ", " a ", "hat", "tha", "es ", " wh", "for",
+		" fo", "his", " be", "ter", "ly ", "was", " is", "it ", "or ", "e t", "all", "ith", "wit", " wi", "her",
+		"you", " yo", "ver", "e a", "d t", "s a", "ere", " ha", "oul", " wa", "are", "ore", "st ", "ght",
+	},
+	"es": {
+		" de", "de ", "os ", " la", "la ", "ión", " qu", "que", "ue ", " el", "el ", "es ", " en", "en ", "as ",
+		"ent", " co", "los", " lo", "ado", "er ", "ra ", " se", "con", "ar ", " pa", "par", "ón ", "nte", "o d",
+		"a d", "cio", "est", "una", " un", "ien", "ida", "ica", "ene", "ero", "ara", "s d", "dad", " su", "por",
+		" po", "del", "men", "ció", "e l", "o e", "a e", "tra", "nto", "mos", "res", "ndo", "ñ", "á", "í",
+	},
+	"de": {
+		"en ", "er ", "ich", "ein", "sch", "der", " de", "die", " di", "che", "ie ", "ch ", "und", " un", "nd ",
+		"cht", " ei", "gen", "den", "ung", "te ", "in ", " zu", "ine", "nde", "ten", "ter", " da", "das", "es ",
+		" ge", "ber", "auf", " au", "ist", " is", "nic", "ach", "ste", "mit", " mi", "sie", " si", "ers", "lic",
+		"ere", "rei", "hen", "ige", "ren", "ens", "bei", "wie", "aus", "eit", "ß", "ü", "ö", "ä",
+	},
+	"it": {
+		" di", "di ", "la ", " la", "to ", "re ", "che", " ch", "he ", "ell", " de", "del", "lla", "one", " co",
+		"are", "zio", "ion", "ent", " il", "il ", "no ", "per", " pe", "i d", "ere", "ato", " in", "o d", "a d",
+		"ne ", "le ", "con", "ta ", "nte", " un", "non", " no", "gli", " e ", "ia ", "ist", "e d", "ggi", "ità",
+		"ess", "sta", "ano", "all", "tta", "zza", "cca", "ebb", "sse", "anc", "ett", "ven", "tut", "ò",
+	},
+}
+
+const (
+	minDetectionTrigrams = 20
+	minDetectionMargin   = 1.15 // best score must beat the runner-up by 15%
+	arabicScriptShare    = 0.3
+)
+
+// NormalizeLanguage maps a provider language name or code to ISO 639-1. It
+// returns "" when the value is empty or unknown.
+func NormalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" {
+		return ""
+	}
+
+	// "fr-FR", "en_US"
+	if i := strings.IndexAny(language, "-_"); i > 0 {
+		language = language[:i]
+	}
+
+	if code, ok := languageNames[language]; ok {
+		return code
+	}
+	if code, ok := languageNames[FoldAccents(language)]; ok {
+		return code
+	}
+
+	return ""
+}
+
+// BookLanguage returns the ISO 639-1 language of a book, from the provider's
+// attribute when it is known and from the title and description otherwise.
+func BookLanguage(attribute, title, description string) string {
+	if code := NormalizeLanguage(attribute); code != "" {
+		return code
+	}
+
+	return DetectLanguage(title + "\n" + description)
+}
+
+// DetectLanguage guesses the language of text with a trigram profile. It
+// returns "" when the text is too short or no language clearly wins.
+func DetectLanguage(text string) string {
+	letters, arabic := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.Is(unicode.Arabic, r) {
+				arabic++
+			}
+		}
+	}
+
+	if letters == 0 {
+		return ""
+	}
+	if float64(arabic)/float64(letters) > arabicScriptShare {
+		return "ar"
+	}
+
+	trigrams := languageTrigrams(text)
+	total := 0
+	for _, count := range trigrams {
+		total += count
+	}
+	if total < minDetectionTrigrams {
+		return ""
+	}
+
+	best, bestScore, secondScore := "", 0.0, 0.0
+	for language, profile := range languageProfiles {
+		score := 0.0
+		for rank, trigram := range profile {
+			// earlier (more frequent) trigrams weigh more
+			score += float64(trigrams[trigram]) * float64(len(profile)-rank)
+		}
+
+		if score > bestScore {
+			best, bestScore, secondScore = language, score, bestScore
+		} else if score > secondScore {
+			secondScore = score
+		}
+	}
+
+	if bestScore == 0 || bestScore < secondScore*minDetectionMargin {
+		return ""
+	}
+	return best
+}
+
+// languageTrigrams counts the trigrams of text, plus single accented letters
+// which some profiles use as strong hints.
+func languageTrigrams(text string) map[string]int {
+	counts := make(map[string]int)
+
+	var cleaned []rune
+	lastSpace := true
+	for _, r := range strings.ToLower(text) {
+		if r == '’' {
+			r = '\''
+		}
+
+		if unicode.IsLetter(r) || r == '\'' {
+			cleaned = append(cleaned, r)
+			lastSpace = false
+			if r > unicode.MaxASCII {
+				counts[string(r)]++
+			}
+		} else if !lastSpace {
+			cleaned = append(cleaned, ' ')
+			lastSpace = true
+		}
+	}
+
+	cleaned = append([]rune{' '}, cleaned...)
+	for i := 0; i+3 <= len(cleaned); i++ {
+		counts[string(cleaned[i:i+3])]++
+	}
+
+	return counts
+}
+
+// FoldAccents removes diacritics: "Français" becomes "francais".
+func FoldAccents(text string) string {
+	var builder strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		if !unicode.Is(unicode.Mn, r) {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
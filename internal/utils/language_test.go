@@ -0,0 +1,49 @@
+package utils
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":         "",
+		"fr":       "fr",
+		" FR-fr ":  "fr",
+		"en_US":    "en",
+		"Français": "fr",
+		"Francais": "fr",
+		"Anglais":  "en",
+		"ara":      "ar",
+		"Deutsch":  "de",
+		"klingon":  "",
+	}
+
+	for language, want := range cases {
+		if got := NormalizeLanguage(language); got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", language, got, want)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":         "",
+		"1984":     "",
+		"Le Petit": "",
+		"Le Petit Prince est une œuvre de langue française, la plus connue d'Antoine de Saint-Exupéry, publiée en 1943 à New York.": "fr",
+		"The Great Gatsby is a novel by the American writer, set in the Jazz Age on Long Island, near New York City.":               "en",
+		"Cien años de soledad es una novela del escritor colombiano Gabriel García Márquez, que narra la historia de la familia.":   "es",
+		"Der Zauberberg ist ein Roman von Thomas Mann, der die Geschichte eines jungen Mannes in einem Sanatorium erzählt.":         "de",
+		"Il nome della rosa è il primo romanzo di Umberto Eco, un giallo ambientato in una abbazia del nord Italia.":                "it",
+		"الأمير الصغير رواية للكاتب الفرنسي أنطوان دو سانت إكزوبيري":                                                                "ar",
+	}
+
+	for text, want := range cases {
+		if got := DetectLanguage(text); got != want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", text, got, want)
+		}
+	}
+
+	// the provider attribute wins over the text
+	if got := BookLanguage("Anglais", "Le Petit Prince", ""); got != "en" {
+		t.Errorf("BookLanguage = %q, want en", got)
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const maxURLFileNameLength = 120
+
+var urlFileNameCleaner = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// ReplayFetcher serves responses recorded on disk instead of hitting the network.
+// With Record set, it fetches through Upstream and stores every 200 response
+// before returning it, so fixtures can be refreshed when the markup changes.
+type ReplayFetcher struct {
+	Dir      string
+	Record   bool
+	Upstream FetchFunc
+}
+
+func NewReplayFetcher(dir string, record bool) *ReplayFetcher {
+	return &ReplayFetcher{
+		Dir:      dir,
+		Record:   record,
+		Upstream: ScrapingBotFetch,
+	}
+}
+
+// URLFileName turns a URL into a readable file name, hashing the tail of long ones.
+func URLFileName(targetURL string) string {
+	name := strings.TrimPrefix(targetURL, "https://")
+	name = strings.TrimPrefix(name, "http://")
+	name = strings.Trim(urlFileNameCleaner.ReplaceAllString(name, "_"), "_")
+
+	if len(name) > maxURLFileNameLength {
+		sum := sha1.Sum([]byte(targetURL))
+		name = name[:maxURLFileNameLength] + "-" + hex.EncodeToString(sum[:])[:10]
+	}
+
+	return name
+}
+
+// FixturePath returns the file a response for targetURL is stored in.
+func (r *ReplayFetcher) FixturePath(targetURL string) string {
+	return filepath.Join(r.Dir, URLFileName(targetURL)+".html")
+}
+
+func (r *ReplayFetcher) Fetch(targetURL string) (string, int, error, bool) {
+	path := r.FixturePath(targetURL)
+
+	if r.Record {
+		content, status, err, isDirect := r.Upstream(targetURL)
+		if err != nil || status != 200 || isDirect {
+			return content, status, err, isDirect
+		}
+
+		if err := os.MkdirAll(r.Dir, 0755); err != nil {
+			return "", 500, fmt.Errorf("failed to create fixture directory: %w", err), false
+		}
+		if err := WriteFile(path, content); err != nil {
+			return "", 500, fmt.Errorf("failed to record fixture %s: %w", path, err), false
+		}
+
+		return content, status, nil, false
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", 404, nil, false
+	}
+	if err != nil {
+		return "", 500, fmt.Errorf("failed to read fixture %s: %w", path, err), false
+	}
+
+	return string(content), 200, nil, false
+}
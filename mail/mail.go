@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Config is the SMTP server mails are sent through.
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string // "Shop <orders@example.com>"
+}
+
+// Message is a mail with an HTML body and its plain text alternative.
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+var config Config
+
+// ErrDisabled is returned for every mail until an SMTP host is configured.
+var ErrDisabled = errors.New("mail is disabled, no SMTP host is configured")
+
+// Deliver sends a message, replaced in tests to inspect what would be sent.
+var Deliver = deliverSMTP
+
+// Configure sets the SMTP server, mails fail with ErrDisabled until it is
+// called with a host.
+func Configure(c Config) {
+	if c.Port == "" {
+		c.Port = "587"
+	}
+	config = c
+}
+
+func Enabled() bool {
+	return config.Host != ""
+}
+
+func Send(message Message) error {
+	if message.To == "" {
+		return fmt.Errorf("missing recipient")
+	}
+	return Deliver(message)
+}
+
+func deliverSMTP(message Message) error {
+	if !Enabled() {
+		return ErrDisabled
+	}
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	from := config.From
+	if start := strings.LastIndex(from, "<"); start >= 0 {
+		from = strings.TrimSuffix(from[start+1:], ">")
+	}
+
+	err := smtp.SendMail(config.Host+":"+config.Port, auth, from, []string{message.To}, build(message))
+	if err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// build renders a multipart/alternative message.
+func build(message Message) []byte {
+	boundary := fmt.Sprintf("boundary-%d", time.Now().UnixNano())
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", boundary, message.Text)
+	if message.HTML != "" {
+		fmt.Fprintf(&b, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n", boundary, message.HTML)
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+
+	return []byte(b.String())
+}
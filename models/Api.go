@@ -103,7 +103,7 @@ func (a *APIResponse) Migrate() Book {
 		Title:       d.Title,
 		Cover:       firstOrEmpty(d.Images),
 		PubDate:     d.DeliveryDate, // best approximation available
-		Language:    guessLanguage(d.Description, d.Category.Name),
+		Language:    "",             // detected from the description by the scraper
 		Publisher:   d.BrandOrFallback(),
 		Description: cleanDesc(d.Description),
 
@@ -137,14 +137,3 @@ func firstOrEmpty(list []string) string {
 func cleanDesc(desc string) string {
 	return strings.TrimSpace(strings.ReplaceAll(desc, "\n", " "))
 }
-
-func guessLanguage(desc, category string) string {
-	desc = strings.ToLower(desc)
-	if strings.Contains(desc, "anglais") || strings.Contains(category, "anglais") {
-		return "English"
-	}
-	if strings.Contains(desc, "français") || strings.Contains(category, "français") {
-		return "French"
-	}
-	return "Unknown"
-}
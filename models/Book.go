@@ -7,12 +7,15 @@ type Book struct {
 	Title       string `json:"title"`
 	Cover       string `json:"cover"`
 	PubDate     string `json:"publication_date"` // was goment.Time before, but decided to replace it with standard date
-	Language    string `json:"language"`
+	Language    string `json:"language"`         // ISO 639-1, "" when unknown
 	Publisher   string `json:"publisher"`
-	Description string `json:"description"`
+	Description string `json:"description"` // sanitised markdown, rendered per request
 
-	Price  float32 `json:"price"`
-	Rating float32 `json:"rating"`
+	ShortDescription string `json:"short_description,omitempty"` // plain text excerpt for listing cards
+
+	Price        float32 `json:"price"`
+	Availability string  `json:"availability,omitempty"` // AVAILABILITY_*, "" when the page doesn't say
+	Rating       float32 `json:"rating"`
 
 	Authors   []AuthorType `json:"authors"`
 	Dimension Dimension    `json:"dimensions"`
@@ -26,8 +29,9 @@ type BookThumbnail struct {
 	Title string `json:"title"`
 	Cover string `json:"cover"`
 
-	Authors []AuthorType `json:"authors"`
-	Rating  float32      `json:"rating"`
+	Authors  []AuthorType `json:"authors"`
+	Rating   float32      `json:"rating"`
+	Language string       `json:"language,omitempty"` // ISO 639-1, "" when unknown
 
 	IsGBook bool `json:"is_gbook"`
 }
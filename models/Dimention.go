@@ -4,4 +4,5 @@ type Dimension struct {
 	Height float64 `json:"height"`
 	Depth  float64 `json:"depth"`
 	Width  float64 `json:"width"`
+	Weight float64 `json:"weight,omitempty"` // grams, 0 when the provider doesn't say
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// ExchangeRate is the DZD value of one unit of Currency from EffectiveAt on,
+// until a rate with a later EffectiveAt. Rates are never edited so the
+// history stays.
+type ExchangeRate struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"created_at"`
+	Currency    string    `json:"currency" gorm:"index:idx_exchange_rate_effective"`
+	Rate        float64   `json:"rate"`
+	EffectiveAt time.Time `json:"effective_at" gorm:"index:idx_exchange_rate_effective"`
+	Source      string    `json:"source"`     // default, manual or csv
+	CreatedBy   string    `json:"created_by"` // admin ID
+	Note        string    `json:"note"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Migration records a data migration already applied to the database, so it
+// runs once.
+type Migration struct {
+	Name      string    `gorm:"primaryKey"`
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -6,6 +6,21 @@ import (
 	"gorm.io/gorm"
 )
 
+// The lifecycle of an order: it awaits a quote, the customer accepts the
+// quote, we order the books from the supplier, receive them and ship them.
+// services.OrderTransitions lists the allowed moves.
+const (
+	ORDER_STATUS_AWAITING_QUOTE        = "awaiting_quote" // new orders
+	ORDER_STATUS_QUOTED                = "quoted"         // the quote email was sent
+	ORDER_STATUS_CONFIRMED             = "confirmed"
+	ORDER_STATUS_ORDERED_FROM_SUPPLIER = "ordered_from_supplier"
+	ORDER_STATUS_RECEIVED              = "received" // the books reached us
+	ORDER_STATUS_SHIPPED               = "shipped"
+	ORDER_STATUS_DELIVERED             = "delivered"
+	ORDER_STATUS_CANCELLED             = "cancelled"
+	ORDER_STATUS_REFUNDED              = "refunded"
+)
+
 type Order struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -18,7 +33,31 @@ type Order struct {
 	Address          string `json:"address"`
 	SubscriptionCode string `json:"subscriptionCode"` // Add subscription code field
 
-	Status string `json:"status"` // e.g., "pending", "shipped", "delivered"
+	Status string `json:"status"` // ORDER_STATUS_*
+
+	TrackingToken string `json:"tracking_token" gorm:"index"` // the customer follows the order at /track/:token
+
+	ExchangeRate float64 `json:"exchange_rate"` // DZD for 1 EUR when the order was placed
+
+	Wilaya         int     `json:"wilaya"`          // code from 1 to 58, 0 when only the address says
+	DeliveryMethod string  `json:"delivery_method"` // DELIVERY_HOME or DELIVERY_STOP_DESK
+	ShippingCost   float64 `json:"shipping_cost"`   // DZD, part of the quote total
+	ShippingWeight float64 `json:"shipping_weight"` // kg
+
+	// DZD, computed from the lines: Total = Subtotal + Fees + ShippingCost
+	Subtotal float64 `json:"subtotal"`
+	Fees     float64 `json:"fees"` // per order fees of the pricing rules
+	Total    float64 `json:"total"`
+
+	QuoteTotal      float64    `json:"quote_total"` // DZD, set when the quote is sent
+	QuoteNote       string     `json:"quote_note"`  // shown to the customer in the quote email
+	QuoteToken      string     `json:"-" gorm:"index"`
+	QuoteSentAt     *time.Time `json:"quote_sent_at"`
+	QuoteAcceptedAt *time.Time `json:"quote_accepted_at"`
+
+	IdempotencyKey *string `json:"-" gorm:"uniqueIndex"`                // Idempotency-Key header of the checkout, nil without
+	DuplicateOfID  *uint   `json:"duplicate_of_id" gorm:"index"`        // earlier order this one probably repeats
+	LinkedOrderIDs []uint  `json:"linked_order_ids,omitempty" gorm:"-"` // probable duplicates, both ways
 
 	// Relationships
 	OrderItems []OrderItem `json:"order_items" gorm:"foreignKey:OrderID"`
@@ -31,6 +70,51 @@ type OrderItem struct {
 	ItemID   string `json:"itemId"`   // Changed to match TypeScript interface
 	Quantity int    `json:"quantity"`
 
+	BasePrice     float64 `json:"basePrice"`     // provider price in EUR when the order was placed
+	ComputedPrice float64 `json:"computedPrice"` // DZD, from the pricing rules, 0 when unavailable
+	UnitPrice     float64 `json:"unitPrice"`     // DZD, quoted to the customer, adjusted by an admin
+	LineTotal     float64 `json:"lineTotal"`     // DZD, UnitPrice × Quantity
+
+	// what the customer ordered, kept as it was when the order was placed
+	Title    string `json:"title"`
+	Cover    string `json:"cover"`
+	Author   string `json:"author"` // names, comma separated
+	Provider string `json:"provider"`
+
+	Availability string `json:"availability,omitempty"` // AVAILABILITY_* at the provider, "" when unknown
+
+	PurchaseBatchID *uint `json:"purchaseBatchId" gorm:"index"` // batch the book is bought in, nil until batched
+
 	// Relationship back to order
 	Order Order `json:"-" gorm:"foreignKey:OrderID"`
 }
+
+// Reasons an item of a new order is refused.
+const (
+	ORDER_ITEM_INVALID_TYPE     = "invalid_type"
+	ORDER_ITEM_MISSING_ID       = "missing_id"
+	ORDER_ITEM_INVALID_QUANTITY = "invalid_quantity"
+	ORDER_ITEM_NOT_FOUND        = "not_found"
+	ORDER_ITEM_AUDIOBOOK        = "audiobook"
+	ORDER_ITEM_UNKNOWN_PROVIDER = "unknown_provider"
+)
+
+// OrderItemError tells why an item of a new order was refused.
+type OrderItemError struct {
+	Index   int    `json:"index"` // position of the item in the order
+	ItemID  string `json:"itemId"`
+	Code    string `json:"code"` // ORDER_ITEM_*
+	Message string `json:"message"`
+}
+
+// OrderPage is a page of the admin order list.
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	Total      int64   `json:"total"` // orders matching the filters
+	Page       int     `json:"page"`  // 0 when paginating with cursors
+	Limit      int     `json:"limit"`
+	Pages      int     `json:"pages"`
+	NextCursor string  `json:"next_cursor,omitempty"` // "" on the last page
+
+	StatusCounts map[string]int64 `json:"status_counts"` // matching orders by status, the status filter aside
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+const (
+	ORDER_EVENT_CREATED       = "created"
+	ORDER_EVENT_STATUS        = "status" // FromStatus -> ToStatus
+	ORDER_EVENT_QUOTE_UPDATED = "quote_updated"
+	ORDER_EVENT_NOTE          = "note"
+	ORDER_EVENT_PRICE_ALERT   = "price_alert"
+	ORDER_EVENT_DELETED       = "deleted"
+
+	ORDER_ACTOR_ADMIN    = "admin"
+	ORDER_ACTOR_CUSTOMER = "customer"
+	ORDER_ACTOR_SYSTEM   = "system"
+)
+
+// OrderEvent is an entry of the history of an order: a status change, an
+// edit or a note.
+type OrderEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	OrderID   uint      `json:"order_id" gorm:"index"`
+
+	Type    string `json:"type"`
+	Actor   string `json:"actor"`    // admin, customer or system
+	AdminID string `json:"admin_id"` // set when Actor is admin
+
+	FromStatus string         `json:"from_status,omitempty"`
+	ToStatus   string         `json:"to_status,omitempty"`
+	Comment    string         `json:"comment,omitempty"`
+	Details    map[string]any `json:"details,omitempty" gorm:"serializer:json"` // what an edit changed
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// OrderTracking is what a customer sees of an order, at checkout and from its
+// tracking link: no contact details, no prices before the quote and no admin
+// notes, the link may be forwarded.
+type OrderTracking struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Status    string    `json:"status"` // ORDER_STATUS_*
+
+	TrackingURL string `json:"tracking_url,omitempty"` // given at checkout, the tracking page is already there
+
+	Name  string `json:"name"`  // first name only
+	Email string `json:"email"` // masked, "a***@example.com"
+
+	Wilaya         string `json:"wilaya"` // name, "" when not given
+	DeliveryMethod string `json:"delivery_method"`
+
+	QuoteTotal  float64    `json:"quote_total,omitempty"` // DZD, once the quote is sent
+	QuoteSentAt *time.Time `json:"quote_sent_at,omitempty"`
+
+	Items   []OrderTrackingItem `json:"items"`
+	History []OrderTrackingStep `json:"history"`
+}
+
+type OrderTrackingItem struct {
+	Title    string `json:"title"`
+	Cover    string `json:"cover"`
+	Author   string `json:"author"`
+	Quantity int    `json:"quantity"`
+}
+
+// OrderTrackingStep is a status the order went through.
+type OrderTrackingStep struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+const (
+	AVAILABILITY_IN_STOCK     = "in_stock"
+	AVAILABILITY_OUT_OF_STOCK = "out_of_stock" // temporarily, can still be ordered
+	AVAILABILITY_UNAVAILABLE  = "unavailable"
+	AVAILABILITY_UNKNOWN      = "unknown"
+)
+
+// PriceObservation is the price and stock status of a book seen at a provider.
+type PriceObservation struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	BookID       string    `json:"book_id" gorm:"index:idx_price_observations_book"`
+	Provider     string    `json:"provider" gorm:"index:idx_price_observations_book"`
+	Price        float64   `json:"price"` // EUR, 0 when the provider lists no price
+	Availability string    `json:"availability"`
+	ObservedAt   time.Time `json:"observed_at" gorm:"index"`
+}
+
+// PriceAlert flags an item of a quoted order whose book now costs more than
+// the customer was quoted. There is one open alert per item at most.
+type PriceAlert struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrderID     uint   `json:"order_id" gorm:"index"`
+	OrderItemID uint   `json:"order_item_id" gorm:"index"`
+	BookID      string `json:"book_id"`
+	Provider    string `json:"provider"`
+
+	QuotedPrice  float64 `json:"quoted_price"`  // DZD
+	CurrentPrice float64 `json:"current_price"` // DZD, with the rules in effect when observed
+	BasePrice    float64 `json:"base_price"`    // EUR, the observed provider price
+
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	AcknowledgedBy string     `json:"acknowledged_by"` // admin ID
+}
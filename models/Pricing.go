@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// PricingRuleSet is one version of the pricing rules. Rules are never edited:
+// saving creates a new version and the latest one applies.
+type PricingRuleSet struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Version   int       `json:"version" gorm:"uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"` // admin ID, "" for the defaults
+	Note      string    `json:"note"`
+
+	ExchangeRate float64 `json:"exchange_rate"` // DZD for 1 EUR, 0 follows the exchange rate table
+
+	Margins []PricingMargin `json:"margins" gorm:"serializer:json"`
+	Fees    []PricingFee    `json:"fees" gorm:"serializer:json"`
+
+	RoundingStep float64 `json:"rounding_step"` // DZD, 0 disables rounding
+	RoundingMode string  `json:"rounding_mode"` // up, down or nearest
+	MinimumPrice float64 `json:"minimum_price"` // DZD
+}
+
+// PricingMargin applies to provider prices in [MinPrice, MaxPrice) euros, MaxPrice
+// 0 meaning no upper bound. A margin with a category wins over a generic one.
+type PricingMargin struct {
+	Category string  `json:"category,omitempty"`
+	MinPrice float64 `json:"min_price"`
+	MaxPrice float64 `json:"max_price"`
+	Percent  float64 `json:"percent"` // of the converted price
+	Fixed    float64 `json:"fixed"`   // DZD
+}
+
+// PricingFee is a fixed amount added to every price, or to a category only.
+// Per order fees are charged once per order holding an item of the category
+// instead.
+type PricingFee struct {
+	Name     string  `json:"name"`
+	Category string  `json:"category,omitempty"`
+	Amount   float64 `json:"amount"` // DZD
+	PerOrder bool    `json:"per_order,omitempty"`
+}
+
+// PriceBreakdown details how a price was computed.
+type PriceBreakdown struct {
+	BasePrice    float64 `json:"base_price"` // provider price, EUR
+	Category     string  `json:"category"`
+	RulesVersion int     `json:"rules_version"`
+
+	ExchangeRate       float64        `json:"exchange_rate"`
+	ExchangeRateSource string         `json:"exchange_rate_source"` // rules or table
+	Converted          float64        `json:"converted"`            // DZD
+	Margin             float64        `json:"margin"`
+	MarginRule         *PricingMargin `json:"margin_rule"`
+	Fees               []PricingFee   `json:"fees"`
+	Subtotal           float64        `json:"subtotal"`
+	Rounded            float64        `json:"rounded"`
+	Price              float64        `json:"price"` // DZD, what the customer pays
+}
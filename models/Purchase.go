@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// A purchase batch is drafted from the confirmed orders, placed at the
+// provider and received when the books reach us.
+const (
+	PURCHASE_DRAFT    = "draft"
+	PURCHASE_PLACED   = "placed"
+	PURCHASE_RECEIVED = "received"
+)
+
+// PurchaseBatch is one order we place at a provider for the books of
+// confirmed orders. Its order items point back to it.
+type PurchaseBatch struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedBy string    `json:"created_by"` // admin ID
+
+	Provider string `json:"provider" gorm:"index"` // books.PROVIDER_*
+	Status   string `json:"status" gorm:"index"`   // PURCHASE_*
+
+	Reference string `json:"reference"` // order number at the provider
+	Note      string `json:"note"`
+
+	EstimatedCost float64 `json:"estimated_cost"` // EUR, base prices of the items
+	Cost          float64 `json:"cost"`           // EUR, what we paid, set by an admin
+
+	PlacedAt   *time.Time `json:"placed_at"`
+	ReceivedAt *time.Time `json:"received_at"`
+
+	Lines []PurchaseLine `json:"lines" gorm:"-"`
+}
+
+// PurchaseLine is a book of a purchase batch, with the orders it is bought for.
+type PurchaseLine struct {
+	ItemID   string  `json:"item_id"`
+	Title    string  `json:"title"`
+	Author   string  `json:"author"`
+	Quantity int     `json:"quantity"`
+	UnitCost float64 `json:"unit_cost"` // EUR
+	OrderIDs []uint  `json:"order_ids"`
+}
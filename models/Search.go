@@ -0,0 +1,64 @@
+package models
+
+// SearchHit is one book of a federated search, merged from every provider
+// that returned it.
+type SearchHit struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Cover string `json:"cover"`
+
+	Authors   []AuthorType `json:"authors"`
+	Publisher string       `json:"publisher,omitempty"`
+	PubDate   string       `json:"publication_date,omitempty"`
+	Language  string       `json:"language,omitempty"` // ISO 639-1, "" when unknown
+	Pages     int          `json:"pages,omitempty"`
+	Price     float32      `json:"price,omitempty"` // provider price, EUR
+	Rating    float32      `json:"rating"`
+
+	Description      string `json:"description,omitempty"`
+	ShortDescription string `json:"short_description,omitempty"`
+
+	LocalPrice float64 `json:"-"` // DZD with the current pricing rules, 0 when unknown, buckets the price facet
+
+	Provider  string   `json:"provider"`  // provider the ID belongs to
+	Providers []string `json:"providers"` // every provider that returned the book
+	Score     float64  `json:"score"`
+
+	IsGBook bool `json:"is_gbook"`
+}
+
+// SearchProviderStatus reports how one provider answered a federated search.
+type SearchProviderStatus struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"` // ok, failed, timeout or skipped
+	Hits     int    `json:"hits"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// SearchFacet counts the values of one facet among the search results.
+type SearchFacet struct {
+	Name   string             `json:"name"`
+	Values []SearchFacetValue `json:"values"`
+}
+
+type SearchFacetValue struct {
+	Value    string `json:"value"`
+	Count    int    `json:"count"`
+	Selected bool   `json:"selected"`
+}
+
+type SearchResponse struct {
+	PageCount int                    "json:\"pages\""
+	Books     []SearchHit            `json:"books"`
+	Facets    []SearchFacet          `json:"facets"`
+	Providers []SearchProviderStatus `json:"providers"`
+	TimedOut  []string               `json:"timed_out"`
+}
+
+// SearchSuggestion is one completion of the search box.
+type SearchSuggestion struct {
+	Text   string  `json:"text"`
+	Kind   string  `json:"kind"`              // query, title, author or series
+	BookID string  `json:"book_id,omitempty"` // titles link straight to the book
+	Weight float64 `json:"-"`
+}
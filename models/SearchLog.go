@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// SearchLog records one search of the storefront.
+type SearchLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	Query           string `json:"query"`
+	NormalizedQuery string `json:"normalized_query" gorm:"index"` // lower case, accents folded
+	Page            int    `json:"page"`
+	ResultCount     int    `json:"result_count"`
+	LatencyMs       int64  `json:"latency_ms"`
+	Client          string `json:"-" gorm:"index"` // keyed hash of the customer's IP, "" when unknown
+
+	Providers []SearchLogProvider `json:"providers" gorm:"foreignKey:SearchLogID"`
+}
+
+// SearchLogProvider is how one provider answered a logged search.
+type SearchLogProvider struct {
+	ID          uint   `json:"-" gorm:"primaryKey"`
+	SearchLogID uint   `json:"-" gorm:"index"`
+	Provider    string `json:"provider" gorm:"index"`
+	Status      string `json:"status"` // ok, failed, timeout or skipped
+	Hits        int    `json:"hits"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+// QueryCount is how many times, or by how many customers, a normalised query
+// was searched.
+type QueryCount struct {
+	Query string `json:"query"`
+	Count int    `json:"count"`
+}
+
+// QueryStats summarises the searches of one normalised query.
+type QueryStats struct {
+	Query          string    `json:"query"`
+	Example        string    `json:"example"` // the query as last typed by a customer
+	Count          int       `json:"count"`
+	AverageResults float64   `json:"average_results"`
+	LastSearchedAt time.Time `json:"last_searched_at"`
+}
+
+// SearchTrend counts the searches of one day or week.
+type SearchTrend struct {
+	Period         string  `json:"period"` // 2025-01-31 or 2025-W04
+	Searches       int     `json:"searches"`
+	UniqueQueries  int     `json:"unique_queries"`
+	ZeroResults    int     `json:"zero_results"`
+	AverageLatency float64 `json:"average_latency_ms"`
+}
+
+// ProviderStats summarises how a search provider answered.
+type ProviderStats struct {
+	Provider        string  `json:"provider"`
+	Searches        int     `json:"searches"`
+	Ok              int     `json:"ok"`
+	Failed          int     `json:"failed"`
+	TimedOut        int     `json:"timed_out"`
+	AverageHits     float64 `json:"average_hits"`
+	AverageDuration float64 `json:"average_duration_ms"`
+}
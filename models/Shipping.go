@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	DELIVERY_HOME      = "home"
+	DELIVERY_STOP_DESK = "stop_desk" // picked up at the carrier's office
+)
+
+type Wilaya struct {
+	Code int    `json:"code"`
+	Name string `json:"name"`
+}
+
+// ShippingRate is the price of a delivery method to a wilaya. The rates of
+// wilaya 0 apply to the wilayas without their own.
+type ShippingRate struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by"` // admin ID, "" for the defaults
+
+	Wilaya int    `json:"wilaya" gorm:"uniqueIndex:idx_shipping_rates_destination"`
+	Method string `json:"method" gorm:"uniqueIndex:idx_shipping_rates_destination"`
+
+	Price          float64 `json:"price"`           // DZD, up to IncludedWeight
+	IncludedWeight float64 `json:"included_weight"` // kg
+	ExtraKgPrice   float64 `json:"extra_kg_price"`  // DZD per started kg above IncludedWeight
+	DeliveryDays   int     `json:"delivery_days"`   // shown to customers, 0 when unknown
+	Disabled       bool    `json:"disabled"`        // the method doesn't serve the wilaya
+}
+
+// ShippingQuote is the cost of delivering a set of items.
+type ShippingQuote struct {
+	Wilaya       int     `json:"wilaya"`
+	WilayaName   string  `json:"wilaya_name"`
+	Method       string  `json:"method"`
+	Weight       float64 `json:"weight"` // kg, the larger of the estimated and volumetric weights
+	BasePrice    float64 `json:"base_price"`
+	Surcharge    float64 `json:"surcharge"`
+	Price        float64 `json:"price"` // DZD
+	DeliveryDays int     `json:"delivery_days"`
+}
@@ -0,0 +1,81 @@
+package sheet
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Writer writes a table row by row, the first row being the header. Cells are
+// strings, numbers or times.
+type Writer interface {
+	WriteRow(cells ...any) error
+	Close() error
+}
+
+const TIME_FORMAT = "2006-01-02 15:04"
+
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+// NewCSV writes UTF-8 CSV with a byte order mark, spreadsheets otherwise read
+// accents as Latin-1.
+func NewCSV(w io.Writer) (Writer, error) {
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return nil, err
+	}
+	return &csvWriter{writer: csv.NewWriter(w)}, nil
+}
+
+func (w *csvWriter) WriteRow(cells ...any) error {
+	w.record = w.record[:0]
+	for _, cell := range cells {
+		text := formatCell(cell)
+		if _, ok := cell.(string); ok {
+			text = escapeFormula(text)
+		}
+		w.record = append(w.record, text)
+	}
+	return w.writer.Write(w.record)
+}
+
+// escapeFormula keeps spreadsheets from running a text cell as a formula, a
+// customer could otherwise type one as their name. XLSX cells are typed, only
+// CSV needs it.
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func formatCell(cell any) string {
+	switch value := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case uint:
+		return strconv.FormatUint(uint64(value), 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return value.Format(TIME_FORMAT)
+	default:
+		return ""
+	}
+}
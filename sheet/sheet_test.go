@@ -0,0 +1,124 @@
+package sheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSV(t *testing.T) {
+	var buffer bytes.Buffer
+	out, err := NewCSV(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.WriteRow("ID", "Titre", "Prix", "Date")
+	out.WriteRow(uint(7), "Rouge, noir", 3400.5, time.Date(2025, 3, 1, 10, 30, 0, 0, time.Local))
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\uFEFFID,Titre,Prix,Date\n7,\"Rouge, noir\",3400.5,2025-03-01 10:30\n"
+	if buffer.String() != want {
+		t.Errorf("csv = %q, want %q", buffer.String(), want)
+	}
+}
+
+func TestCSVFormulas(t *testing.T) {
+	var buffer bytes.Buffer
+	out, err := NewCSV(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.WriteRow(`=HYPERLINK("http://evil.example","Amine")`, "=cmd|' /C calc'!A0", "+213555", "-1", "@SUM(A1)", "\tTab", "\rCR", "Amine", -12.5)
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buffer.String(), "\uFEFF"))).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`'=HYPERLINK("http://evil.example","Amine")`, "'=cmd|' /C calc'!A0", "'+213555", "'-1", "'@SUM(A1)", "'\tTab", "'\rCR", "Amine", "-12.5"}
+	for i := range want {
+		if record[i] != want[i] {
+			t.Errorf("cell %d = %q, want %q", i, record[i], want[i])
+		}
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	var buffer bytes.Buffer
+	out, err := NewXLSX(&buffer, "Commandes & livraisons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.WriteRow("ID", "Titre", "Prix", "Date")
+	out.WriteRow(1, "L'Étranger <poche>\x01", 3400.5, time.Date(2025, 3, 1, 10, 30, 0, 0, time.Local))
+	out.WriteRow(uint(2), nil, 0.0, time.Time{})
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+
+		// every part must be well formed
+		decoder := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", file.Name, err)
+			}
+		}
+		parts[file.Name] = string(content)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{
+		`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">ID</t></is></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">L&#39;Étranger &lt;poche&gt;</t></is></c>`,
+		`<c r="C2"><v>3400.5</v></c>`,
+		`<c r="D2" s="2"><v>45717.4375</v></c>`,
+		`<row r="3"><c r="A3"><v>2</v></c><c r="C3"><v>0</v></c></row>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("sheet lacks %s:\n%s", cell, sheet)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Commandes &amp; livraisons"`) {
+		t.Errorf("workbook = %s", parts["xl/workbook.xml"])
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	cases := map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for index, want := range cases {
+		if got := ColumnName(index); got != want {
+			t.Errorf("ColumnName(%d) = %q, want %q", index, got, want)
+		}
+	}
+}